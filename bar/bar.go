@@ -15,7 +15,10 @@
 // Package bar allows a user to create a go binary that follows the i3bar protocol.
 package bar // import "barista.run/bar"
 
-import "image/color"
+import (
+	"context"
+	"image/color"
+)
 
 // TextAlignment defines the alignment of text within a block.
 // Using TextAlignment rather than string opens up the possibility of i18n without
@@ -69,6 +72,10 @@ type Segment struct {
 	urgent    bool
 	separator bool
 	padding   int
+
+	// If non-empty, the segment can be acknowledged (hidden) by clicking,
+	// until its content changes. See Acknowledgeable for details.
+	ackID string
 }
 
 // sa* (Segment Attribute) consts are used as bitwise flags in attrSet
@@ -127,15 +134,18 @@ X, Y describe event co-ordinates relative to the output segment, and
 Width, Height are set to the size of the output segment.
 
 ScreenX, ScreenY are the event co-ordinates relative to the root window.
+
+Modifiers lists the keyboard modifiers held during the click, e.g. "Shift".
 */
 type Event struct {
-	Button  Button `json:"button"`
-	X       int    `json:"relative_x,omitempty"`
-	Y       int    `json:"relative_y,omitempty"`
-	Width   int    `json:"width,omitempty"`
-	Height  int    `json:"height,omitempty"`
-	ScreenX int    `json:"x,omitempty"`
-	ScreenY int    `json:"y,omitempty"`
+	Button    Button   `json:"button"`
+	X         int      `json:"relative_x,omitempty"`
+	Y         int      `json:"relative_y,omitempty"`
+	Width     int      `json:"width,omitempty"`
+	Height    int      `json:"height,omitempty"`
+	ScreenX   int      `json:"x,omitempty"`
+	ScreenY   int      `json:"y,omitempty"`
+	Modifiers []string `json:"modifiers,omitempty"`
 }
 
 /*
@@ -166,3 +176,13 @@ type Module interface {
 	// subsequent calls may receive different instances.
 	Stream(Sink)
 }
+
+// ContextModule is a Module that can be stopped by cancelling a context,
+// e.g. when the bar exits. The bar calls StreamContext instead of Stream
+// for modules that implement it, and modules should return from
+// StreamContext, cleaning up any resources, once the context is done.
+// Stream typically calls StreamContext with context.Background().
+type ContextModule interface {
+	Module
+	StreamContext(context.Context, Sink)
+}
@@ -14,7 +14,11 @@
 
 package bar
 
-import "image/color"
+import (
+	"image/color"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
 
 // TextSegment creates a new output segment with text content.
 func TextSegment(text string) *Segment {
@@ -163,6 +167,16 @@ func (s *Segment) Urgent(urgent bool) *Segment {
 	return s
 }
 
+// UrgentIf marks the segment as urgent if the condition holds, e.g. when a
+// value crosses a threshold. Otherwise the urgency is left unchanged, so a
+// segment built on each render is only urgent while the condition holds.
+func (s *Segment) UrgentIf(cond bool) *Segment {
+	if cond {
+		s.Urgent(true)
+	}
+	return s
+}
+
 // IsUrgent returns true if this segment is marked urgent.
 // The second value indicates whether it was explicitly set.
 func (s *Segment) IsUrgent() (bool, bool) {
@@ -232,6 +246,27 @@ func (s *Segment) Click(e Event) {
 	}
 }
 
+// Acknowledgeable marks the segment as one that can be dismissed by the user.
+// Clicking on an acknowledgeable segment hides it (in addition to calling any
+// click handler), until the content of the segment changes. This is useful
+// for alerts, which can be acknowledged until the alert condition changes.
+//
+// The identity of a segment is the given id, scoped to the module that
+// outputs it, so two modules can use the same id without interfering. Within
+// a module, the id should be unique for each acknowledgeable segment. If
+// a module stops outputting the segment, the acknowledgement is discarded.
+// An empty id marks the segment as not acknowledgeable.
+func (s *Segment) Acknowledgeable(id string) *Segment {
+	s.ackID = id
+	return s
+}
+
+// GetAcknowledgeID returns the identifier used to track acknowledgements
+// of this segment. The second value indicates whether it was set.
+func (s *Segment) GetAcknowledgeID() (string, bool) {
+	return s.ackID, s.ackID != ""
+}
+
 // Segments implements bar.Output for a single Segment.
 func (s *Segment) Segments() []*Segment {
 	return []*Segment{s}
@@ -249,3 +284,49 @@ func (s *Segment) Clone() *Segment {
 func (s Segments) Segments() []*Segment {
 	return s
 }
+
+func colorString(c color.Color) string {
+	cful, _ := colorful.MakeColor(c)
+	return cful.Hex()
+}
+
+// I3Map serialises the attributes of the Segment in
+// the format used by i3bar.
+func (s *Segment) I3Map() map[string]interface{} {
+	i3map := make(map[string]interface{})
+	txt, pango := s.Content()
+	i3map["full_text"] = txt
+	if shortText, ok := s.GetShortText(); ok {
+		i3map["short_text"] = shortText
+	}
+	if color, ok := s.GetColor(); ok {
+		i3map["color"] = colorString(color)
+	}
+	if background, ok := s.GetBackground(); ok {
+		i3map["background"] = colorString(background)
+	}
+	if border, ok := s.GetBorder(); ok {
+		i3map["border"] = colorString(border)
+	}
+	if minWidth, ok := s.GetMinWidth(); ok {
+		i3map["min_width"] = minWidth
+	}
+	if align, ok := s.GetAlignment(); ok {
+		i3map["align"] = align
+	}
+	if urgent, ok := s.IsUrgent(); ok {
+		i3map["urgent"] = urgent
+	}
+	if separator, ok := s.HasSeparator(); ok {
+		i3map["separator"] = separator
+	}
+	if padding, ok := s.GetPadding(); ok {
+		i3map["separator_block_width"] = padding
+	}
+	if pango {
+		i3map["markup"] = "pango"
+	} else {
+		i3map["markup"] = "none"
+	}
+	return i3map
+}
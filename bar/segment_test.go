@@ -59,6 +59,7 @@ func TestSegment(t *testing.T) {
 	assertUnset(segment.GetBackground())
 	assertUnset(segment.GetBorder())
 	assertUnset(segment.GetMinWidth())
+	assertUnset(segment.GetAcknowledgeID())
 	require.False(segment.HasClick())
 
 	defaultUrgent := assertUnset(segment.IsUrgent())
@@ -113,6 +114,11 @@ func TestSegment(t *testing.T) {
 	segment.Error(nil)
 	require.NoError(segment.GetError())
 
+	segment.Acknowledgeable("alert")
+	require.Equal("alert", assertSet(segment.GetAcknowledgeID()))
+	segment.Acknowledgeable("")
+	assertUnset(segment.GetAcknowledgeID())
+
 	segment.MinWidth(40)
 	require.Equal(40, assertSet(segment.GetMinWidth()))
 	segment.MinWidth(0)
@@ -183,3 +189,16 @@ func TestClone(t *testing.T) {
 	require.True(isSet)
 	require.Equal("short", text)
 }
+
+func TestUrgentIf(t *testing.T) {
+	urgent, isSet := TextSegment("a").UrgentIf(false).IsUrgent()
+	require.False(t, isSet, "not set when condition is false")
+	require.False(t, urgent)
+
+	urgent, isSet = TextSegment("a").UrgentIf(true).IsUrgent()
+	require.True(t, isSet)
+	require.True(t, urgent)
+
+	urgent, _ = TextSegment("a").Urgent(true).UrgentIf(false).IsUrgent()
+	require.True(t, urgent, "existing urgency is kept")
+}
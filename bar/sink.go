@@ -14,6 +14,14 @@
 
 package bar
 
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"barista.run/timing"
+)
+
 // Output updates the module's output on the bar.
 func (s Sink) Output(o Output) {
 	s(o)
@@ -29,3 +37,210 @@ func (s Sink) Error(e error) bool {
 	}
 	return false
 }
+
+// ThrottledSink wraps a sink so that outputs are sent at most once per max
+// duration. The first output is sent immediately, and further outputs within
+// max of the last one sent are collapsed, with the latest sent when the
+// interval elapses. Outputs that contain errors (e.g. from Sink.Error) are
+// always sent immediately, and discard any pending output.
+//
+// The sink uses a goroutine to send pending outputs, which runs for as long as
+// the program does. To throttle a module, use ThrottledModule instead, which
+// stops the goroutine when the module's Stream returns.
+func ThrottledSink(s Sink, max time.Duration) Sink {
+	sink, _ := newThrottledSink(s, max)
+	return sink
+}
+
+// newThrottledSink returns a ThrottledSink, and a function that sends any
+// pending output and stops it.
+func newThrottledSink(s Sink, max time.Duration) (Sink, func()) {
+	t := &throttledSink{
+		sink: s,
+		max:  max,
+		sch:  timing.NewScheduler(),
+		done: make(chan struct{}),
+	}
+	go t.run()
+	return t.output, t.stop
+}
+
+// ThrottledModule wraps a module so that its outputs are throttled using
+// ThrottledSink, without changes to the module itself.
+func ThrottledModule(m Module, max time.Duration) Module {
+	return throttledModule{m, max}
+}
+
+type throttledModule struct {
+	Module
+	max time.Duration
+}
+
+func (t throttledModule) Stream(s Sink) {
+	sink, stop := newThrottledSink(s, t.max)
+	defer stop()
+	t.Module.Stream(sink)
+}
+
+type throttledSink struct {
+	sink Sink
+	max  time.Duration
+	sch  timing.Scheduler
+	done chan struct{}
+
+	mu       sync.Mutex
+	lastSent time.Time
+	pending  Output
+	waiting  bool
+}
+
+func (t *throttledSink) output(o Output) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if hasError(o) {
+		t.send(o)
+		return
+	}
+	if !t.waiting && timing.Now().Sub(t.lastSent) >= t.max {
+		t.send(o)
+		return
+	}
+	t.pending = o
+	if !t.waiting {
+		t.waiting = true
+		t.sch.At(t.lastSent.Add(t.max))
+	}
+}
+
+func (t *throttledSink) run() {
+	for {
+		select {
+		case <-t.sch.Tick():
+		case <-t.done:
+			return
+		}
+		t.mu.Lock()
+		// Ignore stale ticks from a scheduler stopped by an earlier send.
+		if t.waiting && !timing.Now().Before(t.lastSent.Add(t.max)) {
+			t.send(t.pending)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// stop sends any pending output, so that the last output of a module is not
+// lost, and stops the scheduler and the goroutine.
+func (t *throttledSink) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waiting {
+		t.send(t.pending)
+	}
+	t.sch.Stop()
+	close(t.done)
+}
+
+// send sends an output, and clears any pending output.
+// Must be called with mu held.
+func (t *throttledSink) send(o Output) {
+	t.sch.Stop()
+	t.pending, t.waiting = nil, false
+	t.lastSent = timing.Now()
+	t.sink(o)
+}
+
+func hasError(o Output) bool {
+	if o == nil {
+		return false
+	}
+	for _, s := range o.Segments() {
+		if s.GetError() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DedupSink wraps a sink so that outputs identical to the previous output
+// are not sent. Outputs are compared using their i3bar serialisation and any
+// errors, so outputs that are built separately but would render the same are
+// also skipped. Since click handlers cannot be compared, a skipped output's
+// handlers are not used, so outputs whose handlers change should be wrapped
+// with Forced, which always sends the output.
+func DedupSink(s Sink) Sink {
+	d := &dedupSink{sink: s}
+	return d.output
+}
+
+// DedupModule wraps a module so that its outputs are deduplicated using
+// DedupSink, without changes to the module itself.
+func DedupModule(m Module) Module {
+	return dedupModule{m}
+}
+
+// Forced wraps an output so that it is always sent by a DedupSink, even if
+// identical to the previous output. Other sinks treat it like the original.
+func Forced(o Output) Output {
+	return forcedOutput{o}
+}
+
+type forcedOutput struct {
+	Output
+}
+
+func (f forcedOutput) Segments() []*Segment {
+	if f.Output == nil {
+		return nil
+	}
+	return f.Output.Segments()
+}
+
+type dedupModule struct {
+	Module
+}
+
+func (d dedupModule) Stream(s Sink) {
+	d.Module.Stream(DedupSink(s))
+}
+
+type dedupSink struct {
+	sink Sink
+
+	mu   sync.Mutex
+	last string
+	sent bool
+}
+
+func (d *dedupSink) output(o Output) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f, ok := o.(forcedOutput); ok {
+		o = f.Output
+	} else if d.sent && renderKey(o) == d.last {
+		return
+	}
+	d.last, d.sent = renderKey(o), true
+	d.sink(o)
+}
+
+// renderKey returns the i3bar serialisation of an output, with any errors,
+// for comparing outputs.
+func renderKey(o Output) string {
+	if o == nil {
+		return "null"
+	}
+	type rendered struct {
+		I3    map[string]interface{}
+		Error string `json:",omitempty"`
+	}
+	var segments []rendered
+	for _, s := range o.Segments() {
+		r := rendered{I3: s.I3Map()}
+		if err := s.GetError(); err != nil {
+			r.Error = err.Error()
+		}
+		segments = append(segments, r)
+	}
+	key, _ := json.Marshal(segments)
+	return string(key)
+}
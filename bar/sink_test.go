@@ -17,6 +17,9 @@ package bar
 import (
 	"io"
 	"testing"
+	"time"
+
+	"barista.run/timing"
 
 	"github.com/stretchr/testify/require"
 )
@@ -61,3 +64,143 @@ func TestError(t *testing.T) {
 		require.Fail(t, "Expected an error output on Error(...)")
 	}
 }
+
+func TestThrottledSink(t *testing.T) {
+	timing.TestMode()
+	ch := make(chan Output, 10)
+	sink := ThrottledSink(func(o Output) { ch <- o }, time.Second)
+	next := func() string {
+		select {
+		case out := <-ch:
+			txt, _ := out.Segments()[0].Content()
+			return txt
+		case <-time.After(time.Second):
+			require.Fail(t, "expected output")
+		}
+		return ""
+	}
+	assertNoOutput := func(msg string) {
+		select {
+		case <-ch:
+			require.Fail(t, "unexpected output", msg)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	start := timing.Now()
+	sink.Output(TextSegment("a"))
+	require.Equal(t, "a", next(), "first output is sent immediately")
+	sink.Output(TextSegment("b"))
+	sink.Output(TextSegment("c"))
+	assertNoOutput("within throttle interval")
+
+	require.Equal(t, start.Add(time.Second), timing.NextTick())
+	require.Equal(t, "c", next(), "latest output sent when interval elapses")
+	assertNoOutput("outputs are collapsed")
+
+	timing.AdvanceBy(5 * time.Second)
+	sink.Output(TextSegment("d"))
+	require.Equal(t, "d", next(), "output after a quiet period is immediate")
+
+	sink.Output(TextSegment("e"))
+	sink.Error(io.EOF)
+	select {
+	case out := <-ch:
+		require.Error(t, out.Segments()[0].GetError(), "errors sent immediately")
+	case <-time.After(time.Second):
+		require.Fail(t, "expected error output")
+	}
+	now := timing.Now()
+	require.Equal(t, now, timing.NextTick(), "pending output discarded by error")
+	assertNoOutput("after error")
+}
+
+func TestThrottledModule(t *testing.T) {
+	timing.TestMode()
+	ch := make(chan Output, 10)
+	m := ThrottledModule(StaticText("foo"), time.Second)
+	go m.Stream(func(o Output) { ch <- o })
+	select {
+	case out := <-ch:
+		txt, _ := out.Segments()[0].Content()
+		require.Equal(t, "foo", txt)
+	case <-time.After(time.Second):
+		require.Fail(t, "expected output from wrapped module")
+	}
+}
+
+// burstModule outputs all of its outputs at once, and returns.
+type burstModule []Output
+
+func (b burstModule) Stream(s Sink) {
+	for _, o := range b {
+		s.Output(o)
+	}
+}
+
+func TestThrottledModuleStops(t *testing.T) {
+	timing.TestMode()
+	ch := make(chan Output, 10)
+	m := ThrottledModule(burstModule{TextSegment("a"), TextSegment("b"), TextSegment("c")},
+		time.Second)
+	m.Stream(func(o Output) { ch <- o })
+	require.Len(t, ch, 2, "first output sent, and pending output sent on return")
+	<-ch
+	out := <-ch
+	txt, _ := out.Segments()[0].Content()
+	require.Equal(t, "c", txt, "latest output sent when Stream returns")
+	require.Empty(t, timing.AllStats(), "scheduler stopped when Stream returns")
+}
+
+func TestDedupSink(t *testing.T) {
+	ch := make(chan Output, 10)
+	sink := DedupSink(func(o Output) { ch <- o })
+	count := func() int {
+		n := len(ch)
+		for i := 0; i < n; i++ {
+			<-ch
+		}
+		return n
+	}
+
+	sink.Output(TextSegment("foo"))
+	require.Equal(t, 1, count(), "first output")
+	sink.Output(TextSegment("foo"))
+	require.Equal(t, 0, count(), "identical output")
+	sink.Output(TextSegment("foo").Urgent(true))
+	require.Equal(t, 1, count(), "different attributes")
+	sink.Output(TextSegment("foo").Urgent(true).OnClick(func(Event) {}))
+	require.Equal(t, 0, count(), "click handlers are not compared")
+	sink.Output(Forced(TextSegment("foo").Urgent(true)))
+	require.Equal(t, 1, count(), "forced output")
+
+	sink.Output(nil)
+	require.Equal(t, 1, count(), "nil output")
+	sink.Output(nil)
+	require.Equal(t, 0, count(), "repeated nil output")
+
+	sink.Error(io.EOF)
+	require.Equal(t, 1, count())
+	sink.Error(io.ErrUnexpectedEOF)
+	require.Equal(t, 1, count(), "different errors with the same segment")
+	sink.Error(io.ErrUnexpectedEOF)
+	require.Equal(t, 0, count(), "same error")
+
+	forced := Forced(TextSegment("baz"))
+	txt, _ := forced.Segments()[0].Content()
+	require.Equal(t, "baz", txt, "forced outputs work with other sinks")
+	require.Empty(t, Forced(nil).Segments())
+}
+
+func TestDedupModule(t *testing.T) {
+	ch := make(chan Output, 10)
+	m := DedupModule(StaticText("foo"))
+	go m.Stream(func(o Output) { ch <- o })
+	select {
+	case out := <-ch:
+		txt, _ := out.Segments()[0].Content()
+		require.Equal(t, "foo", txt)
+	case <-time.After(time.Second):
+		require.Fail(t, "expected output from wrapped module")
+	}
+}
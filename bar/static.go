@@ -0,0 +1,46 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+// staticModule is a module that displays a fixed output. It never
+// updates, and its Stream never returns.
+type staticModule struct {
+	output Output
+}
+
+func (s staticModule) Stream(sink Sink) {
+	sink.Output(s.output)
+	select {}
+}
+
+// StaticText constructs a module that always displays the given text.
+// It can be used to add labels or section dividers between other modules
+// without writing a module just for that.
+//
+// Static blocks do not participate in click routing: they have no click
+// handlers, so i3bar events on them are ignored. Since the module never
+// finishes, clicking on it will not restart it either.
+func StaticText(text string) Module {
+	return staticModule{TextSegment(text)}
+}
+
+// Spacer constructs a module that displays an empty block of (at least)
+// the given width in pixels, without any separator or padding. Like
+// StaticText, it does not handle click events.
+func Spacer(width int) Module {
+	return staticModule{
+		TextSegment(" ").MinWidth(width).Separator(false).Padding(0),
+	}
+}
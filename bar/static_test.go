@@ -0,0 +1,65 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func streamStatic(t *testing.T, m Module) *Segment {
+	ch := make(chan Output, 2)
+	go m.Stream(func(o Output) { ch <- o })
+	var out Output
+	select {
+	case out = <-ch:
+	case <-time.After(time.Second):
+		require.Fail(t, "expected output from static module")
+		return nil
+	}
+	select {
+	case <-ch:
+		require.Fail(t, "static module should output exactly once")
+	case <-time.After(10 * time.Millisecond):
+		// test passed.
+	}
+	segments := out.Segments()
+	require.Len(t, segments, 1)
+	return segments[0]
+}
+
+func TestStaticText(t *testing.T) {
+	s := streamStatic(t, StaticText("foo"))
+	txt, pango := s.Content()
+	require.Equal(t, "foo", txt)
+	require.False(t, pango)
+	require.False(t, s.HasClick(), "static text has no click handler")
+}
+
+func TestSpacer(t *testing.T) {
+	s := streamStatic(t, Spacer(20))
+	minWidth, isSet := s.GetMinWidth()
+	require.True(t, isSet)
+	require.Equal(t, 20, minWidth)
+	sep, isSet := s.HasSeparator()
+	require.True(t, isSet)
+	require.False(t, sep)
+	padding, isSet := s.GetPadding()
+	require.True(t, isSet)
+	require.Equal(t, 0, padding)
+	require.False(t, s.HasClick(), "spacer has no click handler")
+}
@@ -15,23 +15,23 @@
 package barista // import "barista.run"
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"image/color"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
-	"strconv"
 	"sync"
+	"time"
 
 	"barista.run/bar"
+	"barista.run/base/value"
 	"barista.run/core"
 	l "barista.run/logging"
 	"barista.run/oauth"
 	"barista.run/timing"
 
-	"github.com/lucasb-eyer/go-colorful"
 	"golang.org/x/sys/unix"
 )
 
@@ -69,12 +69,19 @@ type i3Bar struct {
 	writer io.Writer
 	// A json encoder set to write to the output stream.
 	encoder *json.Encoder
+	// How the header and each update are framed in the output stream.
+	framing FrameFormat
+	// The minimum interval between writes of the complete bar,
+	// or 0 to write the bar as soon as any module updates.
+	minUpdateInterval time.Duration
 	// Flipped when Run() is called, to prevent issues with modules
 	// being added after the bar has been started.
 	started bool
 	// Suppress pause/resume signal handling to workaround potential
 	// weirdness with signals.
 	suppressSignals bool
+	// Signal that forces all modules to re-render their output, if any.
+	refreshSignal os.Signal
 	// Keeps track of whether the bar is currently paused, and
 	// whether it needs to be refreshed on resume.
 	paused          bool
@@ -143,6 +150,71 @@ func SuppressSignals(suppressSignals bool) {
 	instance.suppressSignals = suppressSignals
 }
 
+// RefreshSignal sets a signal that forces modules to re-render their last
+// value, e.g. after changing the colour scheme. Modules that store their
+// output function in a value.OutputFunc, which includes the stock modules,
+// re-render by invoking it again; see value.Rerender. Other modules update on
+// their own schedule as usual.
+//
+// SIGUSR1 and SIGUSR2 are used to pause and resume the bar, and cannot be
+// used here unless signal handling is suppressed using SuppressSignals.
+// Must be called before Run.
+func RefreshSignal(sig os.Signal) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change refresh signal after .Run()")
+	}
+	if !instance.suppressSignals && (sig == unix.SIGUSR1 || sig == unix.SIGUSR2) {
+		panic("Cannot use pause/resume signal to refresh")
+	}
+	instance.refreshSignal = sig
+}
+
+// FrameFormat controls how the header and updates are written
+// to the output stream.
+type FrameFormat int
+
+const (
+	// Array is the i3bar protocol, where the header is followed by an
+	// infinite JSON array of updates. This is the default.
+	Array FrameFormat = iota
+	// NDJSON writes the header and each update as a line of JSON.
+	NDJSON
+	// LengthPrefixed writes the header and each update as JSON, prefixed
+	// by its length in bytes as a 4-byte big-endian unsigned integer.
+	LengthPrefixed
+)
+
+// Framing sets the format used to frame the header and updates in the
+// output stream, for compatibility with alternative bar frontends.
+// Must be called before Run.
+func Framing(framing FrameFormat) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change framing after .Run()")
+	}
+	instance.framing = framing
+}
+
+// MaxUpdateRate limits the bar to at most one update per interval. Module
+// updates within the interval are coalesced, and the latest output of all
+// modules is written at the end of the interval. If the bar has not been
+// updated in the last interval, updates are written immediately, so an idle
+// bar still responds quickly. Must be called before Run.
+func MaxUpdateRate(per time.Duration) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change update rate after .Run()")
+	}
+	instance.minUpdateInterval = per
+}
+
 // SetErrorHandler sets the function to be called when an error segment
 // is right clicked. This replaces the DefaultErrorHandler.
 func SetErrorHandler(handler func(bar.ErrorEvent)) {
@@ -172,9 +244,17 @@ func Run(modules ...bar.Module) error {
 		signalChan = make(chan os.Signal, 2)
 		signal.Notify(signalChan, unix.SIGUSR1, unix.SIGUSR2)
 	}
+	var refreshChan chan os.Signal
+	if b.refreshSignal != nil {
+		refreshChan = make(chan os.Signal, 1)
+		signal.Notify(refreshChan, b.refreshSignal)
+	}
 
 	b.modules = append(b.modules, modules...)
 	b.moduleSet = core.NewModuleSet(b.modules)
+	// Stop modules that support it when the bar exits, e.g. to terminate
+	// any commands that they started.
+	defer b.moduleSet.Close()
 
 	// Mark the bar as started.
 	b.started = true
@@ -207,25 +287,51 @@ func Run(modules ...bar.Module) error {
 	// Set up the encoder for the output stream,
 	// so that module outputs can be written directly.
 	b.encoder = json.NewEncoder(b.writer)
-	if err := b.encoder.Encode(&header); err != nil {
+	if err := b.writeFrame(&header); err != nil {
 		return err
 	}
-	// Start the infinite array.
-	if _, err := io.WriteString(b.writer, "["); err != nil {
-		return err
+	if b.framing == Array {
+		// Start the infinite array.
+		if _, err := io.WriteString(b.writer, "["); err != nil {
+			return err
+		}
 	}
 
 	// Bar starts paused, so resume it to get the initial output.
 	b.resume()
 
+	// When rate limited, updates received too soon after the previous write
+	// are deferred until the throttle triggers.
+	throttle := timing.NewScheduler()
+	var nextPrint time.Time
+	var deferred bool
+
 	// Infinite arrays on both sides.
 	for {
 		select {
 		case <-b.update:
+			if deferred {
+				continue
+			}
+			if b.minUpdateInterval > 0 {
+				now := timing.Now()
+				if now.Before(nextPrint) {
+					deferred = true
+					throttle.At(nextPrint)
+					continue
+				}
+				nextPrint = now.Add(b.minUpdateInterval)
+			}
 			// The complete bar needs to printed on each update.
 			if err := b.print(); err != nil {
 				return err
 			}
+		case <-throttle.Tick():
+			deferred = false
+			nextPrint = timing.Now().Add(b.minUpdateInterval)
+			if err := b.print(); err != nil {
+				return err
+			}
 		case event := <-b.events:
 			if onClick, ok := b.clickHandlers[event.Name]; ok {
 				go onClick(event.Event)
@@ -237,6 +343,9 @@ func Run(modules ...bar.Module) error {
 			case unix.SIGUSR2:
 				b.resume()
 			}
+		case <-refreshChan:
+			l.Log("Bar refreshed")
+			value.Rerender()
 		case err := <-errChan:
 			return err
 		}
@@ -248,93 +357,53 @@ func DefaultErrorHandler(e bar.ErrorEvent) {
 	exec.Command("i3-nagbar", "-m", e.Error.Error()).Run()
 }
 
-func colorString(c color.Color) string {
-	cful, _ := colorful.MakeColor(c)
-	return cful.Hex()
-}
-
-// i3map serialises the attributes of the Segment in
-// the format used by i3bar.
-func i3map(s *bar.Segment) map[string]interface{} {
-	i3map := make(map[string]interface{})
-	txt, pango := s.Content()
-	i3map["full_text"] = txt
-	if shortText, ok := s.GetShortText(); ok {
-		i3map["short_text"] = shortText
-	}
-	if color, ok := s.GetColor(); ok {
-		i3map["color"] = colorString(color)
-	}
-	if background, ok := s.GetBackground(); ok {
-		i3map["background"] = colorString(background)
-	}
-	if border, ok := s.GetBorder(); ok {
-		i3map["border"] = colorString(border)
-	}
-	if minWidth, ok := s.GetMinWidth(); ok {
-		i3map["min_width"] = minWidth
-	}
-	if align, ok := s.GetAlignment(); ok {
-		i3map["align"] = align
-	}
-	if urgent, ok := s.IsUrgent(); ok {
-		i3map["urgent"] = urgent
-	}
-	if separator, ok := s.HasSeparator(); ok {
-		i3map["separator"] = separator
-	}
-	if padding, ok := s.GetPadding(); ok {
-		i3map["separator_block_width"] = padding
-	}
-	if pango {
-		i3map["markup"] = "pango"
-	} else {
-		i3map["markup"] = "none"
-	}
-	return i3map
-}
-
 // print outputs the entire bar, using the last output for each module.
 func (b *i3Bar) print() error {
 	// Store the set of click handlers for any segments that can handle clicks.
 	// When i3bar sends us the click event, it will include an identifier that
 	// we can use to look up the function to call.
-	b.clickHandlers = map[string]func(bar.Event){}
 	// i3bar requires the entire bar to be printed at once, so we just take the
 	// last cached value for each module and construct the current bar.
+	lastOutputs := b.moduleSet.LastOutputs()
+	names, handlers := core.ClickHandlers(lastOutputs, b.errorHandler)
+	b.clickHandlers = handlers
 	output := make([]map[string]interface{}, 0)
-	for _, segments := range b.moduleSet.LastOutputs() {
+	for _, segments := range lastOutputs {
 		for _, segment := range segments {
-			out := i3map(segment)
-			var clickHandler func(bar.Event)
-			if err := segment.GetError(); err != nil {
-				// because go.
-				segment := segment
-				clickHandler = func(e bar.Event) {
-					if e.Button == bar.ButtonRight {
-						b.errorHandler(bar.ErrorEvent{err, e})
-					} else {
-						segment.Click(e)
-					}
-				}
-			} else if segment.HasClick() {
-				clickHandler = segment.Click
-			}
-			if clickHandler != nil {
-				name := strconv.Itoa(len(b.clickHandlers))
+			out := segment.I3Map()
+			if name := names[len(output)]; name != "" {
 				out["name"] = name
-				b.clickHandlers[name] = clickHandler
 			}
 			output = append(output, out)
 		}
 	}
-	if err := b.encoder.Encode(output); err != nil {
+	if err := b.writeFrame(output); err != nil {
 		return err
 	}
+	if b.framing != Array {
+		return nil
+	}
 	_, err := io.WriteString(b.writer, ",\n")
 	return err
 }
 
+// writeFrame writes a single value to the output stream,
+// framed according to the configured format.
+func (b *i3Bar) writeFrame(v interface{}) error {
+	if b.framing != LengthPrefixed {
+		// Both the i3bar protocol and NDJSON use newline-terminated JSON.
+		return b.encoder.Encode(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	_, err = b.writer.Write(append(frame, data...))
+	return err
+}
+
 // readEvents parses the infinite stream of events received from i3.
 func (b *i3Bar) readEvents() error {
 	decoder := json.NewDecoder(b.reader)
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/value"
 	"barista.run/outputs"
 	"barista.run/testing/mockio"
 	testModule "barista.run/testing/module"
@@ -635,7 +636,7 @@ type segmentAssertions struct {
 
 func (s segmentAssertions) AssertEqual(message string) {
 	actualMap := make(map[string]string)
-	for k, v := range i3map(s.actual) {
+	for k, v := range s.actual.I3Map() {
 		actualMap[k] = fmt.Sprintf("%v", v)
 	}
 	require.Equal(s.T, s.Expected, actualMap, message)
@@ -710,3 +711,138 @@ func TestI3Map(t *testing.T) {
 	a.Expected["urgent"] = "false"
 	a.AssertEqual("urgent = false")
 }
+
+func TestFraming(t *testing.T) {
+	readAll := func(stdout *mockio.Writable) string {
+		var out string
+		for stdout.WaitForWrite(50 * time.Millisecond) {
+			out += stdout.ReadNow()
+		}
+		return out
+	}
+	header := `{"version":1,"stop_signal":10,"cont_signal":12,"click_events":true}`
+	update := `[{"full_text":"test","markup":"none"}]`
+	lengthPrefixed := func(s string) string {
+		return string([]byte{0, 0, 0, byte(len(s))}) + s
+	}
+
+	for _, tc := range []struct {
+		framing  FrameFormat
+		expected string
+	}{
+		{Array, header + "\n[" + update + "\n,\n"},
+		{NDJSON, header + "\n" + update + "\n"},
+		{LengthPrefixed, lengthPrefixed(header) + lengthPrefixed(update)},
+	} {
+		mockStdin := mockio.Stdin()
+		mockStdout := mockio.Stdout()
+		TestMode(mockStdin, mockStdout)
+		Framing(tc.framing)
+		instance.includeErrorsInOutput = false
+
+		module := testModule.New(t).SkipClickHandlers()
+		go Run(module)
+		module.AssertStarted()
+		module.Output(bar.TextSegment("test"))
+
+		require.Equal(t, tc.expected, readAll(mockStdout),
+			"output for framing %d", tc.framing)
+		require.Panics(t, func() { Framing(Array) },
+			"changing framing of a running bar")
+	}
+}
+
+func TestMaxUpdateRate(t *testing.T) {
+	timing.TestMode()
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	MaxUpdateRate(time.Second)
+
+	module1 := testModule.New(t)
+	module2 := testModule.New(t)
+	runErr := make(chan error)
+	go func() { runErr <- Run(module1, module2) }()
+
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.Nil(t, err, "output array started without any errors")
+	module1.AssertStarted()
+	module2.AssertStarted()
+
+	module1.OutputText("a")
+	out := readOutputTexts(t, mockStdout)
+	require.Equal(t, []string{"a"}, out, "first update is written immediately")
+
+	module1.OutputText("b")
+	module2.OutputText("c")
+	module1.OutputText("d")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"No output within interval, got %s", mockStdout.ReadNow())
+
+	start := timing.Now()
+	require.Equal(t, time.Second, timing.NextTick().Sub(start),
+		"deferred update is scheduled for the end of the interval")
+	out = readOutputTexts(t, mockStdout)
+	require.Equal(t, []string{"d", "c"}, out, "latest outputs are written")
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"Coalesced updates are written once, got %s", mockStdout.ReadNow())
+
+	timing.AdvanceBy(5 * time.Second)
+	module2.OutputText("e")
+	out = readOutputTexts(t, mockStdout)
+	require.Equal(t, []string{"d", "e"}, out, "updates are immediate when idle")
+
+	require.Panics(t, func() { MaxUpdateRate(time.Minute) },
+		"changing update rate of a running bar")
+
+	// Stop the bar before leaving test mode, since it uses timing.Now().
+	mockStdin.ShouldError(errors.New("done"))
+	require.Error(t, <-runErr)
+	timing.ExitTestMode()
+}
+
+type renderModule struct {
+	outputFunc value.OutputFunc[string]
+}
+
+func (r *renderModule) Stream(s bar.Sink) {
+	for {
+		next := r.outputFunc.Next()
+		s.Output(r.outputFunc.Get()("render"))
+		<-next
+	}
+}
+
+func TestRefreshSignal(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	require.Panics(t, func() { RefreshSignal(unix.SIGUSR1) },
+		"pause signal cannot be used for refresh")
+	RefreshSignal(unix.SIGHUP)
+
+	renders := 0
+	module := &renderModule{}
+	module.outputFunc.Set(func(s string) bar.Output {
+		renders++
+		return outputs.Textf("%s %d", s, renders)
+	})
+	go Run(module)
+
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.Nil(t, err, "output array started without any errors")
+	out := readOutputTexts(t, mockStdout)
+	require.Equal(t, []string{"render 1"}, out, "initial output")
+
+	unix.Kill(unix.Getpid(), unix.SIGHUP)
+	out = readOutputTexts(t, mockStdout)
+	require.Equal(t, []string{"render 2"}, out, "re-rendered on signal")
+
+	require.Panics(t, func() { RefreshSignal(unix.SIGHUP) },
+		"changing refresh signal of a running bar")
+
+	TestMode(mockio.Stdin(), mockio.Stdout())
+	SuppressSignals(true)
+	require.NotPanics(t, func() { RefreshSignal(unix.SIGUSR1) },
+		"pause signal can be used when signals are suppressed")
+}
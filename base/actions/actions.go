@@ -0,0 +1,188 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package actions provides a registry of named actions, which can be invoked by
+click handlers and by external clients over a unix socket.
+
+This allows the same action to be bound to both a click on the bar and a
+keybinding in the window manager, e.g. for a socket at
+$XDG_RUNTIME_DIR/barista.sock:
+
+	actions.Register("media.next", player.Next)
+	actions.Listen(filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "barista.sock"))
+
+and in the i3 config:
+
+	bindsym XF86AudioNext exec echo media.next | socat - UNIX-CONNECT:$XDG_RUNTIME_DIR/barista.sock
+
+The socket protocol is line-based: each line received is the name of an action
+to invoke, and each line is answered with either "ok" once the action has
+completed, or "error: " followed by a description of the error.
+*/
+package actions // import "barista.run/base/actions"
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+var (
+	registry   = map[string]func(){}
+	registryMu sync.RWMutex
+)
+
+// Register registers an action with the given name, replacing any existing
+// action with the same name. Names are typically of the form "module.action",
+// e.g. "volume.up", and cannot contain whitespace.
+func Register(name string, action func()) {
+	if strings.ContainsAny(name, " \t\r\n") {
+		panic(fmt.Sprintf("actions: invalid name %q", name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = action
+}
+
+// Unregister removes the action with the given name, if any.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+// Names returns the names of all registered actions, in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnknownActionError is returned when invoking an action that is not
+// registered.
+type UnknownActionError string
+
+func (e UnknownActionError) Error() string {
+	return fmt.Sprintf("unknown action %q", string(e))
+}
+
+// Invoke invokes the action with the given name, and returns once it has
+// completed. If the action panics, the panic is returned as an error.
+func Invoke(name string) (err error) {
+	registryMu.RLock()
+	action, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return UnknownActionError(name)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	action()
+	return nil
+}
+
+// Handler returns a click handler that invokes the named action, looked up
+// on each click so that the action can be registered or replaced later.
+func Handler(name string) func(bar.Event) {
+	return func(bar.Event) {
+		if err := Invoke(name); err != nil {
+			l.Log("Click handler: %v", err)
+		}
+	}
+}
+
+// Server accepts connections from external clients on a unix socket.
+type Server struct {
+	listener net.Listener
+	path     string
+}
+
+// Listen starts accepting connections on a unix socket at the given path.
+// A stale socket left behind by a previous instance is removed, but Listen
+// fails if another process is still listening on the socket, or if the path
+// exists and is not a socket.
+func Listen(path string) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("actions: %s is in use", path)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("actions: %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener, path: path}
+	l.Label(s, path)
+	l.Fine("%s listening on %s", l.ID(s), path)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops accepting connections and removes the socket.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			l.Fine("%s stopped: %v", l.ID(s), err)
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
+		}
+		l.Fine("%s invoking %s", l.ID(s), name)
+		reply := "ok\n"
+		if err := Invoke(name); err != nil {
+			reply = fmt.Sprintf("error: %v\n", err)
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
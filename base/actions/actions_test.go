@@ -0,0 +1,142 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	var next, prev int32
+	Register("media.next", func() { atomic.AddInt32(&next, 1) })
+	Register("media.prev", func() { atomic.AddInt32(&prev, 1) })
+	defer Unregister("media.next")
+	defer Unregister("media.prev")
+
+	require.Equal(t, []string{"media.next", "media.prev"}, Names())
+	require.NoError(t, Invoke("media.next"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&next))
+
+	Handler("media.prev")(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, int32(1), atomic.LoadInt32(&prev), "click handler invokes action")
+
+	err := Invoke("volume.up")
+	require.Equal(t, UnknownActionError("volume.up"), err)
+	require.NotPanics(t, func() { Handler("volume.up")(bar.Event{}) },
+		"click handler for unknown action")
+
+	Unregister("media.prev")
+	require.Error(t, Invoke("media.prev"), "after unregistering")
+	require.Panics(t, func() { Register("media next", func() {}) },
+		"name with whitespace")
+
+	Register("media.panic", func() { panic("no player") })
+	defer Unregister("media.panic")
+	require.EqualError(t, Invoke("media.panic"), "panic: no player")
+	require.NotPanics(t, func() { Handler("media.panic")(bar.Event{}) },
+		"click handler for panicking action")
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "barista.sock")
+
+	var up int32
+	Register("volume.up", func() { atomic.AddInt32(&up, 1) })
+	defer Unregister("volume.up")
+
+	s, err := Listen(path)
+	require.NoError(t, err)
+
+	_, err = Listen(path)
+	require.Error(t, err, "socket in use")
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	replies := bufio.NewScanner(conn)
+	send := func(cmd string) string {
+		_, err := conn.Write([]byte(cmd + "\n"))
+		require.NoError(t, err)
+		require.True(t, replies.Scan(), "reply to %q", cmd)
+		return replies.Text()
+	}
+
+	require.Equal(t, "ok", send("volume.up"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&up), "action completed before reply")
+	require.Equal(t, "ok", send("  volume.up\r"), "surrounding whitespace")
+	require.Equal(t, int32(2), atomic.LoadInt32(&up))
+	require.Equal(t, `error: unknown action "volume.down"`, send("volume.down"))
+
+	Register("volume.panic", func() { panic("mixer gone") })
+	defer Unregister("volume.panic")
+	require.Equal(t, "error: panic: mixer gone", send("volume.panic"))
+	require.Equal(t, "ok", send("volume.up"), "connection survives a panic")
+
+	require.NoError(t, s.Close())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "socket removed on close")
+}
+
+func TestStaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "barista.sock")
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	Register("test", func() {})
+	defer Unregister("test")
+	s, err := Listen(path)
+	require.NoError(t, err, "stale socket is replaced")
+	defer s.Close()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte("\ntest\n"))
+	replies := bufio.NewScanner(conn)
+	require.True(t, replies.Scan())
+	require.Equal(t, "ok", replies.Text(), "empty lines are ignored")
+}
+
+func TestNotASocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "barista.sock")
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+
+	_, err = Listen(path)
+	require.Error(t, err, "regular file at socket path")
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(contents), "file is not removed")
+}
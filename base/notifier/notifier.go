@@ -23,7 +23,10 @@ is preferable to apply just the final format, ignoring the intermediate ones.
 package notifier // import "barista.run/base/notifier"
 
 import (
+	"time"
+
 	l "barista.run/logging"
+	"barista.run/timing"
 )
 
 // New constructs a new notifier. It returns a func that triggers a notification,
@@ -40,3 +43,20 @@ func notify(ch chan<- struct{}) {
 	default:
 	}
 }
+
+// Debounced constructs a notifier that coalesces bursts of notifications.
+// Each call to the returned func delays the notification, which is sent once
+// there have been no calls for the given duration. This guarantees a trailing
+// notification after the last call in a burst. Delays use timing schedulers,
+// so they are controlled by the test clock in tests.
+func Debounced(d time.Duration) (func(), <-chan struct{}) {
+	fn, ch := New()
+	sch := timing.NewScheduler()
+	l.Attach(ch, sch, "debounce")
+	go func() {
+		for range sch.Tick() {
+			fn()
+		}
+	}()
+	return func() { sch.After(d) }, ch
+}
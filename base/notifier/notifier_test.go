@@ -19,6 +19,8 @@ import (
 	"testing"
 	"time"
 
+	"barista.run/timing"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -102,3 +104,27 @@ func TestWait(t *testing.T) {
 		require.Fail(t, "wait did not complete")
 	}
 }
+
+func TestDebounced(t *testing.T) {
+	timing.TestMode()
+	fn, n := Debounced(time.Second)
+	start := timing.Now()
+	assertNoTick(t, n, "when not notified")
+
+	fn()
+	timing.AdvanceBy(500 * time.Millisecond)
+	fn()
+	timing.AdvanceBy(500 * time.Millisecond)
+	assertNoTick(t, n, "before quiescence")
+	fn()
+	assertNoTick(t, n, "during burst")
+
+	require.Equal(t, start.Add(2*time.Second), timing.NextTick(),
+		"notifies after the last call")
+	assertTick(t, n, "trailing notification")
+	assertNoTick(t, n, "bursts are coalesced")
+
+	fn()
+	timing.AdvanceBy(time.Second)
+	assertTick(t, n, "on next burst")
+}
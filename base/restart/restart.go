@@ -0,0 +1,157 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restart provides a wrapper that automatically restarts a module
+// when its Stream returns, e.g. after an error.
+package restart // import "barista.run/base/restart"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// Module wraps a bar.Module, restarting it with an exponential backoff
+// whenever its Stream returns. While waiting to restart, the last output of
+// the wrapped module is shown, and clicking on it restarts the module
+// immediately.
+type Module struct {
+	module  bar.Module
+	backoff value.TypedValue[backoff]
+}
+
+// backoff holds the range of delays before restarting the module.
+type backoff struct {
+	min, max time.Duration
+}
+
+// New wraps a module so that it is restarted when it stops, using a backoff
+// between 1 second and 1 minute. See Backoff.
+func New(m bar.Module) *Module {
+	r := &Module{module: m}
+	l.Attach(m, r, "~restart")
+	r.backoff.Set(backoff{time.Second, time.Minute})
+	return r
+}
+
+// Backoff sets the delays before restarting the module. The module is
+// restarted after min, and the delay doubles on each consecutive restart up
+// to max. Once the module runs for at least max, the delay is reset to min.
+// Changes apply the next time the module is streamed.
+func (m *Module) Backoff(min, max time.Duration) *Module {
+	m.backoff.Set(backoff{min, max})
+	return m
+}
+
+// errStopped is shown while waiting to restart a module that stopped without
+// any output.
+var errStopped = errors.New("module stopped, click to retry")
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.StreamContext(context.Background(), s)
+}
+
+// StreamContext starts the module, and returns once the context is done.
+// The wrapped module receives the context if it is a bar.ContextModule, and
+// is never restarted after it returns because the context is done.
+func (m *Module) StreamContext(ctx context.Context, s bar.Sink) {
+	b := m.backoff.Get()
+	sch := timing.NewBackoffScheduler(b.min, b.max)
+	l.Attach(m, sch, "backoff")
+	sch.Stop()
+	defer sch.Stop()
+	retryFn, retryCh := notifier.New()
+	restarted := false
+	var last bar.Segments
+	for {
+		started := timing.Now()
+		last = m.stream(ctx, s, last)
+		if ctx.Err() != nil {
+			return
+		}
+		// Reset the delay if the module ran for a while, otherwise back
+		// off exponentially.
+		if !restarted || timing.Now().Sub(started) >= b.max {
+			sch.Success()
+		} else {
+			sch.Failure()
+		}
+		restarted = true
+		l.Log("%s stopped, restarting in %v", l.ID(m), sch.Interval())
+		// Discard retries from clicks during a previous wait.
+		select {
+		case <-retryCh:
+		default:
+		}
+		s.Output(retryOutput(last, retryFn))
+		select {
+		case <-sch.Tick():
+		case <-retryCh:
+			sch.Stop()
+		case <-ctx.Done():
+			return
+		}
+		l.Fine("%s restarted", l.ID(m))
+	}
+}
+
+// stream runs the wrapped module until it returns, and returns the last
+// output it sent, or the given output if it did not send any.
+func (m *Module) stream(ctx context.Context, s bar.Sink, last bar.Segments) bar.Segments {
+	var mu sync.Mutex
+	sink := func(o bar.Output) {
+		var segments bar.Segments
+		if o != nil {
+			segments = o.Segments()
+		}
+		mu.Lock()
+		last = segments
+		mu.Unlock()
+		s.Output(o)
+	}
+	if cm, ok := m.module.(bar.ContextModule); ok {
+		cm.StreamContext(ctx, sink)
+	} else {
+		m.module.Stream(sink)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return last
+}
+
+// retryOutput returns the output shown while waiting to restart the module,
+// where a left, right, or middle click restarts the module immediately.
+func retryOutput(last bar.Segments, retryFn func()) bar.Output {
+	if len(last) == 0 {
+		last = bar.Segments{bar.ErrorSegment(errStopped)}
+	}
+	var out bar.Segments
+	for _, s := range last {
+		out = append(out, s.Clone().OnClick(func(e bar.Event) {
+			switch e.Button {
+			case bar.ButtonLeft, bar.ButtonRight, bar.ButtonMiddle:
+				retryFn()
+			}
+		}))
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restart
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/sink"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestart(t *testing.T) {
+	testBar.New(t)
+	tm := testModule.New(t)
+	r := New(tm).Backoff(time.Second, time.Minute)
+	testBar.Run(r)
+
+	tm.AssertStarted()
+	tm.OutputText("foo")
+	testBar.NextOutput().AssertText([]string{"foo"})
+
+	start := timing.Now()
+	tm.Close()
+	testBar.NextOutput("while waiting to restart").AssertText([]string{"foo"})
+	tm.AssertNotStarted("before backoff")
+	require.Equal(t, time.Second, timing.NextTick().Sub(start))
+	tm.AssertStarted("after backoff")
+
+	start = timing.Now()
+	tm.Close()
+	testBar.NextOutput("while waiting to restart").AssertText([]string{"foo"})
+	require.Equal(t, 2*time.Second, timing.NextTick().Sub(start),
+		"backoff doubles on consecutive restarts")
+	tm.AssertStarted("after backoff")
+
+	timing.AdvanceBy(time.Hour)
+	start = timing.Now()
+	tm.Close()
+	testBar.NextOutput("while waiting to restart").AssertText([]string{"foo"})
+	require.Equal(t, time.Second, timing.NextTick().Sub(start),
+		"backoff is reset after running for a while")
+	tm.AssertStarted("after backoff")
+
+	tm.Close()
+	testBar.NextOutput("while waiting to restart").AssertText([]string{"foo"})
+	testBar.SendEvent(0, bar.Event{Button: bar.ScrollUp})
+	tm.AssertNotStarted("on scroll")
+	testBar.SendClick(0)
+	tm.AssertStarted("on click")
+	testBar.AssertNoOutput("on restart")
+
+	tm.Output(nil)
+	testBar.NextOutput().AssertEmpty()
+	tm.Close()
+	errs := testBar.NextOutput("stopped without output").AssertError()
+	require.Equal(t, []string{errStopped.Error()}, errs)
+	testBar.SendClick(0)
+	tm.AssertStarted("on click")
+}
+
+type contextModule struct{ streams chan bool }
+
+func (c *contextModule) Stream(s bar.Sink) {
+	c.StreamContext(context.Background(), s)
+}
+
+func (c *contextModule) StreamContext(ctx context.Context, s bar.Sink) {
+	c.streams <- true
+	<-ctx.Done()
+}
+
+func TestContext(t *testing.T) {
+	testBar.New(t)
+	c := &contextModule{make(chan bool, 10)}
+	r := New(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	outs, s := sink.Buffered(10)
+	returned := make(chan struct{})
+	go func() {
+		r.StreamContext(ctx, s)
+		close(returned)
+	}()
+
+	<-c.streams
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		require.Fail(t, "StreamContext did not return on cancel")
+	}
+	require.Empty(t, outs, "no retry output on cancel")
+	require.Empty(t, c.streams, "module is not restarted on cancel")
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+// OutputFunc stores a module's output function, providing the same atomic
+// storage and update notifications as Value, without the need for type
+// assertions when retrieving the function. The zero value is ready to use.
+//
+// Subscribers are also notified by Rerender, even though the function has not
+// changed, so that modules re-invoke it with their last value.
+type OutputFunc[T any] struct {
+	v Value
+	// The channel returned by Next, shared by all callers until the next
+	// update or rerender, so that repeated calls do not each add a
+	// subscriber and a goroutine.
+	nextMu sync.Mutex
+	next   chan struct{}
+}
+
+// Set updates the stored output function and notifies any subscribers.
+func (o *OutputFunc[T]) Set(outputFunc func(T) bar.Output) {
+	o.v.Set(outputFunc)
+}
+
+// Get returns the currently stored output function,
+// or nil if none has been set.
+func (o *OutputFunc[T]) Get() func(T) bar.Output {
+	f, _ := o.v.Get().(func(T) bar.Output)
+	return f
+}
+
+// Next returns a channel that will be closed on the next update,
+// or on the next call to Rerender.
+func (o *OutputFunc[T]) Next() <-chan struct{} {
+	o.nextMu.Lock()
+	defer o.nextMu.Unlock()
+	if o.next != nil {
+		select {
+		case <-o.next:
+		default:
+			return o.next
+		}
+	}
+	ch := make(chan struct{})
+	next := o.v.Next()
+	all := rerender.Next()
+	go func() {
+		select {
+		case <-next:
+		case <-all:
+		}
+		close(ch)
+	}()
+	o.next = ch
+	return ch
+}
+
+// SafeOutput calls an output function with the given value, recovering from
+// any panic in it. The panic is logged with its stack trace, and the output is
+// an error segment instead, so that a module can keep running and render its
+// next value, rather than stopping because of a bug in its output function.
+func SafeOutput[T any](outputFunc func(T) bar.Output, value T) (out bar.Output) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.Log("output function panicked: %v\n%s", r, debug.Stack())
+			out = bar.ErrorSegment(fmt.Errorf("output function panicked: %v", r))
+		}
+	}()
+	return outputFunc(value)
+}
+
+// rerender is used to notify all OutputFunc subscribers at once.
+var rerender Value
+
+// Rerender notifies all subscribers of every OutputFunc, causing modules that
+// store their output function in one to render their last value again, e.g.
+// after the colour scheme or timezone changes. Other modules, including those
+// that store their output function in a plain Value, are not affected.
+func Rerender() {
+	rerender.Set(struct{}{})
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputFunc(t *testing.T) {
+	require := require.New(t)
+	var o OutputFunc[int]
+
+	require.NotPanics(func() { o.Get() }, "Without a function set")
+	require.Nil(o.Get(), "Unset function returns nil")
+
+	o.Set(func(i int) bar.Output { return outputs.Textf("%d", i) })
+	txt, _ := o.Get()(42).Segments()[0].Content()
+	require.Equal("42", txt)
+}
+
+func TestOutputFuncUpdate(t *testing.T) {
+	require := require.New(t)
+	var o OutputFunc[string]
+	o.Set(func(s string) bar.Output { return outputs.Text(s) })
+
+	first := o.Next()
+	second := o.Next()
+	select {
+	case <-first:
+		require.Fail("Notified without an update")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	o.Set(func(s string) bar.Output { return outputs.Text(s + "!") })
+	for _, ch := range []<-chan struct{}{first, second} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			require.Fail("Not notified of update")
+		}
+	}
+	txt, _ := o.Get()("hi").Segments()[0].Content()
+	require.Equal("hi!", txt, "Get returns the latest function")
+
+	next := o.Next()
+	select {
+	case <-next:
+		require.Fail("Notification channels are single use")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestRerender(t *testing.T) {
+	var a OutputFunc[int]
+	var b OutputFunc[string]
+	var plain Value
+	nextA, nextB, nextPlain := a.Next(), b.Next(), plain.Next()
+	require.Equal(t, nextA, a.Next(),
+		"Next is shared until the next update or rerender")
+
+	Rerender()
+	for _, ch := range []<-chan struct{}{nextA, nextB} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			require.Fail(t, "Not notified on rerender")
+		}
+	}
+	select {
+	case <-nextPlain:
+		require.Fail(t, "Plain values are not notified on rerender")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.Nil(t, a.Get(), "Rerender does not change the function")
+	require.NotEqual(t, nextA, a.Next(), "New channel after rerender")
+}
+
+func TestSafeOutput(t *testing.T) {
+	double := func(i int) bar.Output { return outputs.Textf("%d", i*2) }
+	txt, _ := SafeOutput(double, 21).Segments()[0].Content()
+	require.Equal(t, "42", txt)
+
+	var m map[string]int
+	panicky := func(k string) bar.Output {
+		m[k] = 1
+		return outputs.Text(k)
+	}
+	var out bar.Output
+	require.NotPanics(t, func() { out = SafeOutput(panicky, "foo") })
+	err := out.Segments()[0].GetError()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output function panicked")
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+// TypedValue provides the same atomic storage and update notifications as
+// Value, for values of a single type, without the need for type assertions
+// when retrieving the value. Unlike Value, it can store nil values of
+// pointer, func, or interface types. The zero value is ready to use, and
+// returns the zero value of T until the first Set.
+type TypedValue[T any] struct {
+	v Value // of box[T]
+}
+
+// box wraps values so that the stored type is always the same, even if T is
+// an interface type, and so that nil values can be stored.
+type box[T any] struct {
+	value T
+}
+
+// Set updates the stored value and notifies any subscribers.
+func (t *TypedValue[T]) Set(value T) {
+	t.v.Set(box[T]{value})
+}
+
+// Get returns the currently stored value.
+func (t *TypedValue[T]) Get() T {
+	b, _ := t.v.Get().(box[T])
+	return b.value
+}
+
+// Next returns a channel that will be closed on the next update.
+func (t *TypedValue[T]) Next() <-chan struct{} {
+	return t.v.Next()
+}
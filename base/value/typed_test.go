@@ -0,0 +1,102 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedValue(t *testing.T) {
+	require := require.New(t)
+	var v TypedValue[int]
+	require.Equal(0, v.Get(), "zero value before Set")
+	v.Set(42)
+	require.Equal(42, v.Get())
+
+	var f TypedValue[func() string]
+	require.Nil(f.Get())
+	f.Set(func() string { return "foo" })
+	require.Equal("foo", f.Get()())
+	require.NotPanics(func() { f.Set(nil) }, "storing nil func")
+	require.Nil(f.Get())
+
+	var e TypedValue[error]
+	e.Set(errors.New("foo"))
+	require.NotPanics(func() { e.Set(&errorWithPtr{}) },
+		"storing different concrete types of an interface")
+	require.NotPanics(func() { e.Set(nil) }, "storing nil interface")
+	require.NoError(e.Get())
+}
+
+type errorWithPtr struct{}
+
+func (*errorWithPtr) Error() string { return "error" }
+
+func TestTypedValueUpdate(t *testing.T) {
+	var v TypedValue[string]
+	ch := v.Next()
+	select {
+	case <-ch:
+		require.Fail(t, "<-Next() triggered without a Set(...)")
+	case <-time.After(10 * time.Millisecond):
+	}
+	v.Set("foo")
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		require.Fail(t, "<-Next() not notified within 1s")
+	}
+}
+
+func TestTypedValueConcurrent(t *testing.T) {
+	var v TypedValue[[]int]
+	v.Set([]int{0})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				v.Set([]int{i, j})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				<-v.Next()
+				require.NotEmpty(t, v.Get(), "concurrent Get returns a set value")
+			}
+		}()
+	}
+	// Keep notifying until all readers are done.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+			v.Set([]int{-1})
+		}
+	}
+}
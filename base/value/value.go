@@ -16,10 +16,13 @@
 package value // import "barista.run/base/value"
 
 import (
+	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	l "barista.run/logging"
+	"barista.run/timing"
 )
 
 // Value provides atomic value storage with update notifications.
@@ -28,6 +31,20 @@ type Value struct {
 	// Observers that will be notified on the next value.
 	obs   []chan struct{}
 	obsMu sync.Mutex
+	// For coalescing values, the interval over which updates are coalesced,
+	// whether a notification is pending, and the scheduler used to deliver
+	// it, created on first use. Protected by obsMu.
+	coalesce time.Duration
+	pending  bool
+	sch      timing.Scheduler
+}
+
+// NewCoalescing creates a Value that coalesces bursts of updates. Subscribers
+// are notified once, with the latest value, at the end of an interval of the
+// given duration starting with the first update in a burst. Updates made
+// while there are no subscribers do not wait for the interval.
+func NewCoalescing(min time.Duration) *Value {
+	return &Value{coalesce: min}
 }
 
 // Next returns a channel that will be closed on the next update.
@@ -51,6 +68,29 @@ func (v *Value) Set(value interface{}) {
 	l.Fine("%s: Store %#v", l.ID(v), value)
 	v.obsMu.Lock()
 	defer v.obsMu.Unlock()
+	if v.coalesce <= 0 {
+		v.notify()
+		return
+	}
+	if v.pending || len(v.obs) == 0 {
+		return
+	}
+	v.pending = true
+	if v.sch == nil {
+		v.sch = timing.NewScheduler()
+	}
+	sch := v.sch.After(v.coalesce)
+	go func() {
+		<-sch.Tick()
+		v.obsMu.Lock()
+		defer v.obsMu.Unlock()
+		v.pending = false
+		v.notify()
+	}()
+}
+
+// notify notifies all subscribers. Must be called with obsMu held.
+func (v *Value) notify() {
 	for _, o := range v.obs {
 		close(o)
 	}
@@ -67,6 +107,8 @@ type valueOrErr struct {
 type ErrorValue struct {
 	v       Value // of valueOrErr
 	logInit sync.Once
+	// setMu serialises updates, so that SetError can detect changes.
+	setMu sync.Mutex
 }
 
 func (e *ErrorValue) initLogging() {
@@ -93,6 +135,8 @@ func (e *ErrorValue) Get() (interface{}, error) {
 // Set updates the stored value and clears any error.
 func (e *ErrorValue) Set(value interface{}) {
 	e.initLogging()
+	e.setMu.Lock()
+	defer e.setMu.Unlock()
 	e.v.Set(valueOrErr{value: value})
 }
 
@@ -103,6 +147,41 @@ func (e *ErrorValue) Error(err error) bool {
 		return false
 	}
 	e.initLogging()
+	e.setMu.Lock()
+	defer e.setMu.Unlock()
+	e.v.Set(valueOrErr{err: err})
+	return true
+}
+
+// SetError updates the stored error, and returns true if it changed. Unlike
+// Error, a nil error clears any stored error, and subscribers are only
+// notified on a change, making it simple to track transitions between
+// healthy and failing states. Errors are considered equal if they have
+// the same type and message, so repeated failures do not notify.
+//
+// Clearing an error leaves no value stored, while a nil error when no error
+// is stored leaves the value unchanged.
+func (e *ErrorValue) SetError(err error) bool {
+	e.initLogging()
+	e.setMu.Lock()
+	defer e.setMu.Unlock()
+	current, _ := e.v.Get().(valueOrErr)
+	if sameError(current.err, err) {
+		return false
+	}
 	e.v.Set(valueOrErr{err: err})
 	return true
 }
+
+// Err returns the currently stored error, or nil if a value is stored.
+func (e *ErrorValue) Err() error {
+	_, err := e.Get()
+	return err
+}
+
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && a.Error() == b.Error()
+}
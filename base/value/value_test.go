@@ -15,11 +15,16 @@
 package value
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"barista.run/timing"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -172,3 +177,109 @@ func TestErrorValueSubscription(t *testing.T) {
 		require.Fail("<-Update() not notified within 1s")
 	}
 }
+
+func TestErrorValueSetError(t *testing.T) {
+	require := require.New(t)
+	var v ErrorValue
+	v.Set("value")
+
+	require.False(v.SetError(nil), "nil error when healthy")
+	val, err := v.Get()
+	require.Equal("value", val, "nil error when healthy keeps value")
+	require.NoError(err)
+
+	ch := v.Next()
+	require.True(v.SetError(errors.New("failed")), "new error")
+	assertNotified(t, ch, "on new error")
+	require.EqualError(v.Err(), "failed")
+
+	ch = v.Next()
+	require.False(v.SetError(errors.New("failed")), "same error message")
+	assertNotNotified(t, ch, "on identical error")
+
+	require.True(v.SetError(fmt.Errorf("failed: %w", io.EOF)),
+		"same message but different type")
+	assertNotified(t, ch, "when error type changes")
+
+	ch = v.Next()
+	require.True(v.SetError(errors.New("other")), "different message")
+	assertNotified(t, ch, "on different error")
+
+	ch = v.Next()
+	require.True(v.SetError(nil), "clearing error")
+	assertNotified(t, ch, "when error is cleared")
+	val, err = v.Get()
+	require.Nil(val, "clearing error leaves no value")
+	require.NoError(err)
+	require.NoError(v.Err())
+}
+
+func TestErrorValueSetErrorConcurrent(t *testing.T) {
+	var v ErrorValue
+	var changes int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var err error
+				if j%2 == 0 {
+					err = errors.New("failed")
+				}
+				if v.SetError(err) {
+					atomic.AddInt64(&changes, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Every reported change alternates the state, starting from healthy,
+	// and all goroutines end healthy.
+	require.Equal(t, int64(0), atomic.LoadInt64(&changes)%2,
+		"changes are consistent with the final state")
+	require.NoError(t, v.Err())
+}
+
+func assertNotified(t *testing.T, ch <-chan struct{}, msgAndArgs ...interface{}) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		require.Fail(t, "<-Next() not notified", msgAndArgs...)
+	}
+}
+
+func assertNotNotified(t *testing.T, ch <-chan struct{}, msgAndArgs ...interface{}) {
+	select {
+	case <-ch:
+		require.Fail(t, "<-Next() notified", msgAndArgs...)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	timing.TestMode()
+	v := NewCoalescing(time.Second)
+	v.Set(0)
+	require.Equal(t, 0, v.Get(), "value without subscribers")
+
+	next := v.Next()
+	start := timing.Now()
+	for i := 1; i <= 10; i++ {
+		v.Set(i)
+		require.Equal(t, i, v.Get(), "value is updated immediately")
+	}
+	assertNotNotified(t, next, "before coalescing interval")
+
+	require.Equal(t, time.Second, timing.NextTick().Sub(start))
+	assertNotified(t, next, "after coalescing interval")
+	require.Equal(t, 10, v.Get(), "latest value after burst")
+
+	next = v.Next()
+	assertNotNotified(t, next, "burst notifies only once")
+	require.Equal(t, timing.Now(), timing.NextTick(), "no other pending notifications")
+
+	v.Set(11)
+	timing.NextTick()
+	assertNotified(t, next, "on subsequent update")
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"strconv"
+
+	"barista.run/bar"
+)
+
+// ClickHandlers assigns names to all segments in the given module outputs
+// that handle clicks, in bar order. i3bar includes the name in each click
+// event, and the handler for that name should be called with the event.
+//
+// names has one entry for each segment across all outputs, with an empty
+// name for segments that do not handle clicks. Error segments always handle
+// clicks: a right-click calls onError, and other clicks go to the segment.
+func ClickHandlers(outputs []bar.Segments, onError func(bar.ErrorEvent)) (
+	names []string, handlers map[string]func(bar.Event),
+) {
+	handlers = map[string]func(bar.Event){}
+	for _, segments := range outputs {
+		for _, segment := range segments {
+			var clickHandler func(bar.Event)
+			if err := segment.GetError(); err != nil {
+				// because go.
+				segment := segment
+				clickHandler = func(e bar.Event) {
+					if e.Button == bar.ButtonRight {
+						onError(bar.ErrorEvent{Error: err, Event: e})
+					} else {
+						segment.Click(e)
+					}
+				}
+			} else if segment.HasClick() {
+				clickHandler = segment.Click
+			}
+			name := ""
+			if clickHandler != nil {
+				name = strconv.Itoa(len(handlers))
+				handlers[name] = clickHandler
+			}
+			names = append(names, name)
+		}
+	}
+	return names, handlers
+}
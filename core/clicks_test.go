@@ -0,0 +1,66 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHandlers(t *testing.T) {
+	var clicks []string
+	handler := func(name string) func(bar.Event) {
+		return func(bar.Event) { clicks = append(clicks, name) }
+	}
+	var errorEvents []bar.ErrorEvent
+	err := errors.New("oops")
+
+	names, handlers := ClickHandlers([]bar.Segments{
+		{
+			bar.TextSegment("a").OnClick(handler("a")),
+			bar.TextSegment("b"),
+		},
+		nil,
+		{
+			bar.ErrorSegment(err).OnClick(handler("error")),
+			bar.ErrorSegment(err),
+			bar.TextSegment("c").OnClick(handler("c")),
+		},
+	}, func(e bar.ErrorEvent) { errorEvents = append(errorEvents, e) })
+
+	require.Equal(t, []string{"0", "", "1", "2", "3"}, names)
+	require.Len(t, handlers, 4)
+
+	handlers["0"](bar.Event{Button: bar.ButtonLeft})
+	handlers["3"](bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, []string{"a", "c"}, clicks)
+	require.Empty(t, errorEvents)
+
+	clicks = nil
+	handlers["1"](bar.Event{Button: bar.ButtonLeft})
+	handlers["2"](bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, []string{"error"}, clicks,
+		"other clicks on error segments go to the segment")
+	require.Empty(t, errorEvents)
+
+	handlers["2"](bar.Event{Button: bar.ButtonRight, X: 5})
+	require.Equal(t, []bar.ErrorEvent{
+		{Error: err, Event: bar.Event{Button: bar.ButtonRight, X: 5}},
+	}, errorEvents, "right click on error segment")
+}
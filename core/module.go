@@ -17,6 +17,11 @@
 package core
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
 	"barista.run/bar"
 	"barista.run/base/notifier"
 	"barista.run/base/sink"
@@ -32,18 +37,29 @@ type Sink = func(bar.Segments)
 // other modules (group, reformat), and for writing tests.
 // It handles restarting the wrapped module on a left/right/middle click,
 // as well as providing an option to "replay" the last output from the module.
+// If the wrapped module panics, the panic is logged and shown as an error
+// output, and the module can be restarted by clicking on it, the same as
+// for modules that finish due to an error.
 type Module struct {
 	original  bar.Module
 	replayCh  <-chan struct{}
 	replayFn  func()
 	restartCh <-chan struct{}
 	restartFn func()
+	// Content of acknowledged segments, keyed by their acknowledge ID.
+	acks   map[string]string
+	acksMu sync.Mutex
+	// ctx is passed to modules that implement bar.ContextModule, and is
+	// cancelled by Close.
+	ctx    context.Context
+	cancel func()
 }
 
 // NewModule wraps an existing bar.Module with core barista functionality,
 // such as restarts and the ability to replay the last output.
 func NewModule(original bar.Module) *Module {
-	m := &Module{original: original}
+	m := &Module{original: original, acks: map[string]string{}}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
 	m.replayFn, m.replayCh = notifier.New()
 	m.restartFn, m.restartCh = notifier.New()
 	l.Attach(original, m, "~core")
@@ -53,31 +69,48 @@ func NewModule(original bar.Module) *Module {
 }
 
 // Stream runs the module with the given sink, automatically handling
-// terminations/restarts of the wrapped module.
+// terminations/restarts of the wrapped module. It returns once the module
+// has been closed, and the wrapped module has finished.
 func (m *Module) Stream(sink Sink) {
-	for {
+	for m.ctx.Err() == nil {
 		m.runLoop(sink)
 	}
 }
 
+// Close stops the wrapped module. Modules that implement bar.ContextModule
+// have their context cancelled, and no module is restarted after Close.
+func (m *Module) Close() {
+	l.Fine("%s closed", l.ID(m))
+	m.cancel()
+}
+
 // runLoop is one iteration of the wrapped module. It starts the wrapped
 // module, and multiplexes events, replay notifications, and module output.
 // It returns when the underlying module is ready to be restarted (i.e. it
 // was stopped and an eligible click event was received).
 func (m *Module) runLoop(realSink Sink) {
+	realSink = m.withAcks(realSink)
 	started := false
 	finished := false
 	outputCh, innerSink := sink.New()
 	doneCh := make(chan struct{})
 
-	go func(m bar.Module, innerSink bar.Sink, doneCh chan<- struct{}) {
-		l.Fine("%s started", l.ID(m))
-		m.Stream(innerSink)
-		l.Fine("%s finished", l.ID(m))
-		doneCh <- struct{}{}
+	go func(mod bar.Module, innerSink bar.Sink, doneCh chan<- struct{}) {
+		defer func() { doneCh <- struct{}{} }()
+		defer recoverPanic(mod, innerSink)
+		l.Fine("%s started", l.ID(mod))
+		if cm, ok := mod.(bar.ContextModule); ok {
+			cm.StreamContext(m.ctx, innerSink)
+		} else {
+			mod.Stream(innerSink)
+		}
+		l.Fine("%s finished", l.ID(mod))
 	}(m.original, innerSink, doneCh)
 
 	var out bar.Segments
+	// closed is only selected once the wrapped module has finished, so that
+	// a closed module that isn't waiting for a restart doesn't spin.
+	var closed <-chan struct{}
 	for {
 		select {
 		case o := <-outputCh:
@@ -85,7 +118,11 @@ func (m *Module) runLoop(realSink Sink) {
 			out = toSegments(o)
 			realSink(out)
 		case <-doneCh:
+			if m.ctx.Err() != nil {
+				return
+			}
 			finished = true
+			closed = m.ctx.Done()
 			l.Fine("%s: set restart handlers", l.ID(m))
 			realSink(addRestartHandlers(out, m.restartFn))
 		case <-m.replayCh:
@@ -99,10 +136,75 @@ func (m *Module) runLoop(realSink Sink) {
 				realSink(stripErrors(out, l.ID(m)))
 				return // Stream will restart the run loop.
 			}
+		case <-closed:
+			return
 		}
 	}
 }
 
+// recoverPanic recovers from a panic in the wrapped module's Stream
+// (which includes any output functions called by it), and replaces the
+// module's output with an error. It must be called using defer.
+func recoverPanic(m bar.Module, sink bar.Sink) {
+	if r := recover(); r != nil {
+		l.Log("%s panicked: %v\n%s", l.ID(m), r, debug.Stack())
+		sink.Error(fmt.Errorf("module panicked: %v", r))
+	}
+}
+
+// withAcks wraps a sink such that acknowledged segments are hidden, and
+// acknowledgeable segments are acknowledged when clicked.
+func (m *Module) withAcks(realSink Sink) Sink {
+	return func(in bar.Segments) {
+		realSink(m.hideAcknowledged(in))
+	}
+}
+
+// hideAcknowledged removes any acknowledged segments whose content has not
+// changed since they were acknowledged, and adds click handlers to other
+// acknowledgeable segments so that clicking on them acknowledges them.
+func (m *Module) hideAcknowledged(in bar.Segments) bar.Segments {
+	m.acksMu.Lock()
+	defer m.acksMu.Unlock()
+	var out bar.Segments
+	seen := map[string]bool{}
+	for _, s := range in {
+		id, ok := s.GetAcknowledgeID()
+		if !ok {
+			out = append(out, s)
+			continue
+		}
+		seen[id] = true
+		txt, _ := s.Content()
+		if acked, ok := m.acks[id]; ok && acked == txt {
+			continue
+		}
+		delete(m.acks, id)
+		// because go.
+		s := s
+		out = append(out, s.Clone().OnClick(func(e bar.Event) {
+			m.acknowledge(id, txt)
+			s.Click(e)
+		}))
+	}
+	for id := range m.acks {
+		if !seen[id] {
+			delete(m.acks, id)
+		}
+	}
+	return out
+}
+
+// acknowledge marks the segment with the given ID and content as
+// acknowledged, and updates the output to hide it.
+func (m *Module) acknowledge(id, content string) {
+	l.Fine("%s: acknowledged %s", l.ID(m), id)
+	m.acksMu.Lock()
+	m.acks[id] = content
+	m.acksMu.Unlock()
+	m.Replay()
+}
+
 // Replay sends the last output from the wrapped module to the sink.
 func (m *Module) Replay() {
 	m.replayFn()
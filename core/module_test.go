@@ -15,6 +15,7 @@
 package core
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -130,3 +131,165 @@ func TestRestart(t *testing.T) {
 	require.Equal(t, "test", txt)
 	tm.AssertStarted("on middle click")
 }
+
+type contextModule struct{ done chan struct{} }
+
+func (c *contextModule) Stream(sink bar.Sink) {
+	c.StreamContext(context.Background(), sink)
+}
+
+func (c *contextModule) StreamContext(ctx context.Context, sink bar.Sink) {
+	sink(outputs.Text("foo"))
+	<-ctx.Done()
+	close(c.done)
+}
+
+func TestClose(t *testing.T) {
+	c := &contextModule{make(chan struct{})}
+	m := NewModule(c)
+	ch, sink := chanSink()
+	returned := make(chan struct{})
+	go func() {
+		m.Stream(sink)
+		close(returned)
+	}()
+
+	txt, _ := nextOutput(t, ch, "on start")[0].Content()
+	require.Equal(t, "foo", txt)
+
+	m.Close()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		require.Fail(t, "module context not cancelled on close")
+	}
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		require.Fail(t, "Stream did not return on close")
+	}
+	assertNoOutput(t, ch, "no restart handlers after close")
+}
+
+func TestCloseFinished(t *testing.T) {
+	s := &simpleModule{make(chan bool)}
+	m := NewModule(s)
+	ch, sink := chanSink()
+	returned := make(chan struct{})
+	go func() {
+		m.Stream(sink)
+		close(returned)
+	}()
+
+	nextOutput(t, ch, "on start")
+	<-s.returned
+	nextOutput(t, ch, "sets restart handlers")
+
+	m.Close()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		require.Fail(t, "Stream did not return on close")
+	}
+}
+
+type panickingModule struct{ streams chan bool }
+
+func (p *panickingModule) Stream(sink bar.Sink) {
+	sink(outputs.Text("foo"))
+	p.streams <- true
+	panic("something went wrong")
+}
+
+func TestPanic(t *testing.T) {
+	p := &panickingModule{make(chan bool)}
+	m := NewModule(p)
+	ch, sink := chanSink()
+	go m.Stream(sink)
+
+	txt, _ := nextOutput(t, ch, "before panic")[0].Content()
+	require.Equal(t, "foo", txt)
+	<-p.streams
+
+	out := nextOutput(t, ch, "on panic")
+	require.Len(t, out, 1)
+	require.Error(t, out[0].GetError(), "panic replaces output with error")
+	require.Contains(t, out[0].GetError().Error(), "something went wrong")
+
+	out = nextOutput(t, ch, "sets restart handler after panic")
+	require.Error(t, out[0].GetError())
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+
+	nextOutput(t, ch, "clears error segment on restart")
+	txt, _ = nextOutput(t, ch, "after restart")[0].Content()
+	require.Equal(t, "foo", txt)
+	<-p.streams
+	require.Error(t, nextOutput(t, ch, "on panic after restart")[0].GetError())
+}
+
+func TestAcknowledge(t *testing.T) {
+	tm := testModule.New(t).SkipClickHandlers()
+	m := NewModule(tm)
+	ch, sink := chanSink()
+	go m.Stream(sink)
+	tm.AssertStarted()
+
+	texts := func(out bar.Segments) []string {
+		var txts []string
+		for _, s := range out {
+			txt, _ := s.Content()
+			txts = append(txts, txt)
+		}
+		return txts
+	}
+
+	clicks := make(chan bar.Event, 1)
+	tm.Output(outputs.Group(
+		outputs.Text("alert").Acknowledgeable("a").
+			OnClick(func(e bar.Event) { clicks <- e }),
+		outputs.Text("status"),
+	))
+	out := nextOutput(t, ch, "on output")
+	require.Equal(t, []string{"alert", "status"}, texts(out))
+	require.True(t, out[0].HasClick(), "acknowledgeable segment is clickable")
+
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	select {
+	case <-clicks:
+	case <-time.After(time.Second):
+		require.Fail(t, "original click handler not called")
+	}
+	out = nextOutput(t, ch, "on acknowledgement")
+	require.Equal(t, []string{"status"}, texts(out))
+
+	tm.Output(outputs.Group(
+		outputs.Text("alert").Acknowledgeable("a"),
+		outputs.Text("new status"),
+	))
+	out = nextOutput(t, ch, "on output with same alert")
+	require.Equal(t, []string{"new status"}, texts(out),
+		"acknowledged segment stays hidden while unchanged")
+
+	tm.Output(outputs.Group(
+		outputs.Text("alert!!").Acknowledgeable("a"),
+		outputs.Text("new status"),
+	))
+	out = nextOutput(t, ch, "on output with changed alert")
+	require.Equal(t, []string{"alert!!", "new status"}, texts(out),
+		"acknowledged segment is shown again when changed")
+
+	tm.Output(outputs.Text("alert").Acknowledgeable("a"))
+	out = nextOutput(t, ch, "on output when alert changes back")
+	require.Equal(t, []string{"alert"}, texts(out),
+		"acknowledgement is cleared when content changes")
+
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Empty(t, nextOutput(t, ch, "on acknowledgement"))
+
+	tm.Output(outputs.Text("other"))
+	nextOutput(t, ch, "on output without alert")
+	tm.Output(outputs.Text("alert").Acknowledgeable("a"))
+	out = nextOutput(t, ch, "on alert after absence")
+	require.Equal(t, []string{"alert"}, texts(out),
+		"acknowledgement is discarded when segment is not output")
+}
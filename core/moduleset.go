@@ -59,6 +59,13 @@ func (m *ModuleSet) sinkFn(idx int) Sink {
 	}
 }
 
+// Close stops all modules in the set, see Module.Close.
+func (m *ModuleSet) Close() {
+	for _, mod := range m.modules {
+		mod.Close()
+	}
+}
+
 func (m *ModuleSet) Len() int {
 	return len(m.modules)
 }
@@ -0,0 +1,83 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package format provides helpers for producing grammatical text output,
+// such as pluralised quantities and ordinal numbers.
+//
+// The package level functions use English rules. Other languages can be
+// supported by implementing the Language interface.
+package format // import "barista.run/format"
+
+import "fmt"
+
+// Language provides language-specific rules for pluralisation and ordinals.
+type Language interface {
+	// Plural returns the given quantity along with the correct form
+	// of the noun, e.g. "1 minute" or "2 minutes".
+	Plural(n int, singular, plural string) string
+	// Ordinal returns the ordinal form of the number, e.g. "2nd".
+	Ordinal(n int) string
+}
+
+// English implements Language using English grammar rules.
+var English Language = english{}
+
+type english struct{}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (english) Plural(n int, singular, plural string) string {
+	// Use the singular form only for exactly one, so "0 minutes",
+	// but "-1 minute", by analogy with "1 minute ago".
+	if abs(n) == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+func (english) Ordinal(n int) string {
+	suffix := "th"
+	// 11th, 12th, and 13th are exceptions to the last digit rule,
+	// as are 111th, 212th, etc.
+	switch abs(n) % 100 {
+	case 11, 12, 13:
+	default:
+		switch abs(n) % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// Plural returns the quantity n followed by the singular or plural
+// form of a noun, using English rules. e.g. Plural(2, "day", "days")
+// returns "2 days".
+func Plural(n int, singular, plural string) string {
+	return English.Plural(n, singular, plural)
+}
+
+// Ordinal returns the English ordinal form of n, e.g. "1st", "22nd", "13th".
+func Ordinal(n int) string {
+	return English.Ordinal(n)
+}
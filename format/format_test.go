@@ -0,0 +1,73 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{0, "0 minutes"},
+		{1, "1 minute"},
+		{2, "2 minutes"},
+		{11, "11 minutes"},
+		{21, "21 minutes"},
+		{-1, "-1 minute"},
+		{-2, "-2 minutes"},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.expected, Plural(tc.n, "minute", "minutes"))
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := map[int]string{
+		0:    "0th",
+		1:    "1st",
+		2:    "2nd",
+		3:    "3rd",
+		4:    "4th",
+		10:   "10th",
+		11:   "11th",
+		12:   "12th",
+		13:   "13th",
+		14:   "14th",
+		21:   "21st",
+		22:   "22nd",
+		23:   "23rd",
+		101:  "101st",
+		111:  "111th",
+		112:  "112th",
+		113:  "113th",
+		1002: "1002nd",
+		-1:   "-1st",
+		-11:  "-11th",
+		-22:  "-22nd",
+	}
+	for n, expected := range tests {
+		require.Equal(t, expected, Ordinal(n), "Ordinal(%d)", n)
+	}
+}
+
+func TestEnglish(t *testing.T) {
+	require.Equal(t, "3 ships", English.Plural(3, "ship", "ships"))
+	require.Equal(t, "3rd", English.Ordinal(3))
+}
@@ -140,6 +140,10 @@ var (
 	// labels keeps track of the current label for an object, to allow a subsequent
 	// Label(...) call to remove the current label.
 	labels = map[ident]string{}
+
+	// fields stores key-value pairs set using SetFields, which are included
+	// in leveled log messages about an object.
+	fields = map[ident][]interface{}{}
 	// instances keeps track of the number of instances of each type, used when
 	// generated IDs for previously unseen objects.
 	instances = map[string]int{}
@@ -359,3 +363,30 @@ func Register(thing interface{}, names ...string) {
 	nodes[thingId] = thingNode
 	refreshNames(thingId, thingName)
 }
+
+// SetFields sets key-value pairs that are included in all leveled log
+// messages (see Info) about thing, e.g.
+//     logging.SetFields(m, "cmd", m.cmd)
+// Setting a key again replaces its previous value.
+func SetFields(thing interface{}, keyvals ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	id := identify(thing)
+	if id.zero() {
+		return
+	}
+	kvs := fields[id]
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		replaced := false
+		for j := 0; j+1 < len(kvs); j += 2 {
+			if fmt.Sprint(kvs[j]) == fmt.Sprint(keyvals[i]) {
+				kvs[j+1] = keyvals[i+1]
+				replaced = true
+			}
+		}
+		if !replaced {
+			kvs = append(kvs, keyvals[i], keyvals[i+1])
+		}
+	}
+	fields[id] = kvs
+}
@@ -48,6 +48,15 @@ func construct() {
 	}
 	logger = log.New(os.Stderr, "", 0)
 	SetFlags(log.LstdFlags | log.Lshortfile)
+	minLevel := LevelInfo
+	if name, ok := os.LookupEnv("BARISTA_LOG_LEVEL"); ok {
+		if lvl, err := ParseLevel(name); err == nil {
+			minLevel = lvl
+		} else {
+			Log("BARISTA_LOG_LEVEL: %v", err)
+		}
+	}
+	SetLevel(minLevel)
 	for _, arg := range os.Args {
 		if mods, ok := trimPrefix(arg, "--finelog="); ok {
 			fineLogModules = append(fineLogModules, strings.Split(mods, ",")...)
@@ -176,3 +185,87 @@ func Fine(format string, args ...interface{}) {
 		doLog(mod, loc, format, args...)
 	}
 }
+
+var level int64
+
+// SetLevel sets the minimum level of messages logged by Debug, Info, Warn,
+// and Error. The default is LevelInfo, or the level named by the
+// BARISTA_LOG_LEVEL environment variable (e.g. BARISTA_LOG_LEVEL=debug).
+// [Requires debug logging].
+func SetLevel(l Level) {
+	atomic.StoreInt64(&level, int64(l))
+}
+
+func levelEnabled(l Level) bool {
+	return int64(l) >= atomic.LoadInt64(&level)
+}
+
+// Debug logs a message at LevelDebug. See Info.
+func Debug(thing interface{}, msg string, keyvals ...interface{}) {
+	if levelEnabled(LevelDebug) {
+		mod, loc := callingModule()
+		doLog(mod, loc, "%s", leveled(LevelDebug, thing, msg, keyvals))
+	}
+}
+
+// Info logs a message at LevelInfo about thing (typically a module), which
+// is identified using ID, along with any fields set using SetFields and the
+// given key-value pairs, e.g.
+//     logging.Info(m, "command exited", "cmd", name, "code", 2)
+// logs "[INFO] mod:shell.Module#0: command exited cmd=bash code=2".
+// Nothing is formatted unless the level is enabled. [Requires debug logging].
+func Info(thing interface{}, msg string, keyvals ...interface{}) {
+	if levelEnabled(LevelInfo) {
+		mod, loc := callingModule()
+		doLog(mod, loc, "%s", leveled(LevelInfo, thing, msg, keyvals))
+	}
+}
+
+// Warn logs a message at LevelWarn. See Info.
+func Warn(thing interface{}, msg string, keyvals ...interface{}) {
+	if levelEnabled(LevelWarn) {
+		mod, loc := callingModule()
+		doLog(mod, loc, "%s", leveled(LevelWarn, thing, msg, keyvals))
+	}
+}
+
+// Error logs a message at LevelError. See Info.
+func Error(thing interface{}, msg string, keyvals ...interface{}) {
+	if levelEnabled(LevelError) {
+		mod, loc := callingModule()
+		doLog(mod, loc, "%s", leveled(LevelError, thing, msg, keyvals))
+	}
+}
+
+// leveled formats a message for the leveled logging functions.
+func leveled(l Level, thing interface{}, msg string, keyvals []interface{}) string {
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "[%s] ", l)
+	if thing != nil {
+		fmt.Fprintf(out, "%s: ", ID(thing))
+	}
+	out.WriteString(msg)
+	mu.Lock()
+	fieldKVs := fields[identify(thing)]
+	mu.Unlock()
+	writeKeyvals(out, fieldKVs)
+	writeKeyvals(out, keyvals)
+	return out.String()
+}
+
+// writeKeyvals writes key-value pairs as " key=value", quoting values that
+// would otherwise be ambiguous. A trailing key without a value is written
+// with the value "(missing)".
+func writeKeyvals(out *strings.Builder, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "(missing)"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		str := fmt.Sprint(val)
+		if str == "" || strings.ContainsAny(str, " =\"\t\n") {
+			str = fmt.Sprintf("%q", str)
+		}
+		fmt.Fprintf(out, " %v=%s", keyvals[i], str)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	"barista.run/testing/mockio"
@@ -39,6 +40,7 @@ func resetLoggingState() {
 	fineLogModules = []string{}
 	objectIDs = map[ident]string{}
 	labels = map[ident]string{}
+	fields = map[ident][]interface{}{}
 
 	fineLogModulesCache.Range(func(k, v interface{}) bool {
 		fineLogModulesCache.Delete(k)
@@ -133,3 +135,78 @@ func TestFileLocations(t *testing.T) {
 	_, _, line, _ := runtime.Caller(0)
 	assertLogged(t, fmt.Sprintf("logging_test.go:%d (bar:logging.TestFileLocations) foo", line-1))
 }
+
+func TestLevels(t *testing.T) {
+	resetLoggingState()
+	Debug(nil, "debug")
+	require.Empty(t, mockStderr.ReadNow(), "debug is disabled by default")
+	Info(nil, "info")
+	assertLogged(t, "[INFO] info")
+	Warn(nil, "warn", "key", "value")
+	assertLogged(t, "[WARN] warn key=value")
+
+	SetLevel(LevelError)
+	Warn(nil, "warn")
+	require.Empty(t, mockStderr.ReadNow())
+	Error(nil, "error", "code", 2, "msg", "two words", "empty", "", "odd")
+	assertLogged(t, "%s", `[ERROR] error code=2 msg="two words" empty="" odd=(missing)`)
+
+	SetLevel(LevelDebug)
+	called := false
+	Debug(nil, "debug", "lazy", stringer(func() string {
+		called = true
+		return "yes"
+	}))
+	assertLogged(t, "[DEBUG] debug lazy=yes")
+	require.True(t, called)
+
+	SetLevel(LevelInfo)
+	called = false
+	Debug(nil, "debug", "lazy", stringer(func() string {
+		called = true
+		return "yes"
+	}))
+	require.False(t, called, "values not formatted when level is disabled")
+
+	os.Setenv("BARISTA_LOG_LEVEL", "warn")
+	defer os.Unsetenv("BARISTA_LOG_LEVEL")
+	resetLoggingState()
+	Info(nil, "info")
+	require.Empty(t, mockStderr.ReadNow(), "level from environment")
+	Warn(nil, "warn")
+	assertLogged(t, "[WARN] warn")
+}
+
+type stringer func() string
+
+func (s stringer) String() string { return s() }
+
+func TestFields(t *testing.T) {
+	resetLoggingState()
+	type module struct{ foo int }
+	m := &module{}
+	Info(m, "no fields")
+	assertLogged(t, "[INFO] bar:logging.module#0: no fields")
+
+	SetFields(m, "cmd", "ls", "dir", "/tmp")
+	Info(m, "started", "pid", 42)
+	assertLogged(t, "[INFO] bar:logging.module#0: started cmd=ls dir=/tmp pid=42")
+
+	SetFields(m, "dir", "/home")
+	Warn(m, "exited", "code", 1)
+	assertLogged(t, "[WARN] bar:logging.module#0: exited cmd=ls dir=/home code=1")
+
+	Info(&module{}, "other")
+	assertLogged(t, "[INFO] bar:logging.module#1: other")
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		parsed, err := ParseLevel(strings.ToLower(l.String()))
+		require.NoError(t, err)
+		require.Equal(t, l, parsed)
+	}
+	_, err := ParseLevel("verbose")
+	require.Error(t, err)
+	require.Equal(t, "Level(7)", Level(7).String())
+}
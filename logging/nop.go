@@ -35,6 +35,29 @@ func Log(format string, args ...interface{}) {}
 // `--finelog=$module1,$module2`. [Requires debug logging].
 func Fine(format string, args ...interface{}) {}
 
+// SetLevel sets the minimum level of messages logged by Debug, Info, Warn,
+// and Error. The default is LevelInfo, or the level named by the
+// BARISTA_LOG_LEVEL environment variable (e.g. BARISTA_LOG_LEVEL=debug).
+// [Requires debug logging].
+func SetLevel(l Level) {}
+
+// Debug logs a message at LevelDebug. See Info.
+func Debug(thing interface{}, msg string, keyvals ...interface{}) {}
+
+// Info logs a message at LevelInfo about thing (typically a module), which
+// is identified using ID, along with any fields set using SetFields and the
+// given key-value pairs, e.g.
+//     logging.Info(m, "command exited", "cmd", name, "code", 2)
+// logs "[INFO] mod:shell.Module#0: command exited cmd=bash code=2".
+// Nothing is formatted unless the level is enabled. [Requires debug logging].
+func Info(thing interface{}, msg string, keyvals ...interface{}) {}
+
+// Warn logs a message at LevelWarn. See Info.
+func Warn(thing interface{}, msg string, keyvals ...interface{}) {}
+
+// Error logs a message at LevelError. See Info.
+func Error(thing interface{}, msg string, keyvals ...interface{}) {}
+
 // ID returns a unique name for the given value of the form 'type'#'index'
 // for addressable types. This provides log statements with additional
 // context and separates logs from multiple instances of the same type.
@@ -70,3 +93,9 @@ func Attachf(parent, child interface{}, format string, args ...interface{}) {}
 // This is just a shortcut for Register(&thing, &thing.field, ".field")...
 // for a set of fields.
 func Register(thing interface{}, names ...string) {}
+
+// SetFields sets key-value pairs that are included in all leveled log
+// messages (see Info) about thing, e.g.
+//     logging.SetFields(m, "cmd", m.cmd)
+// Setting a key again replaces its previous value.
+func SetFields(thing interface{}, keyvals ...interface{}) {}
@@ -36,4 +36,10 @@ func TestNopMethods(t *testing.T) {
 	Attach(t, 4, "->int")
 	Attachf(t, 1.0, "->float:%g", 1.0)
 	Register(t, "Fail", "FailNow")
+	SetLevel(LevelDebug)
+	Debug(t, "debug", "key", 1)
+	Info(t, "info")
+	Warn(nil, "warn")
+	Error(nil, "error", "err", "oops")
+	SetFields(t, "key", "value")
 }
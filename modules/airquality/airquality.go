@@ -0,0 +1,172 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package airquality provides an i3bar module that displays the air quality
+// index (AQI) using a pluggable provider.
+package airquality // import "barista.run/modules/airquality"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// AirQuality represents the current air quality.
+type AirQuality struct {
+	// AQI is the US EPA air quality index.
+	AQI int
+	// Dominant is the pollutant responsible for the AQI, e.g. "pm2.5".
+	Dominant string
+	// Updated is the time of the measurement.
+	Updated time.Time
+	// Attribution is the name of the provider.
+	Attribution string
+	// Stale is set by the module if the latest fetch failed, in which case
+	// the last successfully fetched air quality is displayed.
+	Stale bool
+}
+
+// Category returns the category of the air quality index.
+func (a AirQuality) Category() Category {
+	return CategoryOf(a.AQI)
+}
+
+// Category represents a range of the air quality index.
+type Category int
+
+// Air quality categories, as defined by the US EPA.
+const (
+	Good Category = iota
+	Moderate
+	UnhealthyForSensitiveGroups
+	Unhealthy
+	VeryUnhealthy
+	Hazardous
+)
+
+// CategoryOf returns the category of the given air quality index.
+func CategoryOf(aqi int) Category {
+	switch {
+	case aqi <= 50:
+		return Good
+	case aqi <= 100:
+		return Moderate
+	case aqi <= 150:
+		return UnhealthyForSensitiveGroups
+	case aqi <= 200:
+		return Unhealthy
+	case aqi <= 300:
+		return VeryUnhealthy
+	}
+	return Hazardous
+}
+
+func (c Category) String() string {
+	switch c {
+	case Good:
+		return "Good"
+	case Moderate:
+		return "Moderate"
+	case UnhealthyForSensitiveGroups:
+		return "Unhealthy for Sensitive Groups"
+	case Unhealthy:
+		return "Unhealthy"
+	case VeryUnhealthy:
+		return "Very Unhealthy"
+	}
+	return "Hazardous"
+}
+
+// Severity returns the severity used to style outputs for the category.
+func (c Category) Severity() outputs.Severity {
+	switch {
+	case c == Good:
+		return outputs.Info
+	case c <= UnhealthyForSensitiveGroups:
+		return outputs.Warning
+	}
+	return outputs.Critical
+}
+
+// Provider is an interface for air quality providers,
+// implemented by the various provider packages.
+type Provider interface {
+	GetAirQuality() (AirQuality, error)
+}
+
+// Module represents a bar.Module that displays air quality information.
+type Module struct {
+	provider   Provider
+	scheduler  timing.Scheduler
+	outputFunc value.OutputFunc[AirQuality]
+}
+
+// New constructs an instance of the air quality module for the provider.
+func New(provider Provider) *Module {
+	m := &Module{
+		provider:  provider,
+		scheduler: timing.NewScheduler(),
+	}
+	l.Register(m, "outputFunc", "scheduler")
+	// Default output is the AQI, coloured by category.
+	m.Output(func(a AirQuality) bar.Output {
+		return outputs.WithSeverity(
+			outputs.Textf("AQI %d", a.AQI), a.Category().Severity())
+	})
+	m.RefreshInterval(30 * time.Minute)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(AirQuality) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	aq, err := m.provider.GetAirQuality()
+	if s.Error(err) {
+		return
+	}
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		s.Output(outputFunc(aq))
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-m.scheduler.Tick():
+			latest, err := m.provider.GetAirQuality()
+			if err != nil {
+				// Keep showing the last air quality, since it changes slowly.
+				l.Log("%s: fetch failed, showing last value: %v", l.ID(m), err)
+				aq.Stale = true
+			} else {
+				aq = latest
+			}
+		}
+	}
+}
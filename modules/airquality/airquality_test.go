@@ -0,0 +1,118 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package airquality
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	sync.Mutex
+	AirQuality
+	error
+}
+
+func (t *testProvider) set(aq AirQuality, err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.AirQuality, t.error = aq, err
+}
+
+func (t *testProvider) GetAirQuality() (AirQuality, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.AirQuality, t.error
+}
+
+func TestAirQuality(t *testing.T) {
+	colors.Set("good", colors.Hex("#0f0"))
+	colors.Set("degraded", colors.Hex("#ff0"))
+	colors.Set("bad", colors.Hex("#f00"))
+	testBar.New(t)
+	p := &testProvider{AirQuality: AirQuality{AQI: 42, Dominant: "pm2.5"}}
+	aq := New(p)
+	testBar.Run(aq)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"AQI 42"})
+	col, _ := out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("good"), col)
+
+	p.set(AirQuality{AQI: 180, Dominant: "o3"}, nil)
+	testBar.Tick()
+	out = testBar.NextOutput("on tick")
+	out.AssertText([]string{"AQI 180"})
+	col, _ = out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("bad"), col)
+
+	aq.Output(func(a AirQuality) bar.Output {
+		return outputs.Textf("%d %s %s %v", a.AQI, a.Dominant, a.Category(), a.Stale)
+	})
+	testBar.NextOutput().AssertText([]string{"180 o3 Unhealthy false"},
+		"on output func change")
+
+	p.set(AirQuality{}, errors.New("timeout"))
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"180 o3 Unhealthy true"},
+		"last value on fetch failure")
+
+	p.set(AirQuality{AQI: 75, Dominant: "pm10"}, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"75 pm10 Moderate false"},
+		"on successful fetch")
+}
+
+func TestInitialError(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{error: errors.New("offline")}
+	testBar.Run(New(p))
+	testBar.NextOutput().AssertError("on start with error")
+}
+
+func TestCategories(t *testing.T) {
+	for _, tc := range []struct {
+		aqi      int
+		category Category
+		severity outputs.Severity
+	}{
+		{0, Good, outputs.Info},
+		{50, Good, outputs.Info},
+		{51, Moderate, outputs.Warning},
+		{100, Moderate, outputs.Warning},
+		{101, UnhealthyForSensitiveGroups, outputs.Warning},
+		{150, UnhealthyForSensitiveGroups, outputs.Warning},
+		{151, Unhealthy, outputs.Critical},
+		{200, Unhealthy, outputs.Critical},
+		{201, VeryUnhealthy, outputs.Critical},
+		{300, VeryUnhealthy, outputs.Critical},
+		{301, Hazardous, outputs.Critical},
+		{500, Hazardous, outputs.Critical},
+	} {
+		c := CategoryOf(tc.aqi)
+		require.Equal(t, tc.category, c, "category of %d", tc.aqi)
+		require.Equal(t, tc.severity, c.Severity(), "severity of %s", c)
+	}
+	require.Equal(t, "Unhealthy for Sensitive Groups",
+		UnhealthyForSensitiveGroups.String())
+}
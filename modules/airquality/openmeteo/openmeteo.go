@@ -0,0 +1,106 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package openmeteo provides air quality using the free Open-Meteo air quality
+API, available at https://open-meteo.com/en/docs/air-quality-api.
+*/
+package openmeteo // import "barista.run/modules/airquality/openmeteo"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/modules/airquality"
+)
+
+// Provider wraps an Open-Meteo API url so that it can be used as an
+// airquality.Provider.
+type Provider string
+
+// pollutants maps the US AQI sub-index fields of the response to the
+// pollutants displayed as dominant.
+var pollutants = []struct{ field, name string }{
+	{"us_aqi_pm2_5", "pm2.5"},
+	{"us_aqi_pm10", "pm10"},
+	{"us_aqi_ozone", "o3"},
+	{"us_aqi_nitrogen_dioxide", "no2"},
+	{"us_aqi_sulphur_dioxide", "so2"},
+	{"us_aqi_carbon_monoxide", "co"},
+}
+
+// Coords queries Open-Meteo using lat/lon co-ordinates.
+func Coords(lat, lon float64) Provider {
+	fields := "us_aqi"
+	for _, p := range pollutants {
+		fields += "," + p.field
+	}
+	qp := url.Values{}
+	qp.Add("latitude", fmt.Sprintf("%.4f", lat))
+	qp.Add("longitude", fmt.Sprintf("%.4f", lon))
+	qp.Add("current", fields)
+	qp.Add("timeformat", "unixtime")
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "air-quality-api.open-meteo.com",
+		Path:     "/v1/air-quality",
+		RawQuery: qp.Encode(),
+	}
+	return Provider(u.String())
+}
+
+// omResponse represents an Open-Meteo json response. The current values are
+// kept as a map since the sub-index fields are selected in the query.
+type omResponse struct {
+	Current map[string]*float64
+}
+
+// GetAirQuality gets air quality information from Open-Meteo.
+func (p Provider) GetAirQuality() (airquality.AirQuality, error) {
+	response, err := http.Get(string(p))
+	if err != nil {
+		return airquality.AirQuality{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return airquality.AirQuality{}, fmt.Errorf(
+			"open-meteo: %s", response.Status)
+	}
+	o := omResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&o); err != nil {
+		return airquality.AirQuality{}, err
+	}
+	aqi := o.Current["us_aqi"]
+	if aqi == nil {
+		return airquality.AirQuality{}, fmt.Errorf("Bad response from Open-Meteo")
+	}
+	aq := airquality.AirQuality{
+		AQI:         int(*aqi + 0.5),
+		Attribution: "Open-Meteo",
+	}
+	if t := o.Current["time"]; t != nil {
+		aq.Updated = time.Unix(int64(*t), 0)
+	}
+	max := -1.0
+	for _, pollutant := range pollutants {
+		if v := o.Current[pollutant.field]; v != nil && *v > max {
+			max = *v
+			aq.Dominant = pollutant.name
+		}
+	}
+	return aq, nil
+}
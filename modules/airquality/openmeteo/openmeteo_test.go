@@ -0,0 +1,72 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openmeteo
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/modules/airquality"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestGood(t *testing.T) {
+	aq, err := Provider(ts.URL + "/static/good.json").GetAirQuality()
+	require.NoError(t, err)
+	require.Equal(t, airquality.AirQuality{
+		AQI:         63,
+		Dominant:    "o3",
+		Updated:     time.Unix(1718103600, 0),
+		Attribution: "Open-Meteo",
+	}, aq)
+	require.Equal(t, airquality.Moderate, aq.Category())
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider(ts.URL + "/static/bad.json").GetAirQuality()
+	require.Error(t, err, "bad json")
+
+	_, err = Provider(ts.URL + "/code/429").GetAirQuality()
+	require.Error(t, err, "http error")
+
+	_, err = Provider(ts.URL + "/static/empty.json").GetAirQuality()
+	require.Error(t, err, "valid json but bad response")
+
+	_, err = Provider(ts.URL + "/redir").GetAirQuality()
+	require.Error(t, err, "http error")
+}
+
+func TestCoords(t *testing.T) {
+	u, err := url.Parse(string(Coords(52.52, 13.41)))
+	require.NoError(t, err)
+	require.Equal(t, "air-quality-api.open-meteo.com", u.Host)
+	q := u.Query()
+	require.Equal(t, "52.5200", q.Get("latitude"))
+	require.Equal(t, "13.4100", q.Get("longitude"))
+	require.Contains(t, q.Get("current"), "us_aqi,us_aqi_pm2_5")
+}
@@ -127,7 +127,7 @@ func (i Info) SignedPower() float64 {
 type Module struct {
 	updateFunc func() Info
 	scheduler  timing.Scheduler
-	outputFunc value.Value // of func(Info) bar.Output
+	outputFunc value.OutputFunc[Info]
 }
 
 func newModule(updateFunc func() Info) *Module {
@@ -171,7 +171,7 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	info := m.updateFunc()
-	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		s.Output(outputFunc(info))
@@ -180,7 +180,7 @@ func (m *Module) Stream(s bar.Sink) {
 			info = m.updateFunc()
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 	}
 }
@@ -29,17 +29,11 @@ import (
 // Module represents a clock bar module. It supports setting the click handler,
 // timezone, output format, and granularity.
 type Module struct {
-	config value.Value
-}
-
-type config struct {
-	granularity time.Duration
-	outputFunc  func(time.Time) bar.Output
-	timezone    *time.Location
-}
-
-func (m *Module) getConfig() config {
-	return m.config.Get().(config)
+	outputFunc value.OutputFunc[time.Time]
+	// granularity is set before the output function it belongs to, and
+	// re-read whenever the output function changes.
+	granularity value.TypedValue[time.Duration]
+	timezone    value.TypedValue[*time.Location]
 }
 
 func defaultOutput(now time.Time) bar.Output {
@@ -49,12 +43,9 @@ func defaultOutput(now time.Time) bar.Output {
 // Zone constructs a clock module for the given timezone.
 func Zone(timezone *time.Location) *Module {
 	m := &Module{}
-	l.Register(m, "config")
-	m.config.Set(config{
-		timezone:    timezone,
-		granularity: time.Minute,
-		outputFunc:  defaultOutput,
-	})
+	l.Register(m, "outputFunc", "granularity", "timezone")
+	m.timezone.Set(timezone)
+	m.Output(time.Minute, defaultOutput)
 	return m
 }
 
@@ -84,10 +75,8 @@ func (m *Module) Output(
 	granularity time.Duration,
 	outputFunc func(time.Time) bar.Output,
 ) *Module {
-	c := m.getConfig()
-	c.granularity = granularity
-	c.outputFunc = outputFunc
-	m.config.Set(c)
+	m.granularity.Set(granularity)
+	m.outputFunc.Set(outputFunc)
 	return m
 }
 
@@ -113,9 +102,7 @@ func (m *Module) OutputFormat(format string) *Module {
 
 // Timezone configures the timezone for this clock.
 func (m *Module) Timezone(timezone *time.Location) *Module {
-	c := m.getConfig()
-	c.timezone = timezone
-	m.config.Set(c)
+	m.timezone.Set(timezone)
 	return m
 }
 
@@ -123,19 +110,39 @@ func (m *Module) Timezone(timezone *time.Location) *Module {
 func (m *Module) Stream(s bar.Sink) {
 	sch := timing.NewScheduler()
 	l.Attach(m, sch, ".scheduler")
-	cfg := m.getConfig()
-	nextCfg := m.config.Next()
+	nextOutputFunc := m.outputFunc.Next()
+	outputFunc := m.outputFunc.Get()
+	granularity := m.granularity.Get()
+	nextTimezone := m.timezone.Next()
+	timezone := m.timezone.Get()
 	for {
 		now := timing.Now()
-		next := now.Add(cfg.granularity).Truncate(cfg.granularity)
+		next := now.Add(granularity).Truncate(granularity)
 		sch.At(next)
-		s.Output(cfg.outputFunc(now.In(cfg.timezone)))
+		s.Output(outputFunc(now.In(timezone)))
 
 		select {
 		case <-sch.Tick():
-		case <-nextCfg:
-			nextCfg = m.config.Next()
-			cfg = m.getConfig()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+			granularity = m.granularity.Get()
+		case <-nextTimezone:
+			nextTimezone = m.timezone.Next()
+			timezone = m.timezone.Get()
 		}
 	}
 }
+
+// ISOWeek returns the ISO 8601 week number (1-53) of the given time. Since
+// the first and last days of a year may belong to a week of the adjacent
+// year, use t.ISOWeek() if the year of the week is also needed.
+func ISOWeek(t time.Time) int {
+	_, week := t.ISOWeek()
+	return week
+}
+
+// DayOfYear returns the day of the year (1-366) of the given time.
+func DayOfYear(t time.Time) int {
+	return t.YearDay()
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/value"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
@@ -134,6 +135,24 @@ func TestManualGranularities(t *testing.T) {
 		[]string{"02:01:00.00"}, "on tick")
 }
 
+func TestRerender(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(fixedTime)
+
+	suffix := "a"
+	local := Local().Output(time.Minute, func(now time.Time) bar.Output {
+		return outputs.Text(now.Format("15:04") + suffix)
+	})
+	testBar.Run(local)
+	testBar.NextOutput().AssertText([]string{"00:00a"}, "on start")
+
+	suffix = "b"
+	testBar.AssertNoOutput("until re-rendered")
+	value.Rerender()
+	testBar.NextOutput().AssertText([]string{"00:00b"}, "on rerender")
+	testBar.AssertNoOutput("once re-rendered")
+}
+
 func TestZones(t *testing.T) {
 	testBar.New(t)
 	timing.AdvanceTo(
@@ -168,3 +187,29 @@ func TestZones(t *testing.T) {
 	testBar.LatestOutput(1).At(1).AssertText(
 		"05:15:01", "on timezone change")
 }
+
+func TestISOWeekAndDayOfYear(t *testing.T) {
+	for _, tc := range []struct {
+		date      string
+		week, day int
+	}{
+		{"2017-03-01", 9, 60},
+		// Jan 1st on a Sunday belongs to the last week of the previous year.
+		{"2017-01-01", 52, 1},
+		{"2017-01-02", 1, 2},
+		// Week 53 in a year starting on a Thursday.
+		{"2015-12-31", 53, 365},
+		{"2016-01-03", 53, 3},
+		{"2016-01-04", 1, 4},
+		// Dec 31st in a leap year, in week 1 of the next year.
+		{"2024-12-30", 1, 365},
+		{"2024-12-31", 1, 366},
+		{"2020-12-31", 53, 366},
+		{"2021-01-01", 53, 1},
+		{"2018-12-31", 1, 365},
+	} {
+		d, _ := time.Parse("2006-01-02", tc.date)
+		require.Equal(t, tc.week, ISOWeek(d), "ISO week of %s", tc.date)
+		require.Equal(t, tc.day, DayOfYear(d), "day of year of %s", tc.date)
+	}
+}
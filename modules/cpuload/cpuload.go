@@ -51,7 +51,7 @@ func (l LoadAvg) Min15() float64 {
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
 	scheduler  timing.Scheduler
-	outputFunc value.Value // of func(LoadAvg) bar.Output
+	outputFunc value.OutputFunc[LoadAvg]
 }
 
 // New constructs an instance of the cpuload module.
@@ -82,7 +82,7 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 func (m *Module) Stream(s bar.Sink) {
 	var loads LoadAvg
 	count, err := getloadavg(&loads, 3)
-	outputFunc := m.outputFunc.Get().(func(LoadAvg) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		if s.Error(err) {
@@ -98,7 +98,7 @@ func (m *Module) Stream(s bar.Sink) {
 			count, err = getloadavg(&loads, 3)
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(LoadAvg) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 	}
 }
@@ -36,7 +36,7 @@ import (
 type Module struct {
 	thermalFile string
 	scheduler   timing.Scheduler
-	outputFunc  value.Value // of func(unit.Temperature) bar.Output
+	outputFunc  value.OutputFunc[unit.Temperature]
 }
 
 // Zone constructs an instance of the cputemp module for the specified zone.
@@ -97,7 +97,7 @@ var fs = afero.NewOsFs()
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	temp, err := getTemperature(m.thermalFile)
-	outputFunc := m.outputFunc.Get().(func(unit.Temperature) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		if s.Error(err) {
@@ -109,7 +109,7 @@ func (m *Module) Stream(s bar.Sink) {
 			temp, err = getTemperature(m.thermalFile)
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(unit.Temperature) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 	}
 }
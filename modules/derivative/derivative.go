@@ -0,0 +1,116 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package derivative provides an i3bar module that displays the rate of
+// change of a numeric value, e.g. to turn a cumulative counter into a speed.
+package derivative // import "barista.run/modules/derivative"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Source returns the current value to differentiate.
+type Source func() (float64, error)
+
+// Info represents the current value and its rate of change.
+type Info struct {
+	// Value is the most recent value read from the source.
+	Value float64
+	// Rate is the change in value per second since the previous reading.
+	Rate float64
+}
+
+// Rate returns the change per second between two readings of a counter
+// taken the given duration apart. Since counters only increase, a decrease
+// is treated as a counter reset, and results in a rate of zero.
+func Rate(previous, current float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 || current < previous {
+		return 0
+	}
+	return (current - previous) / elapsed.Seconds()
+}
+
+// Module represents a derivative bar module.
+type Module struct {
+	source     Source
+	scheduler  timing.Scheduler
+	outputFunc value.OutputFunc[Info]
+}
+
+// New constructs a module that displays the rate of change of the values
+// returned by the source, which is read on each refresh.
+func New(source Source) *Module {
+	m := &Module{source: source, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(3 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.1f/s", i.Rate)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the source. The rate
+// is averaged over this interval.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	lastRead := timing.Now()
+	last, err := m.source()
+	if s.Error(err) {
+		return
+	}
+
+	var info Info
+	available := false
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+
+	for {
+		if available {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-m.scheduler.Tick():
+			current, err := m.source()
+			if s.Error(err) {
+				return
+			}
+			now := timing.Now()
+			available = true
+			info.Value = current
+			info.Rate = Rate(last, current, now.Sub(lastRead))
+			lastRead = now
+			last = current
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package derivative
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	sync.Mutex
+	value float64
+	err   error
+}
+
+func (c *counter) set(value float64, err error) {
+	c.Lock()
+	defer c.Unlock()
+	c.value, c.err = value, err
+}
+
+func (c *counter) read() (float64, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.value, c.err
+}
+
+func TestDerivative(t *testing.T) {
+	testBar.New(t)
+	c := &counter{value: 100}
+	m := New(c.read).RefreshInterval(2 * time.Second)
+	testBar.Run(m)
+	testBar.AssertNoOutput("until two readings")
+
+	c.set(150, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"25.0/s"}, "on tick")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f %.1f", i.Value, i.Rate)
+	})
+	testBar.NextOutput().AssertText([]string{"150 25.0"}, "on output change")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"150 0.0"}, "unchanged value")
+
+	c.set(20, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"20 0.0"}, "counter reset")
+
+	c.set(21, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"21 0.5"}, "after counter reset")
+
+	c.set(0, errors.New("foo"))
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on source error")
+
+	c.set(30, nil)
+	testBar.NextOutput("with restart click handler").At(0).LeftClick()
+	testBar.NextOutput().Expect("on restart, clears error segment")
+	testBar.AssertNoOutput("until two readings after restart")
+	c.set(40, nil)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"40 5.0"}, "after restart")
+}
+
+func TestRate(t *testing.T) {
+	require.Equal(t, 5.0, Rate(10, 20, 2*time.Second))
+	require.Equal(t, 0.0, Rate(20, 10, time.Second), "counter reset")
+	require.Equal(t, 0.0, Rate(10, 20, 0), "no elapsed time")
+	require.Equal(t, 0.5, Rate(1, 1.5, time.Second), "fractional values")
+}
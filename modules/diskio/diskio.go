@@ -86,7 +86,7 @@ func RefreshInterval(interval time.Duration) {
 // Module represents a bar.Module for a single disk's io activity.
 type Module struct {
 	ioChan     <-chan IO
-	outputFunc value.Value
+	outputFunc value.OutputFunc[IO]
 }
 
 // New creates a diskio module that displays disk io rates for the given disk.
@@ -118,14 +118,14 @@ func (m *Module) Output(outputFunc func(IO) bar.Output) *Module {
 // first module is constructed, even if no modules are streaming.
 func (m *Module) Stream(s bar.Sink) {
 	var i IO
-	outputFunc := m.outputFunc.Get().(func(IO) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		select {
 		case i = <-m.ioChan:
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(IO) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 		if s.Error(i.err) {
 			continue
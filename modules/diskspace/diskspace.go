@@ -66,7 +66,7 @@ func (i Info) AvailPct() int {
 type Module struct {
 	path       string
 	scheduler  timing.Scheduler
-	outputFunc value.Value // of func(Info) bar.Output
+	outputFunc value.OutputFunc[Info]
 }
 
 // New constructs an instance of the diskusage module for the given disk path.
@@ -100,7 +100,7 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	info, err := getStatFsInfo(m.path)
-	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		if os.IsNotExist(err) {
@@ -119,7 +119,7 @@ func (m *Module) Stream(s bar.Sink) {
 			info, err = getStatFsInfo(m.path)
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 	}
 }
@@ -0,0 +1,324 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dock provides an i3bar module that shows whether the laptop
+// lid is closed and whether it is docked.
+//
+// The lid state is read from ACPI (/proc/acpi/button/lid/*/state), and the
+// docking state from the ACPI dock driver (/sys/devices/platform/dock.*/docked).
+// Neither of these files supports inotify, so they are polled. If acpid is
+// running, the module also re-reads them as soon as acpid reports a lid or
+// dock event, so that changes show up without waiting for the next poll.
+// (logind's LidClosed and Docked properties do not emit PropertiesChanged,
+// so they cannot be used for this.) On machines
+// without a lid or a dock (e.g. desktops), the corresponding state is
+// reported as unavailable ("n/a").
+package dock // import "barista.run/modules/dock"
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+)
+
+// LidState represents the state of the laptop lid.
+type LidState int
+
+const (
+	// LidUnavailable indicates that there is no lid (or its state is unknown).
+	LidUnavailable LidState = iota
+	// LidOpen indicates that the lid is open.
+	LidOpen
+	// LidClosed indicates that the lid is closed.
+	LidClosed
+)
+
+func (l LidState) String() string {
+	switch l {
+	case LidOpen:
+		return "open"
+	case LidClosed:
+		return "closed"
+	default:
+		return "n/a"
+	}
+}
+
+// DockState represents the docking state of the laptop.
+type DockState int
+
+const (
+	// DockUnavailable indicates that there is no dock station support.
+	DockUnavailable DockState = iota
+	// Undocked indicates that the laptop is not docked.
+	Undocked
+	// Docked indicates that the laptop is docked.
+	Docked
+)
+
+func (d DockState) String() string {
+	switch d {
+	case Undocked:
+		return "undocked"
+	case Docked:
+		return "docked"
+	default:
+		return "n/a"
+	}
+}
+
+// Info represents the current lid and docking state.
+type Info struct {
+	Lid  LidState
+	Dock DockState
+}
+
+// LidClosed returns true if the lid is known to be closed.
+func (i Info) LidClosed() bool {
+	return i.Lid == LidClosed
+}
+
+// Docked returns true if the laptop is known to be docked.
+func (i Info) Docked() bool {
+	return i.Dock == Docked
+}
+
+// Module represents a dock bar module. It supports setting the output
+// format, callbacks for state transitions, and update frequency.
+type Module struct {
+	scheduler  timing.Scheduler
+	interval   value.TypedValue[time.Duration]
+	outputFunc value.OutputFunc[Info]
+	onChange   value.Value // of func(Info, Info)
+	onUndock   value.Value // of func()
+}
+
+// New constructs an instance of the dock module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "interval", "outputFunc", "onChange", "onUndock")
+	m.RefreshInterval(time.Second)
+	m.OnChange(nil)
+	m.OnUndock(nil)
+	// Default output is just the lid and docking states.
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("lid %s, %s", i.Lid, i.Dock)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// OnChange sets a function to be called whenever the lid or docking state
+// changes. It receives the previous and the new state, and is called in a
+// new goroutine, so it can safely perform slow operations.
+func (m *Module) OnChange(onChange func(from, to Info)) *Module {
+	if onChange == nil {
+		onChange = func(Info, Info) {}
+	}
+	m.onChange.Set(onChange)
+	return m
+}
+
+// OnUndock sets a function to be called when the laptop is undocked,
+// for example to rearrange outputs. Like OnChange, it is called in a new
+// goroutine.
+func (m *Module) OnUndock(onUndock func()) *Module {
+	if onUndock == nil {
+		onUndock = func() {}
+	}
+	m.onUndock.Set(onUndock)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the lid and dock state.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.interval.Set(interval)
+	return m
+}
+
+var fs = afero.NewOsFs()
+
+// acpidSocket is the socket on which acpid broadcasts ACPI events.
+var acpidSocket = "/var/run/acpid.socket"
+
+// subscribe returns a channel that is notified whenever acpid reports a lid
+// or dock event, and a function that unsubscribes. It is replaced in tests.
+var subscribe = subscribeAcpid
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.scheduler.Every(m.interval.Get())
+	nextInterval := m.interval.Next()
+	events, unsubscribe, err := subscribe()
+	if err == nil {
+		defer unsubscribe()
+	} else {
+		l.Fine("%s: acpid unavailable, only polling: %v", l.ID(m), err)
+	}
+	info, err := getInfo()
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+	// Only re-render when something changed, to avoid updating the bar
+	// on every tick.
+	render := true
+	for {
+		if s.Error(err) {
+			return
+		}
+		if render {
+			s.Output(outputFunc(info))
+		}
+		render = false
+		select {
+		case _, ok := <-events:
+			if !ok {
+				l.Log("%s: acpid connection closed, only polling", l.ID(m))
+				events = nil
+				continue
+			}
+			info, render, err = m.refresh(info)
+		case <-m.scheduler.Tick():
+			info, render, err = m.refresh(info)
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+			render = true
+		case <-nextInterval:
+			nextInterval = m.interval.Next()
+			m.scheduler.Every(m.interval.Get())
+		}
+	}
+}
+
+// refresh re-reads the lid and dock state, firing the transition callbacks
+// and returning true if it differs from the given state.
+func (m *Module) refresh(info Info) (Info, bool, error) {
+	newInfo, err := getInfo()
+	if err != nil {
+		return info, true, err
+	}
+	if newInfo == info {
+		return info, false, nil
+	}
+	m.fireTransition(info, newInfo)
+	return newInfo, true, nil
+}
+
+// subscribeAcpid connects to the acpid socket, notifying the returned
+// channel for each lid or dock event. The channel is closed when the
+// connection is.
+func subscribeAcpid() (<-chan struct{}, func(), error) {
+	conn, err := net.Dial("unix", acpidSocket)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Events are coalesced, since the state is re-read in full anyway.
+	events := make(chan struct{}, 1)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			if !isLidOrDockEvent(scanner.Text()) {
+				continue
+			}
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return events, func() { conn.Close() }, nil
+}
+
+// isLidOrDockEvent returns true if an acpid event line (e.g.
+// "button/lid LID close") relates to the lid switch or a dock station.
+func isLidOrDockEvent(event string) bool {
+	event = strings.ToLower(event)
+	return strings.HasPrefix(event, "button/lid") ||
+		strings.Contains(event, "dock")
+}
+
+// fireTransition calls the user callbacks for a change in state.
+func (m *Module) fireTransition(from, to Info) {
+	l.Fine("%s: %+v -> %+v", l.ID(m), from, to)
+	go m.onChange.Get().(func(Info, Info))(from, to)
+	if from.Dock == Docked && to.Dock == Undocked {
+		go m.onUndock.Get().(func())()
+	}
+}
+
+func getInfo() (i Info, err error) {
+	if i.Lid, err = getLidState(); err != nil {
+		return i, err
+	}
+	i.Dock, err = getDockState()
+	return i, err
+}
+
+// getLidState reads the state of the first lid switch reported by ACPI.
+// The file contains a line like "state:      open".
+func getLidState() (LidState, error) {
+	files, err := afero.Glob(fs, "/proc/acpi/button/lid/*/state")
+	if err != nil || len(files) == 0 {
+		return LidUnavailable, err
+	}
+	bytes, err := afero.ReadFile(fs, files[0])
+	if err != nil {
+		return LidUnavailable, err
+	}
+	state := strings.TrimSpace(strings.TrimPrefix(string(bytes), "state:"))
+	switch state {
+	case "open":
+		return LidOpen, nil
+	case "closed":
+		return LidClosed, nil
+	default:
+		return LidUnavailable, nil
+	}
+}
+
+// getDockState reads the docking state from the ACPI dock driver.
+// The laptop is considered docked if any of the dock stations reports
+// being docked.
+func getDockState() (DockState, error) {
+	files, err := afero.Glob(fs, "/sys/devices/platform/dock.*/docked")
+	if err != nil || len(files) == 0 {
+		return DockUnavailable, err
+	}
+	state := Undocked
+	for _, file := range files {
+		bytes, err := afero.ReadFile(fs, file)
+		if err != nil {
+			return DockUnavailable, err
+		}
+		if strings.TrimSpace(string(bytes)) == "1" {
+			state = Docked
+		}
+	}
+	return state, nil
+}
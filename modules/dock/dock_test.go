@@ -0,0 +1,198 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dock
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// noAcpid makes the module rely only on polling.
+func noAcpid() {
+	subscribe = func() (<-chan struct{}, func(), error) {
+		return nil, nil, errors.New("no acpid")
+	}
+}
+
+func setLid(state string) {
+	afero.WriteFile(fs, "/proc/acpi/button/lid/LID0/state",
+		[]byte("state:      "+state+"\n"), 0644)
+}
+
+func setDocked(docked string) {
+	afero.WriteFile(fs, "/sys/devices/platform/dock.0/docked",
+		[]byte(docked+"\n"), 0644)
+}
+
+func TestNoLidOrDock(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	noAcpid()
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput().AssertText([]string{"lid n/a, n/a"}, "on start")
+}
+
+func TestDock(t *testing.T) {
+	require := require.New(t)
+	fs = afero.NewMemMapFs()
+	noAcpid()
+	testBar.New(t)
+
+	setLid("open")
+	setDocked("1")
+
+	changes := make(chan [2]Info, 10)
+	undocks := make(chan struct{}, 10)
+	d := New().
+		OnChange(func(from, to Info) { changes <- [2]Info{from, to} }).
+		OnUndock(func() { undocks <- struct{}{} })
+	testBar.Run(d)
+	testBar.NextOutput().AssertText([]string{"lid open, docked"}, "on start")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("when state is unchanged")
+
+	setLid("closed")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"lid closed, docked"}, "on lid close")
+	select {
+	case c := <-changes:
+		require.Equal(Info{LidOpen, Docked}, c[0])
+		require.Equal(Info{LidClosed, Docked}, c[1])
+		require.True(c[1].LidClosed())
+	case <-time.After(time.Second):
+		require.Fail("expected transition callback on lid close")
+	}
+	select {
+	case <-undocks:
+		require.Fail("undock callback on lid close")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	d.Output(func(i Info) bar.Output {
+		if i.Docked() {
+			return outputs.Text("D")
+		}
+		return outputs.Text("U")
+	})
+	testBar.NextOutput().AssertText([]string{"D"}, "on output function change")
+
+	setDocked("0")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"U"}, "on undock")
+	select {
+	case c := <-changes:
+		require.Equal(Undocked, c[1].Dock)
+	case <-time.After(time.Second):
+		require.Fail("expected transition callback on undock")
+	}
+	select {
+	case <-undocks:
+	case <-time.After(time.Second):
+		require.Fail("expected undock callback")
+	}
+
+	setDocked("1")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"D"}, "on dock")
+	select {
+	case <-undocks:
+		require.Fail("undock callback on dock")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+// fakeAcpid listens on a temporary acpid socket, returning a channel
+// that receives each connection to it.
+func fakeAcpid(t *testing.T) <-chan net.Conn {
+	dir, err := os.MkdirTemp("", "dock")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	acpidSocket = filepath.Join(dir, "acpid.socket")
+	subscribe = subscribeAcpid
+	listener, err := net.Listen("unix", acpidSocket)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	conns := make(chan net.Conn, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return conns
+}
+
+func TestAcpid(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	conns := fakeAcpid(t)
+	testBar.New(t)
+
+	setLid("open")
+	testBar.Run(New().RefreshInterval(time.Hour))
+	testBar.NextOutput().AssertText([]string{"lid open, n/a"}, "on start")
+	var conn net.Conn
+	select {
+	case conn = <-conns:
+	case <-time.After(time.Second):
+		require.Fail(t, "expected module to connect to acpid")
+	}
+
+	setLid("closed")
+	fmt.Fprintln(conn, "ac_adapter ACPI0003:00 00000080 00000000")
+	testBar.AssertNoOutput("on unrelated acpi event")
+	fmt.Fprintln(conn, "button/lid LID close")
+	testBar.NextOutput().AssertText([]string{"lid closed, n/a"}, "on lid event")
+
+	setDocked("1")
+	fmt.Fprintln(conn, "button/volumeup VOLUP 00000080 00000000")
+	testBar.AssertNoOutput("on unrelated button event")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"lid closed, docked"},
+		"polls in addition to acpid events")
+
+	setDocked("0")
+	conn.Close()
+	testBar.AssertNoOutput("when acpid connection is closed")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"lid closed, undocked"},
+		"keeps polling without acpid")
+}
+
+func TestIsLidOrDockEvent(t *testing.T) {
+	require := require.New(t)
+	require.True(isLidOrDockEvent("button/lid LID open"))
+	require.True(isLidOrDockEvent("button/lid LID0 00000080 00000001"))
+	require.True(isLidOrDockEvent("dock GDCK 00000003 00000001"))
+	require.True(isLidOrDockEvent("ACPI_DOCK 00000000 00000001"))
+	require.False(isLidOrDockEvent("button/power PBTN 00000080 00000000"))
+	require.False(isLidOrDockEvent("ac_adapter ACPI0003:00 00000080 00000001"))
+	require.False(isLidOrDockEvent(""))
+}
@@ -49,7 +49,7 @@ func (n Notifications) Total() int {
 
 type Module struct {
 	config     *oauth.Config
-	outputFunc value.Value // of func(Notifications) bar.Output
+	outputFunc value.OutputFunc[Notifications]
 
 	// Use the poll interval and last modified from the previous response to
 	// control when we next check for notifications.
@@ -90,7 +90,7 @@ func (m *Module) Stream(sink bar.Sink) {
 	if wrapForTest != nil {
 		wrapForTest(client)
 	}
-	outf := m.outputFunc.Get().(func(Notifications) bar.Output)
+	outf := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	info, err := m.getNotifications(client)
 	for {
@@ -104,7 +104,7 @@ func (m *Module) Stream(sink bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outf = m.outputFunc.Get().(func(Notifications) bar.Output)
+			outf = m.outputFunc.Get()
 		case <-m.scheduler.Tick():
 			i, e := m.getNotifications(client)
 			err = e
@@ -54,7 +54,7 @@ type Module struct {
 	config     *oauth.Config
 	labels     []string
 	scheduler  timing.Scheduler
-	outputFunc value.Value // of func(Info) bar.Output
+	outputFunc value.OutputFunc[Info]
 }
 
 func New(clientConfig []byte, labels ...string) *Module {
@@ -100,7 +100,7 @@ func (m *Module) Stream(sink bar.Sink) {
 	for _, l := range r.Labels {
 		labelIDs[l.Name] = l.Id
 	}
-	outf := m.outputFunc.Get().(func(Info) bar.Output)
+	outf := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		i := Info{
@@ -119,7 +119,7 @@ func (m *Module) Stream(sink bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outf = m.outputFunc.Get().(func(Info) bar.Output)
+			outf = m.outputFunc.Get()
 		case <-m.scheduler.Tick():
 		}
 	}
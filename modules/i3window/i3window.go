@@ -0,0 +1,273 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package i3window provides an i3bar module that displays the title of the
+focused window, using the i3 (or sway) IPC interface.
+
+This is useful on bars without window title bars, and unlike the window
+manager's own title display, can be styled like any other barista module.
+*/
+package i3window // import "barista.run/modules/i3window"
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the focused window.
+type Info struct {
+	// ID is the window manager's identifier for the window's container.
+	ID int64
+	// Title is the title of the window.
+	Title string
+	// AppID is the application ID of a native wayland window (sway only).
+	AppID string
+	// Class and Instance are the X11 WM_CLASS properties of the window.
+	Class    string
+	Instance string
+}
+
+// Empty returns true if no window is focused, e.g. on an empty workspace.
+func (i Info) Empty() bool {
+	return i.ID == 0
+}
+
+// App returns the best available identifier for the focused application:
+// the wayland app ID if available, and the X11 class otherwise.
+func (i Info) App() string {
+	if i.AppID != "" {
+		return i.AppID
+	}
+	return i.Class
+}
+
+// TruncatedTitle returns the title shortened to at most max characters,
+// ending with an ellipsis if truncated. Truncation is by character, not byte,
+// so the result is always valid UTF-8 and can be safely passed to pango.Text,
+// which takes care of escaping markup.
+func (i Info) TruncatedTitle(max int) string {
+	runes := []rune(i.Title)
+	if max <= 0 || len(runes) <= max {
+		return i.Title
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+func infoFrom(n node) Info {
+	return Info{
+		ID:       n.ID,
+		Title:    n.Name,
+		AppID:    n.AppID,
+		Class:    n.WindowProperties.Class,
+		Instance: n.WindowProperties.Instance,
+	}
+}
+
+// Module represents an i3window bar module.
+type Module struct {
+	outputFunc value.OutputFunc[Info]
+}
+
+// New constructs a module that displays the title of the focused window.
+func New() *Module {
+	m := &Module{}
+	l.Register(m, "outputFunc")
+	m.Output(func(i Info) bar.Output {
+		if i.Empty() {
+			return nil
+		}
+		return outputs.Text(i.TruncatedTitle(64))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+var (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// update is a change to the focused window, received from the IPC goroutine.
+type update func(*Info) bool
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+
+	reconnect := timing.NewScheduler()
+	l.Attach(m, reconnect, "reconnect")
+	delay := minReconnectDelay
+
+	updates := make(chan update)
+	errs := make(chan error)
+	go m.subscribe(updates, errs)
+
+	i := Info{}
+	for {
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case u := <-updates:
+			delay = minReconnectDelay
+			if !u(&i) {
+				continue
+			}
+		case err := <-errs:
+			l.Log("%s disconnected, reconnecting in %v: %v", l.ID(m), delay, err)
+			reconnect.After(delay)
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		case <-reconnect.Tick():
+			go m.subscribe(updates, errs)
+			continue
+		}
+		s.Output(outputFunc(i))
+	}
+}
+
+// subscribe connects to the window manager, and sends updates for the
+// focused window until the connection is lost.
+func (m *Module) subscribe(updates chan<- update, errs chan<- error) {
+	path, err := socketPath()
+	if err != nil {
+		errs <- err
+		return
+	}
+	conn, err := dial(path)
+	if err != nil {
+		errs <- err
+		return
+	}
+	c := newIpcConn(conn)
+	defer c.close()
+	if err := c.write(msgSubscribe, []byte(`["window","workspace"]`)); err != nil {
+		errs <- err
+		return
+	}
+	// Events are only sent after subscribing, so fetching the tree after
+	// the subscription ensures that no changes are missed.
+	if err := c.write(msgGetTree, nil); err != nil {
+		errs <- err
+		return
+	}
+	for {
+		typ, payload, err := c.read()
+		if err != nil {
+			errs <- err
+			return
+		}
+		u, err := parse(typ, payload)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if u != nil {
+			updates <- u
+		}
+	}
+}
+
+// parse converts a message from the window manager into an update to the
+// focused window. It returns a nil update for messages that are irrelevant.
+func parse(typ uint32, payload []byte) (update, error) {
+	switch typ {
+	case msgSubscribe:
+		var reply struct {
+			Success bool `json:"success"`
+		}
+		if err := json.Unmarshal(payload, &reply); err != nil {
+			return nil, err
+		}
+		if !reply.Success {
+			return nil, errSubscribe
+		}
+	case msgGetTree:
+		var tree node
+		if err := json.Unmarshal(payload, &tree); err != nil {
+			return nil, err
+		}
+		return func(i *Info) bool {
+			*i = Info{}
+			if f, ok := tree.focused(); ok && f.isWindow() {
+				*i = infoFrom(f)
+			}
+			return true
+		}, nil
+	case eventWindow:
+		var e windowEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		return e.apply, nil
+	case eventWorkspace:
+		var e workspaceEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		return e.apply, nil
+	}
+	return nil, nil
+}
+
+var errSubscribe = errors.New("i3 ipc: subscription failed")
+
+func (e windowEvent) apply(i *Info) bool {
+	switch e.Change {
+	case "focus":
+		*i = infoFrom(e.Container)
+		return true
+	case "title":
+		if e.Container.ID == i.ID || e.Container.Focused {
+			*i = infoFrom(e.Container)
+			return true
+		}
+	case "close":
+		if e.Container.ID == i.ID {
+			*i = Info{}
+			return true
+		}
+	}
+	return false
+}
+
+func (e workspaceEvent) apply(i *Info) bool {
+	// Focusing an empty workspace does not generate a window event, so the
+	// previously focused window would otherwise remain displayed.
+	if e.Change != "focus" || e.Current == nil || i.Empty() {
+		return false
+	}
+	if len(e.Current.Nodes) > 0 || len(e.Current.FloatingNodes) > 0 {
+		return false
+	}
+	*i = Info{}
+	return true
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3window
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWM is the window manager side of a connection from the module.
+type fakeWM struct {
+	*ipcConn
+	t *testing.T
+}
+
+var (
+	servers  chan net.Conn
+	sockErrs chan error
+)
+
+func init() {
+	socketPath = func() (string, error) {
+		select {
+		case err := <-sockErrs:
+			return "", err
+		default:
+			return "/run/user/1000/i3/ipc-socket", nil
+		}
+	}
+	dial = func(path string) (net.Conn, error) {
+		client, server := net.Pipe()
+		servers <- server
+		return client, nil
+	}
+}
+
+func accept(t *testing.T, tree string) *fakeWM {
+	w := &fakeWM{newIpcConn(<-servers), t}
+	typ, payload, err := w.read()
+	require.NoError(t, err)
+	require.Equal(t, uint32(msgSubscribe), typ, "expected SUBSCRIBE")
+	var events []string
+	require.NoError(t, json.Unmarshal(payload, &events))
+	require.Contains(t, events, "window")
+
+	// net.Pipe is unbuffered, so both requests must be read before replying.
+	typ, _, err = w.read()
+	require.NoError(t, err)
+	require.Equal(t, uint32(msgGetTree), typ, "expected GET_TREE")
+	require.NoError(t, w.write(msgSubscribe, []byte(`{"success":true}`)))
+	require.NoError(t, w.write(msgGetTree, []byte(tree)))
+	return w
+}
+
+func (w *fakeWM) event(typ uint32, payload string) {
+	require.NoError(w.t, w.write(typ, []byte(payload)))
+}
+
+const tree = `{"id":1,"type":"root","nodes":[{"id":2,"type":"output","nodes":[
+	{"id":3,"type":"workspace","nodes":[
+		{"id":10,"type":"con","name":"vim","focused":false,
+		 "window_properties":{"class":"URxvt","instance":"urxvt"}},
+		{"id":11,"type":"con","name":"Inbox - Mail","focused":true,"app_id":"thunderbird"}
+	]}
+]}]}`
+
+func setup(t *testing.T) {
+	servers = make(chan net.Conn, 1)
+	sockErrs = make(chan error, 1)
+	testBar.New(t)
+}
+
+func TestFocusedWindow(t *testing.T) {
+	setup(t)
+	m := New()
+	testBar.Run(m)
+
+	w := accept(t, tree)
+	testBar.NextOutput().AssertText([]string{"Inbox - Mail"}, "from tree")
+
+	w.event(eventWindow, `{"change":"focus","container":{"id":10,"type":"con",
+		"name":"vim","focused":true,"window_properties":{"class":"URxvt"}}}`)
+	testBar.NextOutput().AssertText([]string{"vim"}, "on focus change")
+
+	w.event(eventWindow, `{"change":"title","container":{"id":11,"type":"con",
+		"name":"Re: Lunch - Mail","focused":false}}`)
+	testBar.AssertNoOutput("on title change of unfocused window")
+
+	w.event(eventWindow, `{"change":"title","container":{"id":10,"type":"con",
+		"name":"vim README.md","focused":true,"window_properties":{"class":"URxvt"}}}`)
+	testBar.NextOutput().AssertText([]string{"vim README.md"}, "on title change")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s: %s", i.App(), i.TruncatedTitle(5))
+	})
+	testBar.NextOutput().AssertText([]string{"URxvt: vim …"}, "on output func change")
+
+	w.event(eventWindow, `{"change":"move","container":{"id":10,"type":"con"}}`)
+	testBar.AssertNoOutput("on irrelevant change")
+
+	w.event(eventWindow, `{"change":"close","container":{"id":10,"type":"con"}}`)
+	testBar.NextOutput().AssertText([]string{": "}, "on close")
+
+	w.event(eventWindow, `{"change":"focus","container":{"id":11,"type":"con",
+		"name":"Inbox - Mail","focused":true,"app_id":"thunderbird"}}`)
+	testBar.NextOutput().AssertText([]string{"thunderbird: Inbo…"})
+
+	w.event(eventWorkspace, `{"change":"focus","current":{"id":4,"type":"workspace",
+		"nodes":[{"id":12,"type":"con","name":"firefox"}]}}`)
+	testBar.AssertNoOutput("on focusing a non-empty workspace")
+
+	w.event(eventWorkspace, `{"change":"focus","current":{"id":5,"type":"workspace"}}`)
+	testBar.NextOutput().AssertText([]string{": "}, "on focusing an empty workspace")
+}
+
+func TestEmptyWorkspace(t *testing.T) {
+	setup(t)
+	testBar.Run(New())
+	accept(t, `{"id":1,"type":"root","nodes":[{"id":2,"type":"output","nodes":[
+		{"id":3,"type":"workspace","focused":true}]}]}`)
+	testBar.NextOutput().AssertEmpty("without a focused window")
+}
+
+func TestReconnection(t *testing.T) {
+	setup(t)
+	testBar.Run(New())
+	w := accept(t, tree)
+	testBar.NextOutput().AssertText([]string{"Inbox - Mail"})
+
+	w.close()
+	testBar.AssertNoOutput("on disconnection")
+
+	sockErrs <- errors.New("i3 is restarting")
+	testBar.Tick()
+	testBar.AssertNoOutput("when socket is unavailable")
+
+	testBar.Tick()
+	w = accept(t, tree)
+	testBar.NextOutput().AssertText([]string{"Inbox - Mail"}, "on reconnection")
+
+	w.event(eventWindow, `{"change":"title","container":{"id":11,"type":"con",
+		"name":"Drafts - Mail","focused":true}}`)
+	testBar.NextOutput().AssertText([]string{"Drafts - Mail"})
+}
+
+func TestFailedSubscription(t *testing.T) {
+	setup(t)
+	testBar.Run(New())
+	w := &fakeWM{newIpcConn(<-servers), t}
+	for range []string{"subscribe", "get_tree"} {
+		_, _, err := w.read()
+		require.NoError(t, err)
+	}
+	w.event(msgSubscribe, `{"success":false}`)
+	testBar.AssertNoOutput("on failed subscription")
+	testBar.Tick()
+	accept(t, tree)
+	testBar.NextOutput().AssertText([]string{"Inbox - Mail"}, "after retrying")
+}
+
+func TestTruncatedTitle(t *testing.T) {
+	i := Info{Title: "Ünïcödé <b>title</b>"}
+	require.Equal(t, "Ünïc…", i.TruncatedTitle(5))
+	require.Equal(t, i.Title, i.TruncatedTitle(20))
+	require.Equal(t, i.Title, i.TruncatedTitle(0), "no limit")
+}
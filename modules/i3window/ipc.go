@@ -0,0 +1,169 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i3window
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipcMagic is the prefix of every i3 IPC message, in both directions.
+const ipcMagic = "i3-ipc"
+
+// i3 IPC message and event types. Events have the highest bit set.
+const (
+	msgSubscribe   = 2
+	msgGetTree     = 4
+	eventWorkspace = 0x80000000
+	eventWindow    = 0x80000003
+)
+
+// maxMessageSize limits the size of messages accepted from the window
+// manager. Trees of very busy sessions can be large, but not this large.
+const maxMessageSize = 64 << 20
+
+// node is the subset of an i3/sway tree node used by this module.
+type node struct {
+	ID               int64  `json:"id"`
+	Type             string `json:"type"`
+	Name             string `json:"name"`
+	Focused          bool   `json:"focused"`
+	AppID            string `json:"app_id"`
+	WindowProperties struct {
+		Class    string `json:"class"`
+		Instance string `json:"instance"`
+	} `json:"window_properties"`
+	Nodes         []node `json:"nodes"`
+	FloatingNodes []node `json:"floating_nodes"`
+}
+
+// isWindow returns true if the node is a leaf container holding a window.
+func (n node) isWindow() bool {
+	return strings.HasSuffix(n.Type, "con") &&
+		len(n.Nodes) == 0 && len(n.FloatingNodes) == 0
+}
+
+// focused returns the focused node in the tree rooted at n.
+func (n node) focused() (node, bool) {
+	if n.Focused {
+		return n, true
+	}
+	for _, children := range [][]node{n.Nodes, n.FloatingNodes} {
+		for _, c := range children {
+			if f, ok := c.focused(); ok {
+				return f, true
+			}
+		}
+	}
+	return node{}, false
+}
+
+// windowEvent is the payload of a WINDOW event.
+type windowEvent struct {
+	Change    string `json:"change"`
+	Container node   `json:"container"`
+}
+
+// workspaceEvent is the payload of a WORKSPACE event.
+type workspaceEvent struct {
+	Change  string `json:"change"`
+	Current *node  `json:"current"`
+}
+
+// ipcConn is a minimal i3 IPC client, supporting only what is needed to
+// subscribe to events and fetch the tree.
+type ipcConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	writeMu sync.Mutex
+}
+
+func newIpcConn(conn net.Conn) *ipcConn {
+	return &ipcConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// write sends a message of the given type. i3 uses the native byte order,
+// which is little-endian on all platforms barista supports.
+func (c *ipcConn) write(typ uint32, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	msg := append([]byte(ipcMagic), make([]byte, 8)...)
+	binary.LittleEndian.PutUint32(msg[len(ipcMagic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[len(ipcMagic)+4:], typ)
+	_, err := c.conn.Write(append(msg, payload...))
+	return err
+}
+
+// read reads a single message (reply or event) from the connection.
+func (c *ipcConn) read() (typ uint32, payload []byte, err error) {
+	header := make([]byte, len(ipcMagic)+8)
+	if _, err = io.ReadFull(c.r, header); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[:len(ipcMagic)], []byte(ipcMagic)) {
+		return 0, nil, errors.New("i3 ipc: bad magic")
+	}
+	size := binary.LittleEndian.Uint32(header[len(ipcMagic):])
+	if size > maxMessageSize {
+		return 0, nil, fmt.Errorf("i3 ipc: message too large (%d bytes)", size)
+	}
+	typ = binary.LittleEndian.Uint32(header[len(ipcMagic)+4:])
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
+
+func (c *ipcConn) close() error {
+	return c.conn.Close()
+}
+
+// socketPath finds the IPC socket of the running window manager, using the
+// environment if possible, and asking i3 or sway otherwise.
+var socketPath = func() (string, error) {
+	for _, env := range []string{"SWAYSOCK", "I3SOCK"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	var err error
+	for _, wm := range []string{"i3", "sway"} {
+		var out []byte
+		out, err = exec.Command(wm, "--get-socketpath").Output()
+		if err != nil {
+			continue
+		}
+		if path := strings.TrimSpace(string(out)); path != "" {
+			return path, nil
+		}
+		err = fmt.Errorf("%s returned an empty socket path", wm)
+	}
+	return "", fmt.Errorf("i3 ipc: could not find socket: %v", err)
+}
+
+var dial = func(path string) (net.Conn, error) {
+	return net.DialTimeout("unix", path, 5*time.Second)
+}
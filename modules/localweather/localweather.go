@@ -0,0 +1,283 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package localweather provides an i3bar module that displays readings from a
+personal weather station, received over MQTT.
+
+The station (or a bridge such as a home automation system) is expected to
+publish either JSON objects with numeric fields to the subscribed topic, e.g.
+
+	{"temperature": 21.3, "humidity": 48, "pressure": 1012.5}
+
+or a plain number to a topic per field, e.g. "21.3" to "station/temperature",
+in which case the last level of the topic is used as the field name.
+Subscribing to a wildcard topic (e.g. "station/#") merges fields from all
+matching topics.
+
+Temperatures are expected in degrees Celsius, pressure in hectopascals
+(millibars), and humidity as a percentage.
+*/
+package localweather // import "barista.run/modules/localweather"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Reading represents the latest readings from the weather station.
+type Reading struct {
+	Temperature unit.Temperature
+	Humidity    float64
+	Pressure    unit.Pressure
+	// Values contains all numeric fields received from the station,
+	// including the ones above, keyed by field name.
+	Values map[string]float64
+	// Updated is the time of the last message from the station.
+	Updated time.Time
+	// Connected is true while the module is connected to the MQTT broker.
+	Connected bool
+}
+
+// Has returns true if the given field has been received from the station.
+func (r Reading) Has(field string) bool {
+	_, ok := r.Values[field]
+	return ok
+}
+
+// Module represents a bar.Module that displays local weather station readings.
+type Module struct {
+	broker     string
+	topic      string
+	opts       connectOptions
+	fields     [3]string // temperature, humidity, pressure
+	outputFunc value.OutputFunc[Reading]
+}
+
+// New constructs a local weather module that subscribes to the given topic on
+// the MQTT broker at the given address (host:port).
+func New(broker, topic string) *Module {
+	host, _ := os.Hostname()
+	m := &Module{
+		broker: broker,
+		topic:  topic,
+		opts: connectOptions{
+			clientID:  fmt.Sprintf("barista-%s-%d", host, os.Getpid()),
+			keepAlive: time.Minute,
+		},
+		fields: [3]string{"temperature", "humidity", "pressure"},
+	}
+	l.Label(m, topic)
+	l.Register(m, "outputFunc")
+	// Default output is temperature and humidity.
+	m.Output(func(r Reading) bar.Output {
+		if r.Updated.IsZero() {
+			return nil
+		}
+		return outputs.Textf("%.1f℃ %.0f%%", r.Temperature.Celsius(), r.Humidity)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Reading) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Auth sets the credentials used to connect to the MQTT broker.
+// Must be called before the module is streaming.
+func (m *Module) Auth(username, password string) *Module {
+	m.opts.username = username
+	m.opts.password = password
+	return m
+}
+
+// ClientID overrides the MQTT client identifier, which defaults to a value
+// based on the hostname and process ID. Must be called before the module is
+// streaming.
+func (m *Module) ClientID(clientID string) *Module {
+	m.opts.clientID = clientID
+	return m
+}
+
+// Fields sets the names of the fields used for temperature, humidity, and
+// pressure, which default to "temperature", "humidity", and "pressure".
+// Must be called before the module is streaming.
+func (m *Module) Fields(temperature, humidity, pressure string) *Module {
+	m.fields = [3]string{temperature, humidity, pressure}
+	return m
+}
+
+// Reconnection delays, doubling on each consecutive failure.
+var (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// dial connects to the MQTT broker, replaced in tests.
+var dial = func(addr string) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, 10*time.Second)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+
+	reconnect := timing.NewScheduler()
+	l.Attach(m, reconnect, "reconnect")
+	delay := minReconnectDelay
+
+	connected := make(chan struct{})
+	msgs := make(chan message)
+	errs := make(chan error)
+	go m.subscribe(connected, msgs, errs)
+
+	r := Reading{}
+	for {
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-connected:
+			l.Fine("%s connected to %s", l.ID(m), m.broker)
+			delay = minReconnectDelay
+			r.Connected = true
+		case msg := <-msgs:
+			if !m.update(&r, msg) {
+				continue
+			}
+		case err := <-errs:
+			if err, ok := err.(ConnectError); ok && !err.temporary() {
+				s.Error(err)
+				return
+			}
+			l.Log("%s disconnected, reconnecting in %v: %v", l.ID(m), delay, err)
+			reconnect.After(delay)
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			if !r.Connected {
+				continue
+			}
+			r.Connected = false
+		case <-reconnect.Tick():
+			go m.subscribe(connected, msgs, errs)
+			continue
+		}
+		s.Output(outputFunc(r))
+	}
+}
+
+// subscribe connects to the broker and subscribes to the topic, sending
+// received messages to msgs until the connection fails. The error that
+// caused the connection to fail is sent to errs.
+func (m *Module) subscribe(connected chan<- struct{}, msgs chan<- message, errs chan<- error) {
+	conn, err := dial(m.broker)
+	if err != nil {
+		errs <- err
+		return
+	}
+	c, err := mqttConnect(conn, m.opts)
+	if err != nil {
+		conn.Close()
+		errs <- err
+		return
+	}
+	defer c.close()
+	if err := c.subscribe(m.topic); err != nil {
+		errs <- err
+		return
+	}
+	connected <- struct{}{}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(m.opts.keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.ping()
+			case <-done:
+				return
+			}
+		}
+	}()
+	for {
+		msg, err := c.next()
+		if err != nil {
+			errs <- err
+			return
+		}
+		msgs <- msg
+	}
+}
+
+// update merges the values from a message into the reading, returning
+// false if the message could not be parsed.
+func (m *Module) update(r *Reading, msg message) bool {
+	values := map[string]float64{}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(msg.payload, &fields); err == nil {
+		// Stations often include non-numeric fields (e.g. a model name or
+		// timestamp) alongside the readings, so only those are skipped.
+		for k, v := range fields {
+			if f, ok := v.(float64); ok {
+				values[k] = f
+			}
+		}
+		if len(values) == 0 {
+			l.Fine("%s ignoring message without numeric fields on %s: %q",
+				l.ID(m), msg.topic, msg.payload)
+			return false
+		}
+	} else {
+		val, err := strconv.ParseFloat(strings.TrimSpace(string(msg.payload)), 64)
+		if err != nil {
+			l.Log("%s ignoring unparseable message on %s: %q",
+				l.ID(m), msg.topic, msg.payload)
+			return false
+		}
+		values = map[string]float64{msg.topic[strings.LastIndex(msg.topic, "/")+1:]: val}
+	}
+	merged := map[string]float64{}
+	for k, v := range r.Values {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	r.Values = merged
+	r.Temperature = unit.FromCelsius(merged[m.fields[0]])
+	r.Humidity = merged[m.fields[1]]
+	r.Pressure = unit.Pressure(merged[m.fields[2]]) * unit.Hectopascal
+	r.Updated = timing.Now()
+	return true
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localweather
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker is the server side of a connection from the module.
+type fakeBroker struct {
+	*mqttConn
+	t       *testing.T
+	connect []byte
+}
+
+var servers chan net.Conn
+
+func init() {
+	dial = func(addr string) (net.Conn, error) {
+		if addr == "unreachable:1883" {
+			return nil, errors.New("connection refused")
+		}
+		client, server := net.Pipe()
+		servers <- server
+		return client, nil
+	}
+}
+
+func accept(t *testing.T) *fakeBroker {
+	b := &fakeBroker{t: t}
+	conn := <-servers
+	b.mqttConn = &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+	typ, body, err := b.read()
+	require.NoError(t, err)
+	require.Equal(t, byte(pktConnect), typ>>4, "expected CONNECT")
+	b.connect = body
+	return b
+}
+
+func (b *fakeBroker) connack(code byte) {
+	require.NoError(b.t, b.write(pktConnack<<4, []byte{0, code}))
+}
+
+func (b *fakeBroker) expectSubscribe(topic string) {
+	typ, body, err := b.read()
+	require.NoError(b.t, err)
+	require.Equal(b.t, byte(pktSubscribe<<4|0x02), typ, "expected SUBSCRIBE")
+	actual, _, err := readString(body[2:])
+	require.NoError(b.t, err)
+	require.Equal(b.t, topic, actual)
+	require.NoError(b.t, b.write(pktSuback<<4, append(body[:2], 0)))
+}
+
+func (b *fakeBroker) publish(topic, payload string) {
+	body := appendString(nil, topic)
+	require.NoError(b.t, b.write(pktPublish<<4, append(body, payload...)))
+}
+
+func (b *fakeBroker) publishQoS1(topic, payload string, id uint16) {
+	body := appendString(nil, topic)
+	body = binary.BigEndian.AppendUint16(body, id)
+	require.NoError(b.t, b.write(pktPublish<<4|0x02, append(body, payload...)))
+	typ, ack, err := b.read()
+	require.NoError(b.t, err)
+	require.Equal(b.t, byte(pktPuback<<4), typ, "expected PUBACK")
+	require.Equal(b.t, id, binary.BigEndian.Uint16(ack))
+}
+
+func setup(t *testing.T) {
+	servers = make(chan net.Conn, 1)
+	testBar.New(t)
+}
+
+func TestReadings(t *testing.T) {
+	setup(t)
+	m := New("broker:1883", "station/#").ClientID("test").Auth("user", "pass")
+	testBar.Run(m)
+
+	b := accept(t)
+	require.Contains(t, string(b.connect), "test", "client id")
+	require.Contains(t, string(b.connect), "user", "username")
+	require.Contains(t, string(b.connect), "pass", "password")
+	b.connack(0)
+	b.expectSubscribe("station/#")
+	testBar.NextOutput().AssertEmpty("no readings yet")
+
+	b.publish("station/all", `{"temperature": 21.34, "humidity": 48.2, "pressure": 1012}`)
+	testBar.NextOutput().AssertText([]string{"21.3℃ 48%"})
+
+	b.publish("station/humidity", "52")
+	testBar.NextOutput().AssertText([]string{"21.3℃ 52%"}, "plain value from topic")
+
+	b.publish("station/garbage", "not a number")
+	testBar.AssertNoOutput("on unparseable message")
+
+	m.Output(func(r Reading) bar.Output {
+		return outputs.Textf("%.0fhPa %v %v", r.Pressure.Hectopascals(),
+			r.Has("wind"), r.Values["wind"])
+	})
+	testBar.NextOutput().AssertText([]string{"1012hPa false 0"}, "on output func change")
+
+	b.publishQoS1("station/wind", "3.5", 42)
+	testBar.NextOutput().AssertText([]string{"1012hPa true 3.5"}, "QoS 1 message")
+}
+
+func TestCustomFields(t *testing.T) {
+	setup(t)
+	m := New("broker:1883", "station").Fields("temp_c", "rh", "baro")
+	m.Output(func(r Reading) bar.Output {
+		return outputs.Textf("%.1f %.0f %.0f", r.Temperature.Celsius(),
+			r.Humidity, r.Pressure.Millibars())
+	})
+	testBar.Run(m)
+
+	b := accept(t)
+	b.connack(0)
+	b.expectSubscribe("station")
+	testBar.NextOutput().AssertText([]string{"-273.1 0 0"}, "on connection")
+	b.publish("station", `{"temp_c": -2.5, "rh": 80, "baro": 998, "temperature": 40}`)
+	testBar.NextOutput().AssertText([]string{"-2.5 80 998"})
+}
+
+func TestMixedTypeFields(t *testing.T) {
+	setup(t)
+	m := New("broker:1883", "station")
+	m.Output(func(r Reading) bar.Output {
+		return outputs.Textf("%.1f %.0f %v %v", r.Temperature.Celsius(),
+			r.Humidity, r.Has("model"), r.Has("battery_ok"))
+	})
+	testBar.Run(m)
+
+	b := accept(t)
+	b.connack(0)
+	b.expectSubscribe("station")
+	testBar.NextOutput().AssertText([]string{"-273.1 0 false false"}, "on connection")
+
+	b.publish("station", `{"time": "2024-05-01 10:00:00", "model": "Acurite-Tower",
+		"id": 1234, "battery_ok": true, "temperature": 18.5, "humidity": 61,
+		"extra": {"channel": "A"}, "flags": [1, 2], "mic": null}`)
+	testBar.NextOutput().AssertText([]string{"18.5 61 false false"},
+		"numeric fields from message with other types")
+
+	b.publish("station", `{"model": "Acurite-Tower", "status": "ok"}`)
+	testBar.AssertNoOutput("on message without numeric fields")
+}
+
+func TestReconnection(t *testing.T) {
+	setup(t)
+	m := New("broker:1883", "station")
+	m.Output(func(r Reading) bar.Output {
+		return outputs.Textf("%.0f %v", r.Values["temperature"], r.Connected)
+	})
+	testBar.Run(m)
+
+	b := accept(t)
+	b.connack(0)
+	b.expectSubscribe("station")
+	testBar.NextOutput().AssertText([]string{"0 true"}, "on connection")
+	b.publish("station", `{"temperature": 20}`)
+	testBar.NextOutput().AssertText([]string{"20 true"})
+
+	b.conn.Close()
+	testBar.NextOutput().AssertText([]string{"20 false"}, "on disconnection")
+	testBar.AssertNoOutput("until reconnected")
+
+	testBar.Tick()
+	b = accept(t)
+	b.connack(3)
+	testBar.AssertNoOutput("when reconnection fails")
+
+	testBar.Tick()
+	b = accept(t)
+	b.connack(0)
+	b.expectSubscribe("station")
+	testBar.NextOutput().AssertText([]string{"20 true"}, "on reconnection")
+	b.publish("station", `{"temperature": 22}`)
+	testBar.NextOutput().AssertText([]string{"22 true"})
+}
+
+func TestErrors(t *testing.T) {
+	setup(t)
+	m := New("broker:1883", "station").Auth("user", "wrong")
+	testBar.Run(m)
+	b := accept(t)
+	b.connack(4)
+	errs := testBar.NextOutput().AssertError("on refused connection")
+	require.Equal(t, []string{"mqtt: bad user name or password"}, errs)
+
+	setup(t)
+	m = New("unreachable:1883", "station")
+	testBar.Run(m)
+	testBar.AssertNoOutput("when broker is unreachable")
+	testBar.Tick()
+	testBar.AssertNoOutput("when broker is still unreachable")
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localweather
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MQTT control packet types, as defined by the MQTT 3.1.1 specification.
+const (
+	pktConnect    = 1
+	pktConnack    = 2
+	pktPublish    = 3
+	pktPuback     = 4
+	pktSubscribe  = 8
+	pktSuback     = 9
+	pktPingreq    = 12
+	pktPingresp   = 13
+	pktDisconnect = 14
+)
+
+// maxPacketSize limits the size of packets accepted from the broker.
+// Weather station payloads are tiny, so 1MiB is more than enough.
+const maxPacketSize = 1 << 20
+
+// ConnectError is returned when the broker refuses the connection. Except
+// when the server is unavailable, these indicate configuration problems (e.g.
+// bad credentials), so the module does not attempt to reconnect.
+type ConnectError byte
+
+// temporary returns true if the connection may succeed if retried.
+func (e ConnectError) temporary() bool {
+	return e == 3
+}
+
+func (e ConnectError) Error() string {
+	switch e {
+	case 1:
+		return "mqtt: unacceptable protocol version"
+	case 2:
+		return "mqtt: client identifier rejected"
+	case 3:
+		return "mqtt: server unavailable"
+	case 4:
+		return "mqtt: bad user name or password"
+	case 5:
+		return "mqtt: not authorized"
+	}
+	return fmt.Sprintf("mqtt: connection refused (%d)", byte(e))
+}
+
+// message is a message published to a subscribed topic.
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// connectOptions holds the options sent with the MQTT CONNECT packet.
+type connectOptions struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive time.Duration
+}
+
+// mqttConn is a minimal MQTT 3.1.1 client, supporting only what is needed to
+// subscribe to a topic and receive messages published with QoS 0 or 1.
+type mqttConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	keepAlive time.Duration
+	writeMu   sync.Mutex
+}
+
+// mqttConnect performs the MQTT handshake over the given connection.
+func mqttConnect(conn net.Conn, opts connectOptions) (*mqttConn, error) {
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn), keepAlive: opts.keepAlive}
+	flags := byte(0x02) // clean session.
+	var payload []byte
+	payload = appendString(payload, opts.clientID)
+	if opts.username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.username)
+	}
+	if opts.password != "" {
+		flags |= 0x40
+		payload = appendString(payload, opts.password)
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.keepAlive/time.Second))
+	body = append(body, payload...)
+	if err := c.write(pktConnect<<4, body); err != nil {
+		return nil, err
+	}
+	typ, body, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if typ>>4 != pktConnack || len(body) != 2 {
+		return nil, errors.New("mqtt: expected CONNACK")
+	}
+	if body[1] != 0 {
+		return nil, ConnectError(body[1])
+	}
+	return c, nil
+}
+
+// subscribe subscribes to the given topic filter, and waits for the broker
+// to acknowledge the subscription.
+func (c *mqttConn) subscribe(topic string) error {
+	const packetID = 1
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	body = appendString(body, topic)
+	body = append(body, 0) // QoS 0.
+	if err := c.write(pktSubscribe<<4|0x02, body); err != nil {
+		return err
+	}
+	typ, body, err := c.read()
+	if err != nil {
+		return err
+	}
+	if typ>>4 != pktSuback || len(body) != 3 ||
+		binary.BigEndian.Uint16(body) != packetID {
+		return errors.New("mqtt: expected SUBACK")
+	}
+	if body[2] == 0x80 {
+		return fmt.Errorf("mqtt: subscription to %q rejected", topic)
+	}
+	return nil
+}
+
+// next returns the next message published to a subscribed topic,
+// acknowledging it if required and skipping any other packets.
+func (c *mqttConn) next() (message, error) {
+	for {
+		typ, body, err := c.read()
+		if err != nil {
+			return message{}, err
+		}
+		switch typ >> 4 {
+		case pktPingresp:
+			continue
+		case pktPublish:
+		default:
+			return message{}, fmt.Errorf("mqtt: unexpected packet type %d", typ>>4)
+		}
+		topic, rest, err := readString(body)
+		if err != nil {
+			return message{}, err
+		}
+		if qos := (typ >> 1) & 0x03; qos > 0 {
+			if len(rest) < 2 {
+				return message{}, errors.New("mqtt: malformed PUBLISH")
+			}
+			// Only QoS 0 is requested when subscribing, but the broker may
+			// still deliver QoS 1 messages, which require an acknowledgement.
+			if err := c.write(pktPuback<<4, rest[:2]); err != nil {
+				return message{}, err
+			}
+			rest = rest[2:]
+		}
+		return message{topic, rest}, nil
+	}
+}
+
+// ping sends a keep-alive ping to the broker.
+func (c *mqttConn) ping() error {
+	return c.write(pktPingreq<<4, nil)
+}
+
+// close disconnects from the broker and closes the connection.
+func (c *mqttConn) close() {
+	c.write(pktDisconnect<<4, nil)
+	c.conn.Close()
+}
+
+func (c *mqttConn) write(header byte, body []byte) error {
+	pkt := []byte{header}
+	for l := len(body); ; {
+		b := byte(l % 128)
+		l /= 128
+		if l > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if l == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func (c *mqttConn) read() (header byte, body []byte, err error) {
+	if c.keepAlive > 0 {
+		// The broker should respond to pings within the keep-alive interval,
+		// so a connection without any packets for longer is considered dead.
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+	}
+	if header, err = c.r.ReadByte(); err != nil {
+		return header, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return header, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return header, nil, errors.New("mqtt: malformed remaining length")
+		}
+	}
+	if length > maxPacketSize {
+		return header, nil, fmt.Errorf("mqtt: packet too large (%d bytes)", length)
+	}
+	body = make([]byte, length)
+	_, err = io.ReadFull(c.r, body)
+	return header, body, err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	l := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+l {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	return string(b[2 : 2+l]), b[2+l:], nil
+}
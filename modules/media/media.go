@@ -16,7 +16,9 @@
 package media // import "barista.run/modules/media"
 
 import (
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"barista.run/bar"
@@ -154,7 +156,9 @@ type Controller interface {
 // from an MPRIS-compatible media player.
 type Module struct {
 	playerName string
-	outputFunc value.Value // of func(Info) bar.Output
+	outputFunc value.OutputFunc[Info]
+	formatters formatters // the output functions combined in outputFunc.
+	formatMu   sync.Mutex // for read-modify-write of formatters.
 
 	// player state, updated from dbus signals.
 	info value.Value // of Info
@@ -179,10 +183,56 @@ func New(player string) *Module {
 	return m
 }
 
+// formatters holds the user-defined output functions for each state.
+type formatters struct {
+	playing func(Info) bar.Output
+	paused  func(Info) bar.Output
+	stopped func() bar.Output
+}
+
+// format returns the output for the given info using the formatter
+// appropriate for the current playback state. If no formatter is set for
+// the paused or stopped state, the output function is used instead.
+func (f formatters) format(i Info) bar.Output {
+	switch {
+	case !i.Connected() || i.Stopped():
+		if f.stopped != nil {
+			return f.stopped()
+		}
+	case i.Paused():
+		if f.paused != nil {
+			return f.paused(i)
+		}
+	}
+	return f.playing(i)
+}
+
+// updateFormatters atomically modifies the module's formatters.
+func (m *Module) updateFormatters(fn func(*formatters)) *Module {
+	m.formatMu.Lock()
+	defer m.formatMu.Unlock()
+	fn(&m.formatters)
+	m.outputFunc.Set(m.formatters.format)
+	return m
+}
+
 // Output configures a module to display the output of a user-defined function.
+// Unless overridden by OnPaused or OnStopped, this function is used for all
+// playback states, including when the player is not running.
 func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
-	m.outputFunc.Set(outputFunc)
-	return m
+	return m.updateFormatters(func(f *formatters) { f.playing = outputFunc })
+}
+
+// OnPaused configures a module to display the output of a user-defined
+// function while the player is paused.
+func (m *Module) OnPaused(pausedFunc func(Info) bar.Output) *Module {
+	return m.updateFormatters(func(f *formatters) { f.paused = pausedFunc })
+}
+
+// OnStopped configures a module to display the output of a user-defined
+// function while the player is stopped or not running.
+func (m *Module) OnStopped(stoppedFunc func() bar.Output) *Module {
+	return m.updateFormatters(func(f *formatters) { f.stopped = stoppedFunc })
 }
 
 // Throttle seek calls to once every ~50ms to allow more control
@@ -230,7 +280,7 @@ func (m *Module) Stream(s bar.Sink) {
 	}
 
 	info := Info{}
-	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 
 	m.player = newMprisPlayer(sessionBus, m.playerName, &info)
@@ -253,15 +303,23 @@ func (m *Module) Stream(s bar.Sink) {
 	dbusCh := make(chan *dbus.Signal, 10)
 	sessionBus.Signal(dbusCh)
 
+	info.Controller = m.player
+	s.Output(outputs.Group(outputFunc(info)).
+		OnClick(defaultClickHandler(info)))
+
 	for {
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			outputFunc = m.outputFunc.Get()
 			info.Controller = m.player
 			s.Output(outputs.Group(outputFunc(info)).
 				OnClick(defaultClickHandler(info)))
-		case v := <-dbusCh:
+		case v, ok := <-dbusCh:
+			if !ok {
+				s.Error(errors.New("dbus connection closed"))
+				return
+			}
 			updates, err := m.player.handleDbusSignal(v)
 			if s.Error(err) {
 				return
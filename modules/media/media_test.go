@@ -14,4 +14,234 @@
 
 package media
 
-// Empty test file for more accurate code coverage.
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testOutput "barista.run/testing/output"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/prop"
+	"github.com/stretchr/testify/require"
+)
+
+func format(m *Module, i Info) bar.Output {
+	return m.outputFunc.Get()(i)
+}
+
+func TestDefaultFormat(t *testing.T) {
+	m := New("test-player")
+	require.Nil(t, format(m, Info{}), "when disconnected")
+	for _, status := range []PlaybackStatus{Playing, Paused, Stopped} {
+		testOutput.New(t, format(m, Info{PlaybackStatus: status, Title: "Song"})).
+			AssertText([]string{"Song"}, "when %s", status)
+	}
+}
+
+func TestFormatTransitions(t *testing.T) {
+	m := New("test-player").
+		Output(func(i Info) bar.Output { return outputs.Textf("> %s", i.Title) }).
+		OnPaused(func(i Info) bar.Output { return outputs.Textf("|| %s", i.Title) }).
+		OnStopped(func() bar.Output { return outputs.Text("[]") })
+
+	transitions := []struct {
+		status   PlaybackStatus
+		expected string
+	}{
+		{Disconnected, "[]"},
+		{Stopped, "[]"},
+		{Playing, "> Song"},
+		{Paused, "|| Song"},
+		{Playing, "> Song"},
+		{Stopped, "[]"},
+		{Paused, "|| Song"},
+		{Disconnected, "[]"},
+		{Playing, "> Song"},
+		{Disconnected, "[]"},
+	}
+	for idx, tc := range transitions {
+		i := Info{PlaybackStatus: tc.status, Title: "Song"}
+		testOutput.New(t, format(m, i)).
+			AssertText([]string{tc.expected}, "transition #%d to %q", idx, tc.status)
+	}
+}
+
+func TestPartialFormatters(t *testing.T) {
+	m := New("test-player").
+		Output(func(i Info) bar.Output { return outputs.Text(string(i.PlaybackStatus)) }).
+		OnStopped(func() bar.Output { return nil })
+
+	testOutput.New(t, format(m, Info{PlaybackStatus: Paused})).
+		AssertText([]string{"Paused"}, "output func used when paused if not overridden")
+	require.Nil(t, format(m, Info{PlaybackStatus: Stopped}),
+		"stopped func used when stopped")
+
+	m.OnStopped(nil).OnPaused(func(Info) bar.Output { return outputs.Text("paused") })
+	testOutput.New(t, format(m, Info{PlaybackStatus: Stopped})).
+		AssertText([]string{"Stopped"}, "output func used when stopped if unset")
+	testOutput.New(t, format(m, Info{PlaybackStatus: Paused})).
+		AssertText([]string{"paused"}, "paused func used when paused")
+}
+
+const busConfig = `<busconfig>
+  <type>session</type>
+  <listen>unix:tmpdir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`
+
+// startBus starts a private session bus and points the session bus address
+// at it. It returns the bus address, and a function that stops the bus,
+// which can safely be called more than once.
+// The test is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) (string, func()) {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+	dir, err := ioutil.TempDir("", "media-bus")
+	require.NoError(t, err)
+	config := filepath.Join(dir, "session.conf")
+	require.NoError(t, ioutil.WriteFile(config,
+		[]byte(strings.Replace(busConfig, "%s", dir, 1)), 0644))
+
+	cmd := exec.Command(daemon, "--nofork", "--print-address",
+		"--config-file="+config)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	addr = strings.TrimSpace(addr)
+
+	oldAddr, hadAddr := os.LookupEnv("DBUS_SESSION_BUS_ADDRESS")
+	os.Setenv("DBUS_SESSION_BUS_ADDRESS", addr)
+	var stopOnce sync.Once
+	return addr, func() {
+		stopOnce.Do(func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+			os.RemoveAll(dir)
+			if hadAddr {
+				os.Setenv("DBUS_SESSION_BUS_ADDRESS", oldAddr)
+			} else {
+				os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+			}
+		})
+	}
+}
+
+// fakePlayer is an MPRIS player on the test bus, which only exports the
+// player properties.
+type fakePlayer struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+func newFakePlayer(t *testing.T, addr, name string, status PlaybackStatus, title string) *fakePlayer {
+	conn, err := dbus.Dial(addr)
+	require.NoError(t, err)
+	require.NoError(t, conn.Auth(nil))
+	require.NoError(t, conn.Hello())
+	emit := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	props := prop.New(conn, "/org/mpris/MediaPlayer2", map[string]map[string]*prop.Prop{
+		mprisInterface: {
+			mprisStatus.member:   emit(string(status)),
+			mprisMetadata.member: emit(metadata(title)),
+			mprisShuffle.member:  emit(false),
+			mprisPosition.member: {Value: int64(0), Emit: prop.EmitFalse},
+			mprisRate.member:     emit(1.0),
+		},
+	})
+	reply, err := conn.RequestName("org.mpris.MediaPlayer2."+name,
+		dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+	return &fakePlayer{conn, props}
+}
+
+func metadata(title string) map[string]dbus.Variant {
+	return map[string]dbus.Variant{"xesam:title": dbus.MakeVariant(title)}
+}
+
+func (p *fakePlayer) set(prop name, value interface{}) {
+	p.props.SetMust(mprisInterface, prop.member, value)
+}
+
+func (p *fakePlayer) quit() {
+	p.conn.Close()
+}
+
+func TestStream(t *testing.T) {
+	addr, stopBus := startBus(t)
+	defer stopBus()
+	testBar.New(t)
+
+	m := New("fake").
+		Output(func(i Info) bar.Output { return outputs.Textf("> %s", i.Title) }).
+		OnPaused(func(i Info) bar.Output { return outputs.Textf("|| %s", i.Title) }).
+		OnStopped(func() bar.Output { return outputs.Text("[]") })
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"[]"}, "on start without player")
+
+	player := newFakePlayer(t, addr, "fake", Playing, "Song")
+	testBar.NextOutput().AssertText([]string{"> Song"}, "when player appears")
+
+	player.set(mprisStatus, string(Paused))
+	testBar.NextOutput().AssertText([]string{"|| Song"}, "playing -> paused")
+	player.set(mprisStatus, string(Stopped))
+	testBar.NextOutput().AssertText([]string{"[]"}, "paused -> stopped")
+	player.set(mprisStatus, string(Playing))
+	testBar.NextOutput().AssertText([]string{"> Song"}, "stopped -> playing")
+	player.set(mprisMetadata, metadata("Other Song"))
+	testBar.NextOutput().AssertText([]string{"> Other Song"}, "on track change")
+	player.set(mprisStatus, string(Stopped))
+	testBar.NextOutput().AssertText([]string{"[]"}, "playing -> stopped")
+	player.set(mprisStatus, string(Paused))
+	testBar.NextOutput().AssertText([]string{"|| Other Song"}, "stopped -> paused")
+
+	player.quit()
+	testBar.NextOutput().AssertText([]string{"[]"}, "when player disappears")
+
+	player = newFakePlayer(t, addr, "fake", Paused, "Song")
+	testBar.NextOutput().AssertText([]string{"|| Song"}, "when player reappears")
+	player.set(mprisStatus, string(Playing))
+	testBar.NextOutput().AssertText([]string{"> Song"}, "paused -> playing")
+	player.quit()
+	testBar.NextOutput().AssertText([]string{"[]"}, "when player quits while playing")
+}
+
+func TestStreamOtherPlayer(t *testing.T) {
+	addr, stopBus := startBus(t)
+	defer stopBus()
+	testBar.New(t)
+
+	testBar.Run(New("fake"))
+	testBar.NextOutput().AssertEmpty("on start without player")
+
+	other := newFakePlayer(t, addr, "other", Playing, "Other")
+	other.set(mprisStatus, string(Paused))
+	testBar.AssertNoOutput("for a different player")
+
+	player := newFakePlayer(t, addr, "fake", Playing, "Song")
+	testBar.NextOutput().AssertText([]string{"Song"}, "when player appears")
+	other.quit()
+	testBar.AssertNoOutput("when a different player quits")
+	player.quit()
+	testBar.NextOutput().AssertEmpty("when player disappears")
+}
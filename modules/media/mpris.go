@@ -176,14 +176,16 @@ func (m *mprisPlayer) handleDbusSignal(signal *dbus.Signal) (updates, error) {
 			// don't send empty info on start.
 			*m.info = Info{}
 		}
-		if len(newName) > 0 {
-			m.addMatches(newName)
-			m.getInitialInfo()
-		} else {
+		if len(newName) == 0 {
 			// Empty newName => player disconnected from dbus.
 			m.info.PlaybackStatus = Disconnected
 			return updates{true, true, true}, m.err
 		}
+		m.addMatches(newName)
+		m.getInitialInfo()
+		// Not all players send their state on startup, so show the
+		// initial info right away.
+		return updates{true, true, true}, m.err
 	}
 	return updates{}, m.err
 }
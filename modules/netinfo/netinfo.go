@@ -46,7 +46,7 @@ func (s State) Enabled() bool {
 // Module represents a netinfo bar module.
 type Module struct {
 	subscriber func() netlink.Subscription
-	outputFunc value.Value // of func(State) bar.Output
+	outputFunc value.OutputFunc[State]
 }
 
 // netWithSubscriber constructs a netinfo module using the given
@@ -100,7 +100,7 @@ func (m *Module) Output(outputFunc func(State) bar.Output) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	var state State
-	outputFunc := m.outputFunc.Get().(func(State) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	linkCh := m.subscriber()
 	defer linkCh.Unsubscribe()
@@ -111,7 +111,7 @@ func (m *Module) Stream(s bar.Sink) {
 			state = State{update}
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(State) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 		s.Output(outputFunc(state))
 	}
@@ -0,0 +1,60 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	l "barista.run/logging"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+var fs = afero.NewOsFs()
+
+const sysNetPath = "/sys/class/net"
+
+// linkCapacity returns the combined negotiated speed of the interfaces, or 0
+// if the speed of any of them is unknown.
+func linkCapacity(ifaces []string) unit.Datarate {
+	var total unit.Datarate
+	for _, iface := range ifaces {
+		speed := linkSpeed(iface)
+		if speed <= 0 {
+			return 0
+		}
+		total += speed
+	}
+	return total
+}
+
+// linkSpeed reads the negotiated speed of an interface from sysfs. The kernel
+// reports the speed in Mbit/s, with -1 (or an error reading the file) for
+// interfaces where the speed is unknown.
+func linkSpeed(iface string) unit.Datarate {
+	bytes, err := afero.ReadFile(fs, filepath.Join(sysNetPath, iface, "speed"))
+	if err != nil {
+		l.Fine("netspeed: speed of %s: %v", iface, err)
+		return 0
+	}
+	mbps, err := strconv.Atoi(strings.TrimSpace(string(bytes)))
+	if err != nil || mbps <= 0 {
+		return 0
+	}
+	return unit.Datarate(mbps) * unit.MegabitPerSecond
+}
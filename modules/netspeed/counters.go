@@ -0,0 +1,103 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+// counters is a snapshot of the cumulative counters of an interface.
+type counters struct {
+	rxBytes, txBytes     uint64
+	rxPackets, txPackets uint64
+	rxErrors, txErrors   uint64
+	rxDropped, txDropped uint64
+}
+
+// fields returns pointers to all counters, for element-wise operations.
+func (c *counters) fields() []*uint64 {
+	return []*uint64{
+		&c.rxBytes, &c.txBytes,
+		&c.rxPackets, &c.txPackets,
+		&c.rxErrors, &c.txErrors,
+		&c.rxDropped, &c.txDropped,
+	}
+}
+
+// before returns true if any counter in c is lower than in other, which
+// happens when the interface's counters are reset.
+func (c counters) before(other counters) bool {
+	mine, theirs := c.fields(), other.fields()
+	for i := range mine {
+		if *mine[i] < *theirs[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// sub returns the element-wise difference c - other.
+func (c counters) sub(other counters) counters {
+	mine, theirs := c.fields(), other.fields()
+	for i := range mine {
+		*mine[i] -= *theirs[i]
+	}
+	return c
+}
+
+// plus returns the element-wise sum c + other.
+func (c counters) plus(other counters) counters {
+	mine, theirs := c.fields(), other.fields()
+	for i := range mine {
+		*mine[i] += *theirs[i]
+	}
+	return c
+}
+
+// totals accumulates the counters of multiple interfaces.
+type totals struct {
+	last  map[string]counters
+	total counters
+}
+
+// add updates the totals with the latest counters for an interface. The first
+// reading of an interface only establishes a baseline. If the counters were
+// reset (e.g. the interface went down and came back), they are counted from
+// zero.
+func (t *totals) add(iface string, c counters) {
+	prev, ok := t.last[iface]
+	t.last[iface] = c
+	switch {
+	case !ok:
+	case c.before(prev):
+		t.total = t.total.plus(c)
+	default:
+		t.total = t.total.plus(c.sub(prev))
+	}
+}
+
+func linkStats(iface string) (counters, error) {
+	link, err := linkByName(iface)
+	if err != nil {
+		return counters{}, err
+	}
+	st := link.Attrs().Statistics
+	return counters{
+		rxBytes:   st.RxBytes,
+		txBytes:   st.TxBytes,
+		rxPackets: st.RxPackets,
+		txPackets: st.TxPackets,
+		rxErrors:  st.RxErrors,
+		txErrors:  st.TxErrors,
+		rxDropped: st.RxDropped,
+		txDropped: st.TxDropped,
+	}, nil
+}
@@ -16,9 +16,16 @@
 package netspeed // import "barista.run/modules/netspeed"
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
 	l "barista.run/logging"
 	"barista.run/outputs"
@@ -31,37 +38,274 @@ import (
 // Speeds represents bidirectional network traffic.
 type Speeds struct {
 	Rx, Tx unit.Datarate
+	// Packet, error, and dropped packet rates, per second.
+	RxPackets, TxPackets float64
+	RxErrors, TxErrors   float64
+	RxDropped, TxDropped float64
+	// Severity of traffic in each direction, based on the thresholds
+	// set using RxThresholds and TxThresholds.
+	RxSeverity, TxSeverity outputs.Severity
+	// Bytes transferred since the module started, or since the totals
+	// were last reset using ResetTotals.
+	totalRx, totalTx unit.Datasize
+	// Highest speeds seen since the module started, or since the peaks
+	// were last reset using ResetPeaks.
+	maxRx, maxTx unit.Datarate
+	// Negotiated speed of the link(s), or 0 if unknown.
+	capacity unit.Datarate
+	// Signal strength of the wireless link in dBm, and as a percentage,
+	// if enabled using WithSignal. Both are 0 for wired interfaces, which
+	// can be distinguished using HasSignal.
+	SignalDBm, SignalPercent int
+	hasSignal                bool
 	// Keep track of whether these speeds are actually 0
 	// or uninitialised.
 	available bool
 }
 
+// speedsFrom computes speeds from the change in counters over a duration in
+// seconds.
+func speedsFrom(delta counters, elapsed float64) Speeds {
+	perSec := func(v uint64) float64 { return float64(v) / elapsed }
+	return Speeds{
+		Rx:        unit.Datarate(perSec(delta.rxBytes)) * unit.BytePerSecond,
+		Tx:        unit.Datarate(perSec(delta.txBytes)) * unit.BytePerSecond,
+		RxPackets: perSec(delta.rxPackets),
+		TxPackets: perSec(delta.txPackets),
+		RxErrors:  perSec(delta.rxErrors),
+		TxErrors:  perSec(delta.txErrors),
+		RxDropped: perSec(delta.rxDropped),
+		TxDropped: perSec(delta.txDropped),
+		available: true,
+	}
+}
+
+// TotalRx returns the total bytes received since the module started, or since
+// the last call to ResetTotals.
+func (s Speeds) TotalRx() unit.Datasize {
+	return s.totalRx
+}
+
+// TotalTx returns the total bytes sent since the module started, or since the
+// last call to ResetTotals.
+func (s Speeds) TotalTx() unit.Datasize {
+	return s.totalTx
+}
+
+// smooth blends the latest rate into the previous average.
+func smooth(alpha float64, avg, latest unit.Datarate) unit.Datarate {
+	return unit.Datarate(alpha)*latest + unit.Datarate(1-alpha)*avg
+}
+
+// MaxRx returns the highest receive speed since the module started, or since
+// the last call to ResetPeaks.
+func (s Speeds) MaxRx() unit.Datarate {
+	return s.maxRx
+}
+
+// MaxTx returns the highest send speed since the module started, or since the
+// last call to ResetPeaks.
+func (s Speeds) MaxTx() unit.Datarate {
+	return s.maxTx
+}
+
+func maxRate(a, b unit.Datarate) unit.Datarate {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Capacity returns the negotiated speed of the link, or 0 if it is unknown
+// (e.g. for most wireless and virtual interfaces). For multiple interfaces,
+// this is the sum of their speeds, and is unknown unless all speeds are known.
+func (s Speeds) Capacity() unit.Datarate {
+	return s.capacity
+}
+
+// HasSignal returns true if the signal strength fields are set, i.e. the
+// module was configured using WithSignal and the interface is wireless.
+func (s Speeds) HasSignal() bool {
+	return s.hasSignal
+}
+
+// Utilization returns the speed of the busier direction as a percentage of
+// the link capacity, or -1 if the capacity is unknown.
+func (s Speeds) Utilization() float64 {
+	if s.capacity <= 0 {
+		return -1
+	}
+	return float64(maxRate(s.Rx, s.Tx)) / float64(s.capacity) * 100.0
+}
+
 // Total gets the total speed (both up and down).
 func (s Speeds) Total() unit.Datarate {
 	return s.Rx + s.Tx
 }
 
+// String returns the speeds in SI byte units, e.g. "↓1.2 MB/s ↑340 kB/s", or an
+// empty string if the speeds are not available yet.
+func (s Speeds) String() string {
+	return fmt.Sprint(s)
+}
+
+// Format implements fmt.Formatter, so that the verb selects the units: %v, %s,
+// and %d format the speeds in byte units, as String does, while %b uses bit
+// units. The '#' flag selects IEC units instead of SI, e.g. "%#d" formats the
+// speeds as "↓1.2 MiB/s ↑340 KiB/s". Width and the '-' flag pad the result.
+func (s Speeds) Format(f fmt.State, verb rune) {
+	var rate func(unit.Datarate) string
+	switch {
+	case verb == 'b' && f.Flag('#'):
+		rate = outputs.IBitrate
+	case verb == 'b':
+		rate = outputs.Bitrate
+	case f.Flag('#'):
+		rate = outputs.IByterate
+	default:
+		rate = outputs.Byterate
+	}
+	var out string
+	if s.available {
+		out = fmt.Sprintf("↓%s ↑%s", rate(s.Rx), rate(s.Tx))
+	}
+	switch verb {
+	case 'v', 's', 'd', 'b':
+	default:
+		fmt.Fprintf(f, "%%!%c(netspeed.Speeds=%s)", verb, out)
+		return
+	}
+	if width, ok := f.Width(); ok {
+		if f.Flag('-') {
+			out = fmt.Sprintf("%-*s", width, out)
+		} else {
+			out = fmt.Sprintf("%*s", width, out)
+		}
+	}
+	io.WriteString(f, out)
+}
+
 // Module represents a netspeed bar module. It supports setting the output
 // format, click handler, and update frequency.
 type Module struct {
-	iface      string
-	scheduler  timing.Scheduler
-	outputFunc value.Value // of func(Speeds) bar.Output
+	ifaces       []string
+	multi        bool
+	defaultRoute bool
+	// resolve returns the interfaces to read, for modules that do not use
+	// a fixed set of interfaces.
+	resolve func() ([]string, error)
+	// enumerate is true if the interfaces are resolved again on each
+	// refresh, and read as a single total across changes.
+	enumerate    bool
+	filter       value.TypedValue[func(netlink.Link) bool]
+	scheduler    timing.Scheduler
+	display      timing.Scheduler
+	outputFunc   value.OutputFunc[Speeds]
+	onDisconnect value.TypedValue[func() bar.Output]
+	onClick      value.TypedValue[func(bar.Event)]
+
+	resetTotalsFn func()
+	resetTotalsCh <-chan struct{}
+	resetPeaksFn  func()
+	resetPeaksCh  <-chan struct{}
+	refreshFn     func()
+	refreshCh     <-chan struct{}
+
+	interval        value.TypedValue[time.Duration]
+	displayInterval value.TypedValue[time.Duration]
+	averageOver     value.TypedValue[time.Duration]
+	smoothing       value.TypedValue[float64]
+	signal          value.TypedValue[bool]
+
+	rx, tx       direction
+	directionsMu sync.Mutex
 }
 
 // New constructs an instance of the netspeed module for the given interface.
 func New(iface string) *Module {
+	return newModule([]string{iface}, false)
+}
+
+// NewMulti constructs an instance of the netspeed module that displays the
+// combined speeds of all the given interfaces. Unlike New, a missing or
+// failing interface does not stop the module; it is simply left out of the
+// total until it is available again.
+func NewMulti(ifaces ...string) *Module {
+	return newModule(ifaces, true)
+}
+
+// NewDefaultRoute constructs an instance of the netspeed module that follows
+// the interface of the current default route, switching interfaces as routes
+// change. While there is no default route, the OnDisconnect output is
+// displayed.
+func NewDefaultRoute() *Module {
+	m := newModule(nil, false)
+	m.defaultRoute = true
+	m.resolve = func() ([]string, error) {
+		iface, err := defaultRouteIface()
+		return ifaceList(iface), err
+	}
+	l.Label(m, "default")
+	return m
+}
+
+// NewPattern constructs an instance of the netspeed module for the first
+// interface whose name matches the given pattern, using the syntax of
+// filepath.Match (e.g. "wl*"). The pattern is resolved when the module starts,
+// and again whenever the chosen interface goes away. If several interfaces
+// match, the first interface (sorted by name) that is up and has a carrier is
+// used, followed by the first interface that is up, and then the first
+// matching interface. While no interface matches, the OnDisconnect output is
+// displayed.
+func NewPattern(pattern string) *Module {
+	m := newModule(nil, false)
+	m.resolve = func() ([]string, error) {
+		iface, err := matchLink(pattern)
+		return ifaceList(iface), err
+	}
+	l.Label(m, pattern)
+	return m
+}
+
+// NewPhysical constructs an instance of the netspeed module that displays the
+// combined speeds of all physical interfaces that are up, ignoring loopback
+// and virtual interfaces (see IsPhysical, and Filter to change the rules).
+// The interfaces are listed again on each refresh, so interfaces that are
+// added or come up are included in the total from then on.
+func NewPhysical() *Module {
+	m := newModule(nil, true)
+	m.enumerate = true
+	m.resolve = func() ([]string, error) {
+		return filterLinks(m.filter.Get())
+	}
+	m.Filter(IsPhysical)
+	l.Label(m, "physical")
+	return m
+}
+
+func newModule(ifaces []string, multi bool) *Module {
 	m := &Module{
-		iface:     iface,
+		ifaces:    ifaces,
+		multi:     multi,
 		scheduler: timing.NewScheduler(),
+		display:   timing.NewScheduler(),
 	}
-	l.Label(m, iface)
-	l.Register(m, "scheduler", "outputFunc")
+	m.resetTotalsFn, m.resetTotalsCh = notifier.New()
+	m.resetPeaksFn, m.resetPeaksCh = notifier.New()
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Label(m, strings.Join(ifaces, ","))
+	l.Register(m, "scheduler", "display", "outputFunc", "onDisconnect", "onClick", "interval", "displayInterval", "averageOver", "smoothing", "signal", "filter")
+	m.OnDisconnect(func() bar.Output { return nil })
+	m.OnClick(nil)
 	m.RefreshInterval(3 * time.Second)
-	// Default output is just the up and down speeds in SI.
+	m.Smoothing(1.0)
+	// Default output is just the up and down speeds in IEC units, padded
+	// to the widest possible rate (e.g. "1023.9 KiB/s") so that the bar
+	// does not shift around as the speeds change.
+	rate := outputs.IByterateFormat().Width(12)
 	m.Output(func(s Speeds) bar.Output {
 		return outputs.Textf("%s up | %s down",
-			outputs.IByterate(s.Tx), outputs.IByterate(s.Rx))
+			rate.Format(s.Tx), rate.Format(s.Rx))
 	})
 	return m
 }
@@ -72,64 +316,475 @@ func (m *Module) Output(outputFunc func(Speeds) bar.Output) *Module {
 	return m
 }
 
+// OnDisconnect configures the output displayed while there is no interface to
+// read: the interface does not exist (for example while wifi is disabled), no
+// interface matches the pattern given to NewPattern, or there is no default
+// route for NewDefaultRoute. The module keeps checking for the interface on
+// each refresh, and resumes displaying speeds once it returns. By default
+// nothing is displayed.
+func (m *Module) OnDisconnect(outputFunc func() bar.Output) *Module {
+	m.onDisconnect.Set(outputFunc)
+	return m
+}
+
+// Filter sets the function that selects the interfaces to include, for modules
+// constructed using NewPhysical. It has no effect on other modules.
+func (m *Module) Filter(filter func(netlink.Link) bool) *Module {
+	m.filter.Set(filter)
+	return m
+}
+
+// OnClick sets a click handler for the module. It applies to all outputs,
+// except for segments that already have their own click handler.
+func (m *Module) OnClick(f func(bar.Event)) *Module {
+	m.onClick.Set(f)
+	return m
+}
+
+// minRefreshInterval is the shortest supported refresh interval.
+const minRefreshInterval = 100 * time.Millisecond
+
 // RefreshInterval configures the polling frequency for network speed.
 // Since there is no concept of an instantaneous network speed, the speeds will
-// be averaged over this interval before being displayed.
+// be averaged over this interval before being displayed. Intervals shorter
+// than 100ms are not supported, and are increased to 100ms.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	if interval < minRefreshInterval {
+		l.Warn(m, "refresh interval too short", "interval", interval, "min", minRefreshInterval)
+		interval = minRefreshInterval
+	}
+	m.interval.Set(interval)
 	m.scheduler.Every(interval)
 	return m
 }
 
+// ResetTotals resets the cumulative totals returned by Speeds.TotalRx and
+// Speeds.TotalTx to zero.
+func (m *Module) ResetTotals() {
+	m.resetTotalsFn()
+}
+
+// ResetPeaks resets the peak speeds returned by Speeds.MaxRx and Speeds.MaxTx
+// to the current speeds.
+func (m *Module) ResetPeaks() {
+	m.resetPeaksFn()
+}
+
+// Refresh samples the interfaces immediately, without waiting for the next
+// refresh interval, e.g. after resuming from suspend. Rates are still computed
+// over the actual time between samples, but the time since the previous sample
+// may be short, so frequent refreshes can produce noisy rates. A refresh soon
+// after a long gap (such as a suspend) starts a new averaging window.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// DisplayInterval configures the module to re-render the output at the given
+// interval, using the last computed speeds, independently of how often the
+// interface is sampled. This is useful for output functions that display
+// time-dependent information. A zero interval (the default) only renders the
+// output when the speeds are updated.
+func (m *Module) DisplayInterval(interval time.Duration) *Module {
+	m.displayInterval.Set(interval)
+	m.armDisplay(interval)
+	return m
+}
+
+// armDisplay sets the display scheduler for the given display interval.
+func (m *Module) armDisplay(interval time.Duration) {
+	if interval <= 0 {
+		m.display.Stop()
+	} else {
+		m.display.Every(interval)
+	}
+}
+
+// WithSignal configures the module to also read the signal strength of
+// wireless interfaces on each refresh. Interfaces that are not wireless
+// devices are still shown, but without a signal (see Speeds.HasSignal).
+// For multiple interfaces, the first wireless interface is used.
+func (m *Module) WithSignal() *Module {
+	m.signal.Set(true)
+	return m
+}
+
+// AverageOver configures the period over which speeds are averaged, without
+// changing how often the output is updated. For example, a refresh interval of
+// 1s with an averaging window of 10s produces a smooth rate that still updates
+// every second. A zero duration (the default) averages over the last refresh
+// interval.
+func (m *Module) AverageOver(d time.Duration) *Module {
+	m.averageOver.Set(d)
+	return m
+}
+
+// Smoothing configures an exponentially weighted moving average of the rx and
+// tx speeds, applied separately to each direction. Alpha is the weight given
+// to the newest rate, so smaller values produce smoother but less responsive
+// speeds. An alpha of 1 (the default) disables smoothing, and values outside
+// (0, 1] are treated as 1. Speeds are only available once the first rate has
+// been computed, which is then used as the initial average.
+func (m *Module) Smoothing(alpha float64) *Module {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	m.smoothing.Set(alpha)
+	return m
+}
+
+// spans returns the averaging window, and the longest gap between samples
+// before the window is considered stale (for example after a suspend).
+func (m *Module) spans() (span, maxGap time.Duration) {
+	span = m.averageOver.Get()
+	interval := m.interval.Get()
+	return span, span + 2*interval
+}
+
 // For tests.
 var linkByName = netlink.LinkByName
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	lastRead := timing.Now()
-	lastRx, lastTx, err := linkRxTx(m.iface)
-	if s.Error(err) {
+	m.StreamContext(context.Background(), s)
+}
+
+// StreamContext starts the module, and returns once the context is done,
+// stopping any further sampling of the interfaces until the module is
+// streamed again.
+func (m *Module) StreamContext(ctx context.Context, s bar.Sink) {
+	// Re-arm the schedulers, in case they were stopped when the context of
+	// an earlier stream was done.
+	m.scheduler.Every(m.interval.Get())
+	m.armDisplay(m.displayInterval.Get())
+	var routes chan netlink.RouteUpdate
+	if m.defaultRoute {
+		routes = make(chan netlink.RouteUpdate, 16)
+		done := make(chan struct{})
+		defer close(done)
+		if s.Error(routeSubscribe(routes, done)) {
+			return
+		}
+	}
+	ifaces := m.ifaces
+	if m.resolve != nil {
+		var err error
+		ifaces, err = m.resolve()
+		if s.Error(err) {
+			return
+		}
+	}
+
+	st := &stream{Module: m, session: &totals{last: map[string]counters{}}}
+	if s.Error(st.follow(ifaces)) {
 		return
 	}
 
-	var speeds Speeds
-	outputFunc := m.outputFunc.Get().(func(Speeds) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
+	nextOnDisconnect := m.onDisconnect.Next()
+	nextOnClick := m.onClick.Next()
+	st.render(s, outputFunc)
 
 	for {
-		if speeds.available {
-			s.Output(outputFunc(speeds))
-		}
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Speeds) bar.Output)
+			outputFunc = m.outputFunc.Get()
+		case <-m.resetTotalsCh:
+			st.session.total = counters{}
+			st.speeds.totalRx, st.speeds.totalTx = 0, 0
+		case <-m.resetPeaksCh:
+			st.speeds.maxRx, st.speeds.maxTx = st.speeds.Rx, st.speeds.Tx
+		case <-nextOnDisconnect:
+			nextOnDisconnect = m.onDisconnect.Next()
+			if !st.absent() {
+				continue
+			}
+		case <-nextOnClick:
+			nextOnClick = m.onClick.Next()
+		case _, ok := <-routes:
+			if !ok {
+				s.Error(errRouteSubscription)
+				return
+			}
+			ifaces, err := m.resolve()
+			if s.Error(err) {
+				return
+			}
+			if sameIfaces(st.ifaces, ifaces) {
+				continue
+			}
+			l.Fine("%s: default route now via %v", l.ID(m), ifaces)
+			wasAbsent := st.absent()
+			if s.Error(st.follow(ifaces)) {
+				return
+			}
+			if wasAbsent && !st.absent() {
+				// Clear the disconnected output until speeds are available.
+				s.Output(nil)
+				continue
+			}
+		case <-ctx.Done():
+			m.scheduler.Stop()
+			m.display.Stop()
+			return
+		case <-m.display.Tick():
+		case <-m.refreshCh:
+			changed, err := st.update()
+			if s.Error(err) {
+				return
+			}
+			if !changed {
+				continue
+			}
 		case <-m.scheduler.Tick():
-			rx, tx, err := linkRxTx(m.iface)
+			changed, err := st.update()
 			if s.Error(err) {
 				return
 			}
-			now := timing.Now()
-			duration := now.Sub(lastRead).Seconds()
+			if !changed {
+				continue
+			}
+		}
+		st.render(s, outputFunc)
+	}
+}
 
-			speeds.available = true
-			speeds.Rx = unit.Datarate(float64(rx-lastRx)/duration) * unit.BytePerSecond
-			speeds.Tx = unit.Datarate(float64(tx-lastTx)/duration) * unit.BytePerSecond
+// stream holds the state of a single run of the module's Stream.
+type stream struct {
+	*Module
+	ifaces []string
+	// key identifies the interfaces for the session totals. It is kept when
+	// enumerated interfaces change, since they are read as a single total.
+	key          string
+	read         func() (counters, error)
+	w            window
+	speeds       Speeds
+	capacity     unit.Datarate
+	disconnected bool
+	// session accumulates the bytes transferred, handling counter resets.
+	// It is keyed by the interfaces being read, so switching interfaces
+	// establishes a new baseline instead of counting the difference.
+	session *totals
+}
 
-			lastRead = now
-			lastRx = rx
-			lastTx = tx
-		}
+// noRoute returns true if the module follows the default route, and there is
+// no default route.
+func (st *stream) noRoute() bool {
+	return st.defaultRoute && len(st.ifaces) == 0
+}
+
+// absent returns true if there is no interface to read, because it does not
+// exist, or there is no default route.
+func (st *stream) absent() bool {
+	return st.disconnected || st.noRoute()
+}
+
+// clear discards any samples and speeds, but keeps the session totals and
+// peaks.
+func (st *stream) clear() {
+	st.w.reset()
+	st.speeds = Speeds{
+		totalRx: st.speeds.totalRx,
+		totalTx: st.speeds.totalTx,
+		maxRx:   st.speeds.maxRx,
+		maxTx:   st.speeds.maxTx,
+	}
+}
+
+// follow switches to a new set of interfaces, discarding any samples and
+// speeds from the previous ones.
+func (st *stream) follow(ifaces []string) error {
+	st.ifaces = ifaces
+	st.disconnected = false
+	st.clear()
+	if len(ifaces) == 0 {
+		st.disconnected = !st.noRoute()
+		return nil
+	}
+	st.key = strings.Join(ifaces, ",")
+	st.read = newReader(func() []string { return st.ifaces }, st.multi)
+	c, err := st.read()
+	if isMissing(err) {
+		st.disconnected = true
+		return nil
 	}
+	if err != nil {
+		return err
+	}
+	st.capacity = linkCapacity(ifaces)
+	st.record(c)
+	return nil
 }
 
-func linkRxTx(iface string) (rx, tx uint64, err error) {
-	var link netlink.Link
-	link, err = linkByName(iface)
+// reenumerate updates the interfaces of a module that enumerates them, without
+// discarding any samples, since the reader carries its total across changes.
+func (st *stream) reenumerate() {
+	ifaces, err := st.resolve()
 	if err != nil {
+		l.Fine("%s: listing interfaces: %v", l.ID(st.Module), err)
 		return
 	}
-	linkStats := link.Attrs().Statistics
-	rx = linkStats.RxBytes
-	tx = linkStats.TxBytes
-	return
+	if sameIfaces(st.ifaces, ifaces) {
+		return
+	}
+	// With no interfaces left, reading fails and the module is shown as
+	// disconnected, as for other modules.
+	l.Fine("%s: interfaces now %v", l.ID(st.Module), ifaces)
+	st.ifaces = ifaces
+	st.capacity = linkCapacity(ifaces)
+}
+
+// record adds a reading to the session totals and the rate window.
+func (st *stream) record(c counters) {
+	st.session.add(st.key, c)
+	span, maxGap := st.spans()
+	st.w.add(sample{timing.Now(), c}, span, maxGap)
+}
+
+// update reads the interface counters and updates the speeds, returning true
+// if the output needs to be updated.
+func (st *stream) update() (changed bool, err error) {
+	if st.noRoute() {
+		return false, nil
+	}
+	if st.enumerate && len(st.ifaces) > 0 {
+		st.reenumerate()
+	}
+	var c counters
+	err = errNoInterface
+	if len(st.ifaces) > 0 {
+		c, err = st.read()
+	}
+	if err != nil && st.resolve != nil {
+		// The interface may have gone away, possibly before the route
+		// update was received.
+		ifaces, rErr := st.resolve()
+		if rErr == nil && !sameIfaces(st.ifaces, ifaces) {
+			return true, st.follow(ifaces)
+		}
+	}
+	if isMissing(err) {
+		if st.disconnected {
+			return false, nil
+		}
+		l.Fine("%s: disconnected: %v", l.ID(st.Module), err)
+		st.disconnected = true
+		st.clear()
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if st.disconnected {
+		// Start afresh, so the counters from before the interface went away
+		// are not used to compute a rate.
+		st.disconnected = false
+		st.w.reset()
+		// The link speed may also have changed.
+		st.capacity = linkCapacity(st.ifaces)
+	}
+	if st.w.tooSoon(timing.Now()) {
+		// e.g. a repeated tick without the clock moving, which cannot be
+		// used to compute a rate. Keep the previous speeds.
+		return false, nil
+	}
+	st.record(c)
+	delta, elapsed, ok := st.w.delta()
+	if !ok {
+		// The window was reset, keep the previous speeds until there are
+		// enough samples again.
+		return false, nil
+	}
+
+	prev := st.speeds
+	st.speeds = speedsFrom(delta, elapsed)
+	if prev.available {
+		alpha := st.smoothing.Get()
+		st.speeds.Rx = smooth(alpha, prev.Rx, st.speeds.Rx)
+		st.speeds.Tx = smooth(alpha, prev.Tx, st.speeds.Tx)
+	}
+	st.speeds.totalRx = unit.Datasize(st.session.total.rxBytes) * unit.Byte
+	st.speeds.totalTx = unit.Datasize(st.session.total.txBytes) * unit.Byte
+	st.speeds.maxRx = maxRate(prev.maxRx, st.speeds.Rx)
+	st.speeds.maxTx = maxRate(prev.maxTx, st.speeds.Tx)
+	st.speeds.capacity = st.capacity
+	if st.signal.Get() {
+		if dBm, ok := wifiSignal(st.ifaces); ok {
+			st.speeds.SignalDBm = dBm
+			st.speeds.SignalPercent = signalPercent(dBm)
+			st.speeds.hasSignal = true
+		}
+	}
+
+	rxDir, txDir := st.directions()
+	st.speeds.RxSeverity = rxDir.thresholds.Severity(st.speeds.Rx)
+	st.speeds.TxSeverity = txDir.thresholds.Severity(st.speeds.Tx)
+	return true, nil
+}
+
+// render sends the output for the current state to the sink. Nothing is sent
+// while speeds are unavailable.
+func (st *stream) render(s bar.Sink, outputFunc func(Speeds) bar.Output) {
+	var out bar.Output
+	switch {
+	case st.absent():
+		if onDisconnect := st.onDisconnect.Get(); onDisconnect != nil {
+			out = onDisconnect()
+		}
+	case st.speeds.available:
+		out = value.SafeOutput(outputFunc, st.speeds)
+	default:
+		return
+	}
+	if onClick := st.onClick.Get(); onClick != nil && out != nil {
+		out = outputs.Group(out).OnClick(onClick)
+	}
+	s.Output(out)
+}
+
+var errNoInterface = errors.New("no matching interface")
+
+// isMissing returns true if the error is because the interface does not exist.
+func isMissing(err error) bool {
+	var notFound netlink.LinkNotFoundError
+	return errors.Is(err, errNoInterface) || errors.As(err, &notFound)
+}
+
+// newReader returns a function that reads the interface counters.
+// For a single interface these are the interface's counters. For multiple
+// interfaces, the per-interface deltas are summed into running totals, so
+// interfaces can come and go without the totals going backwards. The
+// interfaces are fetched on each read, since NewPhysical modules update them.
+func newReader(ifaces func() []string, multi bool) func() (counters, error) {
+	if !multi {
+		return func() (counters, error) {
+			return linkStats(ifaces()[0])
+		}
+	}
+	t := &totals{last: map[string]counters{}}
+	return func() (counters, error) {
+		current := ifaces()
+		listed := map[string]bool{}
+		for _, iface := range current {
+			listed[iface] = true
+		}
+		for iface := range t.last {
+			// Interfaces that are no longer listed start from a new
+			// baseline if they return.
+			if !listed[iface] {
+				delete(t.last, iface)
+			}
+		}
+		for _, iface := range current {
+			c, err := linkStats(iface)
+			if err != nil {
+				l.Fine("netspeed: skipping %s: %v", iface, err)
+				delete(t.last, iface)
+				continue
+			}
+			t.add(iface, c)
+		}
+		return t.total, nil
+	}
 }
@@ -15,18 +15,27 @@
 package netspeed
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"sync"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/sink"
+	"barista.run/colors"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
 
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
 )
 
 type testLink netlink.LinkStatistics
@@ -37,18 +46,38 @@ func (t testLink) Attrs() *netlink.LinkAttrs {
 func (t testLink) Type() string { return "test" }
 
 var ifaces = make(map[string]testLink)
+var linkErrs = make(map[string]error)
 var ifacesLock sync.Mutex
 
 func removeLink(name string) {
 	ifacesLock.Lock()
 	defer ifacesLock.Unlock()
 	delete(ifaces, name)
+	delete(linkErrs, name)
 }
 
 func setLink(name string, stats netlink.LinkStatistics) {
 	ifacesLock.Lock()
 	defer ifacesLock.Unlock()
 	ifaces[name] = testLink(stats)
+	delete(linkErrs, name)
+}
+
+func failLink(name string, err error) {
+	ifacesLock.Lock()
+	defer ifacesLock.Unlock()
+	linkErrs[name] = err
+}
+
+// linkNotFound mimics netlink.LinkNotFoundError, which cannot be constructed
+// outside the netlink package.
+type linkNotFound string
+
+func (e linkNotFound) Error() string { return string(e) }
+
+func (e linkNotFound) As(target interface{}) bool {
+	_, ok := target.(*netlink.LinkNotFoundError)
+	return ok
 }
 
 var signalChan chan struct{}
@@ -57,14 +86,18 @@ func init() {
 	linkByName = func(name string) (netlink.Link, error) {
 		ifacesLock.Lock()
 		link, ok := ifaces[name]
+		err := linkErrs[name]
 		sigCh := signalChan
 		signalChan = nil
 		ifacesLock.Unlock()
 		if sigCh != nil {
 			sigCh <- struct{}{}
 		}
+		if err != nil {
+			return nil, err
+		}
 		if !ok {
-			return nil, fmt.Errorf("No such link: %s", name)
+			return nil, linkNotFound(fmt.Sprintf("No such link: %s", name))
 		}
 		return link, nil
 	}
@@ -133,10 +166,10 @@ func TestNetspeed(t *testing.T) {
 func TestErrors(t *testing.T) {
 	testBar.New(t)
 
-	removeLink("if0")
+	failLink("if0", errors.New("something went wrong"))
 	n := New("if0").RefreshInterval(time.Second)
 	testBar.Run(n)
-	testBar.NextOutput().AssertError("on start for missing interface")
+	testBar.NextOutput().AssertError("on start with error")
 	out := testBar.NextOutput("sets restart click handler")
 
 	setLink("if0", netlink.LinkStatistics{
@@ -148,7 +181,7 @@ func TestErrors(t *testing.T) {
 	go out.At(0).LeftClick()
 	<-sigCh
 	testBar.NextOutput().AssertText([]string{},
-		"clears error on click after interface is available")
+		"clears error on click after error is resolved")
 
 	setLink("if0", netlink.LinkStatistics{
 		RxBytes: 4096,
@@ -156,9 +189,1064 @@ func TestErrors(t *testing.T) {
 	})
 	testBar.Tick()
 	testBar.NextOutput().AssertText(
-		[]string{"2.0 KiB/s up | 4.0 KiB/s down"}, "on tick")
+		[]string{"   2.0 KiB/s up |    4.0 KiB/s down"}, "on tick")
+
+	failLink("if0", errors.New("something else went wrong"))
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on tick with error")
+}
+
+func TestDisconnect(t *testing.T) {
+	testBar.New(t)
+
+	removeLink("if0")
+	n := New("if0").RefreshInterval(time.Second)
+	testBar.Run(n)
+	testBar.NextOutput().AssertEmpty("on start for missing interface")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("on tick while interface is missing")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 10000, TxBytes: 10000})
+	testBar.Tick()
+	testBar.AssertNoOutput("on first sample after interface returns")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 12048, TxBytes: 11024})
+	testBar.Tick()
+	testBar.NextOutput().AssertText(
+		[]string{"   1.0 KiB/s up |    2.0 KiB/s down"}, "on tick")
+
+	n.OnDisconnect(func() bar.Output { return outputs.Text("—") })
+	testBar.AssertNoOutput("on disconnect output change while connected")
+
+	removeLink("if0")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"—"}, "on losing interface")
+	testBar.Tick()
+	testBar.AssertNoOutput("on tick while interface is missing")
+
+	n.OnDisconnect(func() bar.Output { return outputs.Text("-") })
+	testBar.NextOutput().AssertText([]string{"-"},
+		"on disconnect output change while disconnected")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 100, TxBytes: 100})
+	testBar.Tick()
+	testBar.AssertNoOutput("no spike on reconnect")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 1124, TxBytes: 612})
+	testBar.Tick()
+	testBar.NextOutput().AssertText(
+		[]string{"     512 B/s up |    1.0 KiB/s down"}, "after reconnect")
+}
+
+func TestThresholds(t *testing.T) {
+	th := Thresholds{Warning: 1 * unit.MegabytePerSecond, Critical: 10 * unit.MegabytePerSecond}
+	require.Equal(t, outputs.Normal, th.Severity(0))
+	require.Equal(t, outputs.Normal, th.Severity(999*unit.KilobytePerSecond))
+	require.Equal(t, outputs.Warning, th.Severity(1*unit.MegabytePerSecond))
+	require.Equal(t, outputs.Warning, th.Severity(9*unit.MegabytePerSecond))
+	require.Equal(t, outputs.Critical, th.Severity(10*unit.MegabytePerSecond))
+	require.Equal(t, outputs.Critical, th.Severity(1*unit.GigabytePerSecond))
+
+	require.Equal(t, outputs.Normal, Thresholds{}.Severity(1*unit.GigabytePerSecond),
+		"zero thresholds are ignored")
+	require.Equal(t, outputs.Critical,
+		Thresholds{Critical: 1 * unit.KilobytePerSecond}.Severity(2*unit.KilobytePerSecond),
+		"critical without warning")
+}
+
+func TestSeverities(t *testing.T) {
+	testBar.New(t)
+	colors.Set("degraded", colors.Hex("#ff0"))
+	colors.Set("bad", colors.Hex("#f00"))
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(time.Second).
+		RxThresholds(10*unit.KilobytePerSecond, 100*unit.KilobytePerSecond).
+		TxThresholds(1*unit.KilobytePerSecond, 5*unit.KilobytePerSecond).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%d/%d", s.RxSeverity, s.TxSeverity)
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 2000, TxBytes: 2000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0/2"},
+		"same rate, different severity per direction")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 52000, TxBytes: 2500})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"2/0"})
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 252000, TxBytes: 12500})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3/3"})
+
+	n.TxIcons(map[outputs.Severity]bar.Output{
+		outputs.Normal:   outputs.Text("up"),
+		outputs.Critical: outputs.Text("UP!"),
+	}).RxIcons(map[outputs.Severity]bar.Output{
+		outputs.Warning: outputs.Text("down"),
+	}).SeverityOutput(outputs.Byterate)
+	out := testBar.NextOutput("on output change")
+	out.AssertText([]string{"UP!", "10 kB/s", "200 kB/s"})
+	col, _ := out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Hex("#f00"), col, "critical colour")
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "critical is urgent")
+	sep, _ := out.At(0).Segment().HasSeparator()
+	require.False(t, sep, "icon and value are glued")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 272000, TxBytes: 13000})
+	testBar.Tick()
+	out = testBar.NextOutput("on tick")
+	out.AssertText([]string{"up", "500 B/s", "down", "20 kB/s"})
+	_, hasColor := out.At(0).Segment().GetColor()
+	require.False(t, hasColor, "no colour for normal severity")
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent, "normal is not urgent")
+	col, _ = out.At(3).Segment().GetColor()
+	require.Equal(t, colors.Hex("#ff0"), col, "warning colour")
+}
+
+func TestAverageOver(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(time.Second).
+		AverageOver(3 * time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.Rx.BytesPerSecond(), s.Tx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	for _, tc := range []struct {
+		rx, tx   uint64
+		expected string
+	}{
+		{600, 30, "600/30"},
+		{1200, 60, "600/30"},
+		{3000, 90, "1000/30"},
+		{3000, 90, "800/20"},
+		{3000, 90, "600/10"},
+		{3000, 90, "0/0"},
+	} {
+		setLink("if0", netlink.LinkStatistics{RxBytes: tc.rx, TxBytes: tc.tx})
+		testBar.Tick()
+		testBar.NextOutput().AssertText([]string{tc.expected}, "on tick")
+	}
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 100, TxBytes: 10})
+	testBar.Tick()
+	testBar.AssertNoOutput("window reset when counters go backwards")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 400, TxBytes: 20})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"300/10"}, "after reset")
+
+	n.AverageOver(0)
+	setLink("if0", netlink.LinkStatistics{RxBytes: 500, TxBytes: 20})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100/0"},
+		"averages over refresh interval")
+}
+
+func TestMulti(t *testing.T) {
+	testBar.New(t)
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1000, TxBytes: 1000})
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 5000, TxBytes: 5000})
+	removeLink("usb0")
+	n := NewMulti("eth0", "wlan0", "usb0").
+		RefreshInterval(time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.Rx.BytesPerSecond(), s.Tx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1100, TxBytes: 1010})
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 5200, TxBytes: 5020})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"300/30"},
+		"sums speeds, ignores missing interface")
+
+	setLink("usb0", netlink.LinkStatistics{RxBytes: 90000, TxBytes: 90000})
+	removeLink("wlan0")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1200, TxBytes: 1020})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100/10"},
+		"new interface starts from baseline, removed interface ignored")
+
+	setLink("usb0", netlink.LinkStatistics{RxBytes: 90500, TxBytes: 90050})
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 100, TxBytes: 100})
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1300, TxBytes: 1030})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"600/60"},
+		"interface returning with reset counters")
+
+	removeLink("eth0")
+	removeLink("wlan0")
+	removeLink("usb0")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0/0"},
+		"no errors when all interfaces are missing")
+}
+
+func TestDefaultRoute(t *testing.T) {
+	testBar.New(t)
+
+	var routeMu sync.Mutex
+	route := ""
+	setRoute := func(iface string) {
+		routeMu.Lock()
+		defer routeMu.Unlock()
+		route = iface
+	}
+	routeUpdates := make(chan chan<- netlink.RouteUpdate, 1)
+	routeSubscribe = func(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error {
+		routeUpdates <- ch
+		return nil
+	}
+	defaultRouteIface = func() (string, error) {
+		routeMu.Lock()
+		defer routeMu.Unlock()
+		return route, nil
+	}
+	defer func() {
+		routeSubscribe = netlink.RouteSubscribe
+		defaultRouteIface = netlinkDefaultRoute
+	}()
+
+	n := NewDefaultRoute().
+		RefreshInterval(time.Second).
+		OnDisconnect(func() bar.Output { return outputs.Text("offline") }).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.Rx.BytesPerSecond(), s.Tx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	updates := <-routeUpdates
+	testBar.NextOutput().AssertText([]string{"offline"}, "no default route")
+	testBar.Tick()
+	testBar.AssertNoOutput("on tick while offline")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1000, TxBytes: 100})
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 50000, TxBytes: 5000})
+	setRoute("eth0")
+	updates <- netlink.RouteUpdate{}
+	testBar.NextOutput().AssertText([]string{}, "clears offline output")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1500, TxBytes: 150})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"500/50"}, "follows default route")
+
+	updates <- netlink.RouteUpdate{}
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1600, TxBytes: 160})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100/10"},
+		"ignores route updates that do not change the interface")
+
+	setRoute("wlan0")
+	updates <- netlink.RouteUpdate{}
+	testBar.AssertNoOutput("on interface change")
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 52000, TxBytes: 5200})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"2000/200"},
+		"switches to new default route interface")
+
+	n.OnDisconnect(func() bar.Output { return outputs.Text("no route") })
+	testBar.AssertNoOutput("disconnected output change while online")
+	setRoute("")
+	removeLink("wlan0")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"no route"},
+		"interface removed before route update")
+	updates <- netlink.RouteUpdate{}
+	testBar.AssertNoOutput("route update with same (no) route")
+
+	close(updates)
+	testBar.NextOutput().AssertError("on route subscription closed")
+}
+
+func TestPacketCounters(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{
+		RxBytes: 1000, TxBytes: 1000, RxPackets: 10, TxPackets: 10,
+	})
+	n := New("if0").
+		RefreshInterval(2 * time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f %.1f/%.1f %.1f/%.1f",
+				s.RxPackets, s.TxPackets, s.RxErrors, s.TxErrors,
+				s.RxDropped, s.TxDropped)
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("if0", netlink.LinkStatistics{
+		RxBytes: 5000, TxBytes: 2000, RxPackets: 50, TxPackets: 20,
+		RxErrors: 1, TxDropped: 3,
+	})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"20/5 0.5/0.0 0.0/1.5"},
+		"packet, error, and drop rates")
+
+	setLink("if0", netlink.LinkStatistics{
+		RxBytes: 5000, TxBytes: 2000, RxPackets: 50, TxPackets: 20,
+		RxErrors: 1, TxDropped: 3,
+	})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0/0 0.0/0.0 0.0/0.0"},
+		"no change")
+}
+
+func TestTotals(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 5000, TxBytes: 1000})
+	n := New("if0").
+		RefreshInterval(time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.TotalRx().Bytes(), s.TotalTx().Bytes())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 6000, TxBytes: 1500})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1000/500"}, "since start")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 8000, TxBytes: 1600})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3000/600"}, "accumulates")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 200, TxBytes: 100})
+	testBar.Tick()
+	testBar.AssertNoOutput("rate window reset on counter reset")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 300, TxBytes: 100})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3300/700"},
+		"counter reset counted from zero")
+
+	n.ResetTotals()
+	testBar.NextOutput().AssertText([]string{"0/0"}, "on reset")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 400, TxBytes: 150})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100/50"}, "after reset")
+}
+
+func TestSmoothing(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(time.Second).
+		Smoothing(0.5).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.Rx.BytesPerSecond(), s.Tx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	for _, tc := range []struct {
+		rx, tx   uint64
+		expected string
+	}{
+		{800, 400, "800/400"},
+		{800, 400, "400/200"},
+		{2400, 1200, "1000/500"},
+		{2400, 1200, "500/250"},
+	} {
+		setLink("if0", netlink.LinkStatistics{RxBytes: tc.rx, TxBytes: tc.tx})
+		testBar.Tick()
+		testBar.NextOutput().AssertText([]string{tc.expected}, "on tick")
+	}
+
+	n.Smoothing(1.0)
+	setLink("if0", netlink.LinkStatistics{RxBytes: 3400, TxBytes: 1300})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1000/100"}, "smoothing disabled")
+
+	n.Smoothing(-1)
+	setLink("if0", netlink.LinkStatistics{RxBytes: 3500, TxBytes: 1300})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100/0"}, "invalid alpha")
+}
+
+func TestOnClick(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").RefreshInterval(time.Second)
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 1024, TxBytes: 1024})
+	testBar.Tick()
+	out := testBar.NextOutput("on tick")
+	require.False(t, out.At(0).Segment().HasClick(), "no click handler by default")
+
+	clicks := make(chan string, 10)
+	n.OnClick(func(e bar.Event) { clicks <- "module" })
+	out = testBar.NextOutput("on click handler change")
+	out.At(0).LeftClick()
+	require.Equal(t, "module", <-clicks)
+
+	n.Output(func(s Speeds) bar.Output {
+		return outputs.Group(
+			outputs.Text("a"),
+			outputs.Text("b").OnClick(func(bar.Event) { clicks <- "segment" }),
+		)
+	})
+	out = testBar.NextOutput("on output change")
+	out.At(0).LeftClick()
+	require.Equal(t, "module", <-clicks, "applies to all segments")
+	out.At(1).LeftClick()
+	require.Equal(t, "segment", <-clicks, "segment handler takes precedence")
+
+	n.OnClick(nil)
+	out = testBar.NextOutput("on click handler removal")
+	require.False(t, out.At(0).Segment().HasClick())
+}
+
+func TestPeaks(t *testing.T) {
+	testBar.New(t)
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f/%.0f", s.MaxRx().BytesPerSecond(), s.MaxTx().BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	for _, tc := range []struct {
+		rx, tx   uint64
+		expected string
+	}{
+		{100, 500, "100/500"},
+		{400, 600, "300/500"},
+		{500, 1000, "300/500"},
+	} {
+		setLink("if0", netlink.LinkStatistics{RxBytes: tc.rx, TxBytes: tc.tx})
+		testBar.Tick()
+		testBar.NextOutput().AssertText([]string{tc.expected}, "on tick")
+	}
 
 	removeLink("if0")
 	testBar.Tick()
-	testBar.NextOutput().AssertError("on tick after losing interface")
+	testBar.NextOutput().AssertEmpty("on disconnect")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 100, TxBytes: 100})
+	testBar.Tick()
+	testBar.AssertNoOutput("on first sample after reconnect")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 150, TxBytes: 150})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"300/500"},
+		"peaks kept while speeds are unavailable")
+
+	n.ResetPeaks()
+	testBar.NextOutput().AssertText([]string{"50/50"}, "on reset")
+	setLink("if0", netlink.LinkStatistics{RxBytes: 170, TxBytes: 250})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"50/100"}, "after reset")
+}
+
+func TestDisplayInterval(t *testing.T) {
+	testBar.New(t)
+
+	start := timing.Now()
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(3 * time.Second).
+		DisplayInterval(2 * time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f @%d", s.Rx.BytesPerSecond(),
+				timing.Now().Sub(start)/time.Second)
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("on display tick before speeds are available")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 300})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100 @3"}, "on refresh")
+
+	setLink("if0", netlink.LinkStatistics{RxBytes: 900})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100 @4"},
+		"on display tick, uses previous speeds")
+
+	n.DisplayInterval(0)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"200 @6"},
+		"only on refresh after display interval is cleared")
+	testBar.Tick()
+	require.Equal(t, 9*time.Second, timing.Now().Sub(start))
+	testBar.NextOutput().AssertText([]string{"0 @9"})
+}
+
+type listedLink struct {
+	netlink.LinkAttrs
+}
+
+func (l listedLink) Attrs() *netlink.LinkAttrs { return &l.LinkAttrs }
+func (l listedLink) Type() string              { return "test" }
+
+func TestMatchLink(t *testing.T) {
+	var links []netlink.Link
+	linkList = func() ([]netlink.Link, error) { return links, nil }
+	defer func() { linkList = netlink.LinkList }()
+
+	up := net.FlagUp
+	for _, tc := range []struct {
+		links    []netlink.LinkAttrs
+		expected string
+		desc     string
+	}{
+		{nil, "", "no links"},
+		{[]netlink.LinkAttrs{{Name: "eth0"}, {Name: "lo"}}, "", "no match"},
+		{[]netlink.LinkAttrs{{Name: "wlan1"}, {Name: "wlan0"}}, "wlan0", "sorted by name"},
+		{[]netlink.LinkAttrs{
+			{Name: "wlan0"},
+			{Name: "wlan1", Flags: up},
+		}, "wlan1", "prefers up"},
+		{[]netlink.LinkAttrs{
+			{Name: "wlan0", Flags: up},
+			{Name: "wlan1", Flags: up, OperState: netlink.OperUp},
+			{Name: "wlp3s0", Flags: up, OperState: netlink.OperUp},
+			{Name: "eth0", Flags: up, OperState: netlink.OperUp},
+		}, "wlan1", "prefers up with carrier"},
+		{[]netlink.LinkAttrs{
+			{Name: "wlan0", OperState: netlink.OperUp},
+			{Name: "wlan1"},
+		}, "wlan0", "carrier without up"},
+	} {
+		links = nil
+		for _, attrs := range tc.links {
+			links = append(links, listedLink{attrs})
+		}
+		iface, err := matchLink("wl*")
+		require.NoError(t, err, tc.desc)
+		require.Equal(t, tc.expected, iface, tc.desc)
+	}
+
+	_, err := matchLink("[")
+	require.Error(t, err, "bad pattern")
+}
+
+func TestPattern(t *testing.T) {
+	testBar.New(t)
+
+	var linksMu sync.Mutex
+	var names []string
+	setNames := func(n ...string) {
+		linksMu.Lock()
+		defer linksMu.Unlock()
+		names = n
+	}
+	linkList = func() ([]netlink.Link, error) {
+		linksMu.Lock()
+		defer linksMu.Unlock()
+		var links []netlink.Link
+		for _, n := range names {
+			links = append(links, listedLink{netlink.LinkAttrs{Name: n}})
+		}
+		return links, nil
+	}
+	defer func() { linkList = netlink.LinkList }()
+
+	setLink("eth0", netlink.LinkStatistics{})
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 1000})
+	setNames("eth0", "wlan0")
+	n := NewPattern("wl*").
+		RefreshInterval(time.Second).
+		OnDisconnect(func() bar.Output { return outputs.Text("none") }).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f", s.Rx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 1500})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"500"}, "uses matching interface")
+
+	removeLink("wlan0")
+	setNames("eth0")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"none"}, "no matching interface")
+	testBar.Tick()
+	testBar.AssertNoOutput("while no interface matches")
+
+	setLink("wlp3s0", netlink.LinkStatistics{RxBytes: 5000})
+	setNames("eth0", "wlp3s0")
+	testBar.Tick()
+	testBar.AssertNoOutput("on first sample of new interface")
+	setLink("wlp3s0", netlink.LinkStatistics{RxBytes: 5100})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100"}, "re-resolves pattern")
+}
+
+type typedLink struct {
+	netlink.LinkAttrs
+	linkType string
+}
+
+func (l typedLink) Attrs() *netlink.LinkAttrs { return &l.LinkAttrs }
+func (l typedLink) Type() string              { return l.linkType }
+
+func TestIsPhysical(t *testing.T) {
+	up := net.FlagUp
+	for _, tc := range []struct {
+		link     typedLink
+		expected bool
+		desc     string
+	}{
+		{typedLink{netlink.LinkAttrs{Name: "eth0", Flags: up}, "device"}, true, "up device"},
+		{typedLink{netlink.LinkAttrs{Name: "eth1"}, "device"}, false, "down device"},
+		{typedLink{netlink.LinkAttrs{Name: "lo", Flags: up | net.FlagLoopback}, "device"}, false, "loopback"},
+		{typedLink{netlink.LinkAttrs{Name: "docker0", Flags: up}, "bridge"}, false, "bridge"},
+		{typedLink{netlink.LinkAttrs{Name: "veth1a2b", Flags: up}, "veth"}, false, "veth"},
+		{typedLink{netlink.LinkAttrs{Name: "tun0", Flags: up}, "tuntap"}, false, "tunnel"},
+	} {
+		require.Equal(t, tc.expected, IsPhysical(tc.link), tc.desc)
+	}
+}
+
+func TestPhysical(t *testing.T) {
+	testBar.New(t)
+
+	var linksMu sync.Mutex
+	var links []netlink.Link
+	setLinks := func(l ...netlink.Link) {
+		linksMu.Lock()
+		defer linksMu.Unlock()
+		links = l
+	}
+	linkList = func() ([]netlink.Link, error) {
+		linksMu.Lock()
+		defer linksMu.Unlock()
+		return links, nil
+	}
+	defer func() { linkList = netlink.LinkList }()
+
+	eth0 := typedLink{netlink.LinkAttrs{Name: "eth0", Flags: net.FlagUp}, "device"}
+	eth1 := typedLink{netlink.LinkAttrs{Name: "eth1", Flags: net.FlagUp}, "device"}
+	veth := typedLink{netlink.LinkAttrs{Name: "veth0", Flags: net.FlagUp}, "veth"}
+	lo := typedLink{netlink.LinkAttrs{Name: "lo", Flags: net.FlagUp | net.FlagLoopback}, "device"}
+
+	setLink("eth0", netlink.LinkStatistics{})
+	setLink("eth1", netlink.LinkStatistics{RxBytes: 9000})
+	setLink("veth0", netlink.LinkStatistics{})
+	setLink("lo", netlink.LinkStatistics{})
+	setLinks(lo, eth0, veth)
+	n := NewPhysical().
+		RefreshInterval(time.Second).
+		OnDisconnect(func() bar.Output { return outputs.Text("none") }).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f", s.Rx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 100})
+	setLink("veth0", netlink.LinkStatistics{RxBytes: 1000})
+	setLink("lo", netlink.LinkStatistics{RxBytes: 1000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100"},
+		"ignores loopback and virtual interfaces")
+
+	setLinks(lo, eth0, eth1, veth)
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 200})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100"},
+		"new interface starts from a baseline")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 300})
+	setLink("eth1", netlink.LinkStatistics{RxBytes: 9050})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"150"}, "includes new interface")
+
+	n.Filter(func(l netlink.Link) bool { return l.Attrs().Name != "eth0" })
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 400})
+	setLink("eth1", netlink.LinkStatistics{RxBytes: 9100})
+	setLink("veth0", netlink.LinkStatistics{RxBytes: 1020})
+	setLink("lo", netlink.LinkStatistics{RxBytes: 1030})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"50"},
+		"custom filter, new interfaces start from a baseline")
+
+	setLinks()
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"none"}, "no interfaces")
+
+	setLinks(eth0)
+	n.Filter(IsPhysical)
+	testBar.Tick()
+	testBar.AssertNoOutput("on first sample after reconnecting")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 700})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"300"}, "after reconnecting")
+}
+
+func TestUtilization(t *testing.T) {
+	testBar.New(t)
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+	afero.WriteFile(fs, "/sys/class/net/eth0/speed", []byte("1\n"), 0644)
+	afero.WriteFile(fs, "/sys/class/net/wlan0/speed", []byte("-1\n"), 0644)
+
+	require.Equal(t, 1*unit.MegabitPerSecond, linkCapacity([]string{"eth0"}))
+	require.Equal(t, unit.Datarate(0), linkCapacity([]string{"wlan0"}),
+		"unknown speed")
+	require.Equal(t, unit.Datarate(0), linkCapacity([]string{"usb0"}),
+		"missing speed file")
+	require.Equal(t, unit.Datarate(0), linkCapacity([]string{"eth0", "wlan0"}),
+		"unknown if any speed is unknown")
+	require.Equal(t, float64(-1), Speeds{Rx: unit.MegabitPerSecond}.Utilization())
+
+	setLink("eth0", netlink.LinkStatistics{})
+	n := New("eth0").
+		RefreshInterval(time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f%% of %s", s.Utilization(), outputs.Bitrate(s.Capacity()))
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 12500, TxBytes: 25000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"20% of 1.0 Mbit/s"},
+		"uses the busier direction")
+
+	removeLink("eth0")
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("on disconnect")
+
+	afero.WriteFile(fs, "/sys/class/net/eth0/speed", []byte("2\n"), 0644)
+	setLink("eth0", netlink.LinkStatistics{})
+	testBar.Tick()
+	testBar.AssertNoOutput("on reconnect")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 125000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"50% of 2.0 Mbit/s"},
+		"re-reads speed on reconnect")
+}
+
+// noNl80211 makes signal levels fall back to /proc/net/wireless.
+func noNl80211(string) (int, bool, error) {
+	return 0, false, errors.New("nl80211 not found")
+}
+
+func TestSignal(t *testing.T) {
+	testBar.New(t)
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+	stationSignal = noNl80211
+	defer func() { stationSignal = nl80211Signal }()
+	fs.MkdirAll("/sys/class/net/wlan0/phy80211", 0755)
+	fs.MkdirAll("/sys/class/net/wlp2/wireless", 0755)
+	fs.MkdirAll("/sys/class/net/eth0", 0755)
+	afero.WriteFile(fs, procWireless, []byte(
+		"Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE\n"+
+			" face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22\n"+
+			" wlan0: 0000   70.  -40.  -256        0      0      0      0      0        0\n"+
+			"  wlp2: 0000   30.  186.  -256        0      0      0      0      0        0\n"),
+		0644)
+
+	dBm, ok := wifiSignal([]string{"wlan0"})
+	require.True(t, ok)
+	require.Equal(t, -40, dBm)
+	dBm, ok = wifiSignal([]string{"eth0", "wlp2"})
+	require.True(t, ok, "uses first wireless interface")
+	require.Equal(t, -70, dBm, "unsigned level")
+	_, ok = wifiSignal([]string{"eth0"})
+	require.False(t, ok, "wired interface")
+
+	require.Equal(t, 100, signalPercent(-40))
+	require.Equal(t, 60, signalPercent(-70))
+	require.Equal(t, 0, signalPercent(-110))
+
+	stations := map[string]int{"wlan0": -55}
+	stationSignal = func(iface string) (int, bool, error) {
+		dBm, ok := stations[iface]
+		return dBm, ok, nil
+	}
+	fs.Remove(procWireless)
+	dBm, ok = wifiSignal([]string{"eth0", "wlan0"})
+	require.True(t, ok, "nl80211 without /proc/net/wireless")
+	require.Equal(t, -55, dBm)
+	_, ok = wifiSignal([]string{"wlp2", "wlan0"})
+	require.False(t, ok, "first wireless interface not associated")
+	stationSignal = noNl80211
+
+	info := nl.NewRtAttr(unix.NL80211_ATTR_STA_INFO|unix.NLA_F_NESTED, nil)
+	info.AddRtAttr(unix.NL80211_STA_INFO_INACTIVE_TIME, nl.Uint32Attr(10))
+	info.AddRtAttr(unix.NL80211_STA_INFO_SIGNAL, []byte{0xc4})
+	msg := nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, nl.Uint32Attr(3)).Serialize()
+	dBm, ok = stationInfoSignal(append(msg, info.Serialize()...))
+	require.True(t, ok)
+	require.Equal(t, -60, dBm, "signed level from nested station info")
+	_, ok = stationInfoSignal(msg)
+	require.False(t, ok, "no station info")
+
+	format := func(s Speeds) bar.Output {
+		if !s.HasSignal() {
+			return outputs.Textf("%s", s)
+		}
+		return outputs.Textf("%s %ddBm %d%%", s, s.SignalDBm, s.SignalPercent)
+	}
+
+	afero.WriteFile(fs, procWireless, []byte(
+		" wlan0: 0000   70.  -40.  -256        0      0      0      0      0        0\n"),
+		0644)
+	setLink("wlan0", netlink.LinkStatistics{})
+	n := New("wlan0").WithSignal().RefreshInterval(time.Second).Output(format)
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 1000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s -40dBm 100%"})
+
+	afero.WriteFile(fs, procWireless, []byte(
+		" wlan0: 0000   40.  -80.  -256        0      0      0      0      0        0\n"),
+		0644)
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 2000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s -80dBm 40%"},
+		"re-reads signal on each refresh")
+
+	fs.Remove(procWireless)
+	setLink("wlan0", netlink.LinkStatistics{RxBytes: 3000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s"},
+		"no signal without wireless info")
+
+	testBar.New(t)
+	setLink("eth0", netlink.LinkStatistics{})
+	testBar.Run(New("eth0").WithSignal().RefreshInterval(time.Second).Output(format))
+	testBar.AssertNoOutput("on start")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s"}, "wired interface")
+}
+
+func TestRepeatedTickAtSameTime(t *testing.T) {
+	testBar.New(t)
+	setLink("eth0", netlink.LinkStatistics{})
+	n := New("eth0").RefreshInterval(time.Second).Output(func(s Speeds) bar.Output {
+		return outputs.Textf("%s", s)
+	})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1000})
+	n.scheduler.After(0)
+	testBar.Tick()
+	testBar.AssertNoOutput("sample at start time is skipped")
+
+	n.RefreshInterval(time.Second)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s"})
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 5000})
+	n.scheduler.After(0)
+	testBar.Tick()
+	testBar.AssertNoOutput("keeps previous speeds for a repeated tick")
+
+	n.RefreshInterval(time.Second)
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 6000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓5.0 kB/s ↑0 B/s"},
+		"rate uses the sample before the repeated tick")
+}
+
+func TestRefresh(t *testing.T) {
+	testBar.New(t)
+	setLink("eth0", netlink.LinkStatistics{})
+	n := New("eth0").RefreshInterval(3 * time.Second).Output(func(s Speeds) bar.Output {
+		return outputs.Textf("%s", s)
+	})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	timing.AdvanceBy(time.Second)
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 1000})
+	n.Refresh()
+	testBar.NextOutput().AssertText([]string{"↓1.0 kB/s ↑0 B/s"},
+		"uses actual elapsed time")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 5000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓2.0 kB/s ↑0 B/s"},
+		"next tick uses time since refresh")
+
+	n.Refresh()
+	testBar.AssertNoOutput("refresh without time passing")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 11000})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓2.0 kB/s ↑0 B/s"})
+}
+
+func TestStreamContext(t *testing.T) {
+	timing.TestMode()
+	setLink("eth0", netlink.LinkStatistics{})
+	n := New("eth0").RefreshInterval(time.Second).DisplayInterval(time.Minute).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f", s.Rx.BytesPerSecond())
+		})
+	stream := func() (<-chan bar.Output, context.CancelFunc, <-chan struct{}) {
+		ctx, cancel := context.WithCancel(context.Background())
+		outs, s := sink.Buffered(10)
+		returned := make(chan struct{})
+		go func() {
+			n.StreamContext(ctx, s)
+			close(returned)
+		}()
+		return outs, cancel, returned
+	}
+	nextText := func(outs <-chan bar.Output, msg string) string {
+		select {
+		case out := <-outs:
+			txt, _ := out.Segments()[0].Content()
+			return txt
+		case <-time.After(time.Second):
+			require.Fail(t, "expected output", msg)
+		}
+		return ""
+	}
+
+	// Tick until the stream has taken its first sample and computed a rate.
+	waitForOutput := func(outs <-chan bar.Output, msg string) string {
+		require.Eventually(t, func() bool {
+			timing.NextTick()
+			return len(outs) > 0
+		}, time.Second, 10*time.Millisecond, msg)
+		return nextText(outs, msg)
+	}
+
+	outs, cancel, returned := stream()
+	require.Equal(t, "0", waitForOutput(outs, "first stream"))
+	cancel()
+	<-returned
+	require.Empty(t, timing.AllStats(), "schedulers stopped on cancel")
+
+	outs, cancel, returned = stream()
+	defer func() {
+		cancel()
+		<-returned
+	}()
+	require.Equal(t, "0", waitForOutput(outs, "second stream"))
+	require.Len(t, timing.AllStats(), 2, "schedulers re-armed on a new stream")
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 3000})
+	require.Eventually(t, func() bool {
+		timing.NextTick()
+		for len(outs) > 0 {
+			if nextText(outs, "") != "0" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "samples on ticks")
+}
+
+func TestOutputPanic(t *testing.T) {
+	testBar.New(t)
+	setLink("eth0", netlink.LinkStatistics{})
+	n := New("eth0").RefreshInterval(time.Second).Output(func(s Speeds) bar.Output {
+		if s.Rx < unit.KilobytePerSecond {
+			panic("too slow")
+		}
+		return outputs.Textf("%s", s)
+	})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 10})
+	testBar.Tick()
+	err := testBar.NextOutput("on panic").At(0).Segment().GetError()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "too slow")
+
+	setLink("eth0", netlink.LinkStatistics{RxBytes: 2010})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"↓2.0 kB/s ↑0 B/s"},
+		"keeps running after panic")
+}
+
+func TestMinRefreshInterval(t *testing.T) {
+	n := New("eth0").RefreshInterval(time.Nanosecond)
+	require.Equal(t, minRefreshInterval, n.interval.Get())
+	n.RefreshInterval(0)
+	require.Equal(t, minRefreshInterval, n.interval.Get(), "no panic on zero")
+	n.RefreshInterval(time.Second)
+	require.Equal(t, time.Second, n.interval.Get())
+}
+
+func TestSpeedsFormat(t *testing.T) {
+	require.Equal(t, "", Speeds{}.String(), "unavailable speeds")
+	require.Equal(t, "", fmt.Sprintf("%b", Speeds{}), "unavailable speeds")
+
+	s := Speeds{
+		Rx:        1200 * unit.KilobytePerSecond,
+		Tx:        340 * unit.KilobytePerSecond,
+		available: true,
+	}
+	require.Equal(t, "↓1.2 MB/s ↑340 kB/s", s.String())
+	require.Equal(t, "↓1.2 MB/s ↑340 kB/s", fmt.Sprintf("%v", s))
+	require.Equal(t, "↓1.2 MB/s ↑340 kB/s", fmt.Sprintf("%d", s))
+	require.Equal(t, "↓1.1 MiB/s ↑332 KiB/s", fmt.Sprintf("%#d", s))
+	require.Equal(t, "↓9.6 Mbit/s ↑2.7 Mbit/s", fmt.Sprintf("%b", s))
+	require.Equal(t, "↓9.2 Mibit/s ↑2.6 Mibit/s", fmt.Sprintf("%#b", s))
+	require.Equal(t, "[↓1.2 MB/s ↑340 kB/s  ]", fmt.Sprintf("[%-21s]", s))
+	require.Equal(t, "[  ↓1.2 MB/s ↑340 kB/s]", fmt.Sprintf("[%21s]", s))
+	require.Equal(t, "%!x(netspeed.Speeds=↓1.2 MB/s ↑340 kB/s)", fmt.Sprintf("%x", s))
+}
+
+func TestSamplingWithTestClock(t *testing.T) {
+	testBar.New(t)
+	clock := timing.NewTestClock()
+
+	setLink("if0", netlink.LinkStatistics{})
+	n := New("if0").
+		RefreshInterval(500 * time.Millisecond).
+		AverageOver(2 * time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%.0f", s.Rx.BytesPerSecond())
+		})
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	// 1000 B/s for 2 seconds, then 3000 B/s.
+	deltas := []uint64{500, 500, 500, 500, 1500, 1500}
+	var rx uint64
+	var rates []string
+	var tickTimes []time.Duration
+	start := clock.Now()
+	next := func() {
+		rx += deltas[len(rates)]
+		setLink("if0", netlink.LinkStatistics{RxBytes: rx})
+	}
+	next()
+	clock.OnTick(func(now time.Time) {
+		tickTimes = append(tickTimes, now.Sub(start))
+		txt, _ := testBar.NextOutput().At(0).Segment().Content()
+		rates = append(rates, txt)
+		if len(rates) < len(deltas) {
+			next()
+		}
+	})
+	require.Equal(t, start.Add(500*time.Millisecond), clock.NextTickTime())
+	clock.Advance(3 * time.Second)
+
+	require.Equal(t, []string{"1000", "1000", "1000", "1000", "1500", "2000"}, rates)
+	require.Equal(t, []time.Duration{
+		500 * time.Millisecond, time.Second, 1500 * time.Millisecond,
+		2 * time.Second, 2500 * time.Millisecond, 3 * time.Second,
+	}, tickTimes)
 }
@@ -0,0 +1,95 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"net"
+	"path/filepath"
+	"sort"
+
+	"github.com/vishvananda/netlink"
+)
+
+// For tests.
+var linkList = netlink.LinkList
+
+// matchLink returns the name of the best interface matching the pattern, or an
+// empty string if no interfaces match. See NewPattern for the precedence rules.
+func matchLink(pattern string) (string, error) {
+	links, err := linkList()
+	if err != nil {
+		return "", err
+	}
+	best, bestRank := "", -1
+	var names []string
+	ranks := map[string]int{}
+	for _, link := range links {
+		attrs := link.Attrs()
+		ok, err := filepath.Match(pattern, attrs.Name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			names = append(names, attrs.Name)
+			ranks[attrs.Name] = rankLink(attrs)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ranks[name] > bestRank {
+			best, bestRank = name, ranks[name]
+		}
+	}
+	return best, nil
+}
+
+// IsPhysical is the default filter for NewPhysical. It returns true for
+// interfaces of physical devices that are up, excluding loopback and virtual
+// interfaces such as bridges, veth pairs, and tunnels.
+func IsPhysical(link netlink.Link) bool {
+	attrs := link.Attrs()
+	return link.Type() == "device" &&
+		attrs.Flags&net.FlagUp != 0 &&
+		attrs.Flags&net.FlagLoopback == 0
+}
+
+// filterLinks returns the names of all interfaces for which the filter
+// returns true, sorted by name.
+func filterLinks(filter func(netlink.Link) bool) ([]string, error) {
+	links, err := linkList()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, link := range links {
+		if filter(link) {
+			names = append(names, link.Attrs().Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// rankLink ranks an interface for matchLink: 2 if the interface is up and has
+// a carrier, 1 if it is only up, and 0 otherwise.
+func rankLink(attrs *netlink.LinkAttrs) int {
+	if attrs.Flags&net.FlagUp == 0 {
+		return 0
+	}
+	if attrs.OperState == netlink.OperUp {
+		return 2
+	}
+	return 1
+}
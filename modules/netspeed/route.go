@@ -0,0 +1,91 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"errors"
+
+	"github.com/vishvananda/netlink"
+)
+
+var errRouteSubscription = errors.New("route subscription closed")
+
+// For tests.
+var (
+	routeSubscribe    = netlink.RouteSubscribe
+	defaultRouteIface = netlinkDefaultRoute
+)
+
+// netlinkDefaultRoute returns the name of the interface used by the default
+// route, preferring IPv4 over IPv6, or an empty string if there is no default
+// route.
+func netlinkDefaultRoute() (string, error) {
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := netlink.RouteList(nil, family)
+		if err != nil {
+			return "", err
+		}
+		var best *netlink.Route
+		for i, r := range routes {
+			if !isDefault(r) {
+				continue
+			}
+			if best == nil || r.Priority < best.Priority {
+				best = &routes[i]
+			}
+		}
+		if best == nil {
+			continue
+		}
+		link, err := netlink.LinkByIndex(best.LinkIndex)
+		if err != nil {
+			return "", err
+		}
+		return link.Attrs().Name, nil
+	}
+	return "", nil
+}
+
+func isDefault(r netlink.Route) bool {
+	if r.LinkIndex <= 0 {
+		return false
+	}
+	if r.Dst == nil {
+		return true
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0
+}
+
+// ifaceList returns a list containing only the given interface, or an empty
+// list if the interface name is empty.
+func ifaceList(iface string) []string {
+	if iface == "" {
+		return nil
+	}
+	return []string{iface}
+}
+
+func sameIfaces(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Thresholds are the rates at which traffic is considered to be at warning
+// or critical levels. A zero threshold is ignored.
+type Thresholds struct {
+	Warning, Critical unit.Datarate
+}
+
+// Severity returns the severity of the given rate: outputs.Critical or
+// outputs.Warning at or above the respective threshold, and outputs.Normal
+// otherwise.
+func (t Thresholds) Severity(rate unit.Datarate) outputs.Severity {
+	switch {
+	case t.Critical > 0 && rate >= t.Critical:
+		return outputs.Critical
+	case t.Warning > 0 && rate >= t.Warning:
+		return outputs.Warning
+	}
+	return outputs.Normal
+}
+
+// direction stores the severity configuration for one direction of traffic.
+type direction struct {
+	thresholds Thresholds
+	icons      map[outputs.Severity]bar.Output
+}
+
+func (m *Module) updateDirection(d *direction, fn func(*direction)) *Module {
+	m.directionsMu.Lock()
+	defer m.directionsMu.Unlock()
+	fn(d)
+	return m
+}
+
+func (m *Module) directions() (rx, tx direction) {
+	m.directionsMu.Lock()
+	defer m.directionsMu.Unlock()
+	return m.rx, m.tx
+}
+
+// RxThresholds sets the thresholds used to compute the severity of
+// received (download) traffic.
+func (m *Module) RxThresholds(warning, critical unit.Datarate) *Module {
+	return m.updateDirection(&m.rx, func(d *direction) {
+		d.thresholds = Thresholds{warning, critical}
+	})
+}
+
+// TxThresholds sets the thresholds used to compute the severity of
+// transmitted (upload) traffic.
+func (m *Module) TxThresholds(warning, critical unit.Datarate) *Module {
+	return m.updateDirection(&m.tx, func(d *direction) {
+		d.thresholds = Thresholds{warning, critical}
+	})
+}
+
+// RxIcons sets the icon displayed before the received rate for each
+// severity, when using SeverityOutput.
+func (m *Module) RxIcons(icons map[outputs.Severity]bar.Output) *Module {
+	return m.updateDirection(&m.rx, func(d *direction) { d.icons = icons })
+}
+
+// TxIcons sets the icon displayed before the transmitted rate for each
+// severity, when using SeverityOutput.
+func (m *Module) TxIcons(icons map[outputs.Severity]bar.Output) *Module {
+	return m.updateDirection(&m.tx, func(d *direction) { d.icons = icons })
+}
+
+// SeverityOutput configures the module to display a group for each direction
+// (transmitted then received), consisting of the icon for the current
+// severity and the rate formatted using the given function. Groups are
+// coloured and marked urgent using the shared severity styles (see
+// outputs.SetSeverityStyle). This replaces any function set using Output.
+func (m *Module) SeverityOutput(format func(unit.Datarate) string) *Module {
+	return m.Output(func(s Speeds) bar.Output {
+		rx, tx := m.directions()
+		return outputs.Group(
+			directionOutput(tx, s.TxSeverity, format(s.Tx)),
+			directionOutput(rx, s.RxSeverity, format(s.Rx)),
+		)
+	})
+}
+
+func directionOutput(d direction, sev outputs.Severity, text string) bar.Output {
+	out := outputs.Group()
+	if icon, ok := d.icons[sev]; ok && icon != nil {
+		out.Append(icon)
+	}
+	out.Append(outputs.Text(text)).Glue()
+	style := outputs.GetSeverityStyle(sev)
+	if c := colors.Scheme(style.Color); c != nil {
+		out.Color(c)
+	}
+	if style.Urgent {
+		out.Urgent(true)
+	}
+	return out
+}
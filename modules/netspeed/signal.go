@@ -0,0 +1,166 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	l "barista.run/logging"
+
+	"github.com/spf13/afero"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const procWireless = "/proc/net/wireless"
+
+// wifiSignal returns the signal level in dBm of the first wireless interface
+// in ifaces. It returns false if none of the interfaces is a wireless device,
+// or if the first one is not associated, neither of which is an error.
+func wifiSignal(ifaces []string) (dBm int, ok bool) {
+	for _, iface := range ifaces {
+		if !isWireless(iface) {
+			continue
+		}
+		dBm, ok, err := stationSignal(iface)
+		if err == nil {
+			return dBm, ok
+		}
+		// Fall back to the wireless extensions, which are still provided by
+		// some out-of-tree drivers that do not support nl80211.
+		l.Fine("netspeed: nl80211 signal of %s: %v", iface, err)
+		return procWirelessSignal(iface)
+	}
+	return 0, false
+}
+
+// isWireless returns true if sysfs reports the interface as a wireless
+// device, either through cfg80211 (phy80211) or the wireless extensions.
+func isWireless(iface string) bool {
+	for _, name := range []string{"phy80211", "wireless"} {
+		if _, err := fs.Stat(filepath.Join(sysNetPath, iface, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// stationSignal returns the signal level of the station (access point) that
+// the interface is associated with. It is replaced in tests.
+var stationSignal = nl80211Signal
+
+// nl80211Signal dumps the stations of the interface using nl80211 over
+// generic netlink, and returns the signal level of the first one. It returns
+// false if the interface is not associated.
+func nl80211Signal(iface string) (dBm int, ok bool, err error) {
+	intf, err := net.InterfaceByName(iface)
+	if err != nil {
+		return 0, false, err
+	}
+	family, err := netlink.GenlFamilyGet("nl80211")
+	if err != nil {
+		return 0, false, err
+	}
+	req := nl.NewNetlinkRequest(int(family.ID), unix.NLM_F_DUMP)
+	req.AddData(&nl.Genlmsg{Command: unix.NL80211_CMD_GET_STATION, Version: 1})
+	req.AddData(nl.NewRtAttr(unix.NL80211_ATTR_IFINDEX, nl.Uint32Attr(uint32(intf.Index))))
+	msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, msg := range msgs {
+		if len(msg) < nl.SizeofGenlmsg {
+			continue
+		}
+		if dBm, ok := stationInfoSignal(msg[nl.SizeofGenlmsg:]); ok {
+			return dBm, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// stationInfoSignal extracts the signal level from the attributes of an
+// NL80211_CMD_NEW_STATION message, where it is nested in the station info.
+func stationInfoSignal(b []byte) (dBm int, ok bool) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return 0, false
+	}
+	for _, attr := range attrs {
+		if attr.Attr.Type&^unix.NLA_F_NESTED != unix.NL80211_ATTR_STA_INFO {
+			continue
+		}
+		info, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return 0, false
+		}
+		for _, a := range info {
+			if a.Attr.Type == unix.NL80211_STA_INFO_SIGNAL && len(a.Value) > 0 {
+				// The signal level is a signed 8-bit value in dBm.
+				return int(int8(a.Value[0])), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// procWirelessSignal reads the signal level in dBm of an interface from
+// /proc/net/wireless. It returns false if the interface is not listed there.
+func procWirelessSignal(iface string) (dBm int, ok bool) {
+	data, err := afero.ReadFile(fs, procWireless)
+	if err != nil {
+		l.Fine("netspeed: wireless info: %v", err)
+		return 0, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// e.g. " wlan0: 0000   70.  -40.  -256  0  0  0  0  0  0".
+		// The two header lines have no ':', and are skipped.
+		name, stats, found := strings.Cut(scanner.Text(), ":")
+		fields := strings.Fields(stats)
+		if !found || len(fields) < 3 || strings.TrimSpace(name) != iface {
+			continue
+		}
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil {
+			return 0, false
+		}
+		if level > 0 {
+			// Some drivers report the level as an unsigned 8-bit value.
+			level -= 256
+		}
+		return int(level), true
+	}
+	return 0, false
+}
+
+// signalPercent converts a signal level in dBm to a quality percentage,
+// linearly from 0% at -100 dBm to 100% at -50 dBm.
+func signalPercent(dBm int) int {
+	pct := 2 * (dBm + 100)
+	if pct < 0 {
+		return 0
+	}
+	if pct > 100 {
+		return 100
+	}
+	return pct
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import "time"
+
+// sample is a single reading of the interface counters.
+type sample struct {
+	when time.Time
+	counters
+}
+
+// minElapsed is the shortest time between samples that is used to compute a
+// rate. Closer samples (e.g. two ticks at the same test time) would produce
+// huge or infinite rates.
+const minElapsed = time.Millisecond
+
+// window holds recent samples, so that rates can be averaged over a period
+// longer than the refresh interval.
+type window struct {
+	samples []sample
+}
+
+// add records a new sample, discarding samples that are no longer needed to
+// compute the rate over span. A span of zero keeps only the previous sample,
+// so the rate is computed over the last refresh interval. If the counters go
+// backwards (e.g. the interface was recreated), or the gap since the last
+// sample exceeds maxGap (e.g. after a suspend), the window is reset.
+func (w *window) add(s sample, span, maxGap time.Duration) {
+	if l := len(w.samples); l > 0 {
+		last := w.samples[l-1]
+		if s.before(last.counters) ||
+			(maxGap > 0 && s.when.Sub(last.when) > maxGap) {
+			w.reset()
+		}
+	}
+	w.samples = append(w.samples, s)
+	cutoff := s.when.Add(-span)
+	drop := 0
+	for drop < len(w.samples)-2 && !w.samples[drop+1].when.After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		w.samples = append(w.samples[:0], w.samples[drop:]...)
+	}
+}
+
+// tooSoon returns true if a sample taken at the given time would be too close
+// to the newest sample to compute a rate.
+func (w *window) tooSoon(when time.Time) bool {
+	l := len(w.samples)
+	return l > 0 && when.Sub(w.samples[l-1].when) < minElapsed
+}
+
+// reset discards all samples.
+func (w *window) reset() {
+	w.samples = w.samples[:0]
+}
+
+// delta returns the change in counters between the oldest and newest samples
+// in the window, and the time between them in seconds. ok is false if there
+// are not enough samples to compute a rate.
+func (w *window) delta() (delta counters, elapsed float64, ok bool) {
+	if len(w.samples) < 2 {
+		return counters{}, 0, false
+	}
+	first, last := w.samples[0], w.samples[len(w.samples)-1]
+	if last.when.Sub(first.when) < minElapsed {
+		return counters{}, 0, false
+	}
+	return last.sub(first.counters), last.when.Sub(first.when).Seconds(), true
+}
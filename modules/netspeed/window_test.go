@@ -0,0 +1,83 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netspeed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow(t *testing.T) {
+	start := time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)
+	at := func(secs int, rx, tx uint64) sample {
+		return sample{
+			start.Add(time.Duration(secs) * time.Second),
+			counters{rxBytes: rx, txBytes: tx},
+		}
+	}
+	requireRate := func(w *window, rx, tx float64, msg string) {
+		t.Helper()
+		delta, elapsed, ok := w.delta()
+		require.True(t, ok, msg)
+		require.InDelta(t, rx, float64(delta.rxBytes)/elapsed, 0.001, msg)
+		require.InDelta(t, tx, float64(delta.txBytes)/elapsed, 0.001, msg)
+	}
+
+	var w window
+	w.add(at(0, 0, 0), 0, 0)
+	_, _, ok := w.delta()
+	require.False(t, ok, "single sample")
+
+	w.add(at(1, 100, 10), 0, 0)
+	requireRate(&w, 100, 10, "two samples")
+	w.add(at(2, 400, 20), 0, 0)
+	requireRate(&w, 300, 10, "zero span uses last interval only")
+	require.Len(t, w.samples, 2)
+
+	w = window{}
+	for i := 0; i <= 10; i++ {
+		w.add(at(i, uint64(i*i*100), uint64(i*10)), 4*time.Second, 0)
+	}
+	// rx: (10000 - 3600) / 4
+	requireRate(&w, 1600, 10, "averaged over span")
+	require.Len(t, w.samples, 5, "drops samples outside span")
+
+	w.add(at(12, 10200, 120), 4*time.Second, 0)
+	// Oldest sample at or before the cutoff (8s) is kept.
+	requireRate(&w, (10200-6400)/4.0, 40/4.0, "uneven samples")
+
+	w.add(at(13, 10, 10), 4*time.Second, 0)
+	_, _, ok = w.delta()
+	require.False(t, ok, "reset when counters go backwards")
+	w.add(at(14, 110, 20), 4*time.Second, 0)
+	requireRate(&w, 100, 10, "after counter reset")
+
+	require.False(t, w.tooSoon(at(15, 0, 0).when))
+	require.True(t, w.tooSoon(at(14, 0, 0).when), "same time as the last sample")
+	w = window{}
+	require.False(t, w.tooSoon(at(14, 0, 0).when), "empty window")
+	w.add(at(14, 110, 20), 0, 0)
+	w.add(sample{at(14, 210, 20).when.Add(time.Microsecond), counters{rxBytes: 210}}, 0, 0)
+	_, _, ok = w.delta()
+	require.False(t, ok, "samples too close together")
+
+	w.add(at(100, 1000, 100), 4*time.Second, 10*time.Second)
+	_, _, ok = w.delta()
+	require.False(t, ok, "reset after a long gap")
+	w.add(at(102, 1200, 110), 4*time.Second, 10*time.Second)
+	requireRate(&w, 100, 5, "after long gap")
+}
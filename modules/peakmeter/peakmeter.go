@@ -0,0 +1,204 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package peakmeter provides an i3bar module that shows the current peak level
+of an audio sink or source, e.g. as a small unicode meter.
+
+It taps the PulseAudio (or PipeWire, via pipewire-pulse) monitor source
+using parec, reading a low sample rate mono stream to keep CPU usage down.
+*/
+package peakmeter // import "barista.run/modules/peakmeter"
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Peak represents the normalised peak level, in the range [0, 1].
+type Peak float64
+
+// Pct returns the peak level in the range 0-100.
+func (p Peak) Pct() int {
+	return int(float64(p)*100 + 0.5)
+}
+
+// Eighths of a block, for a meter with sub-character resolution.
+var meterBlocks = []rune(" ▏▎▍▌▋▊▉█")
+
+// Meter returns a horizontal meter of the given width (in characters)
+// representing the peak level, using unicode block elements.
+func (p Peak) Meter(width int) string {
+	total := int(math.Floor(float64(p)*float64(width*8) + 0.5))
+	var meter strings.Builder
+	for i := 0; i < width; i++ {
+		eighths := total - i*8
+		if eighths > 8 {
+			eighths = 8
+		}
+		if eighths < 0 {
+			eighths = 0
+		}
+		meter.WriteRune(meterBlocks[eighths])
+	}
+	return meter.String()
+}
+
+// Module represents a peak meter bar module. It supports setting the
+// output format and update frequency.
+type Module struct {
+	device     string
+	scheduler  timing.Scheduler
+	outputFunc value.OutputFunc[Peak]
+}
+
+// Source constructs a peak meter for the given PulseAudio source. Use
+// "@DEFAULT_SOURCE@" for the default input (e.g. microphone), or the
+// monitor source of a sink to show playback levels.
+func Source(device string) *Module {
+	m := &Module{device: device, scheduler: timing.NewScheduler()}
+	l.Label(m, device)
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(200 * time.Millisecond)
+	// Default output is a small meter.
+	m.Output(func(p Peak) bar.Output {
+		return outputs.Text(p.Meter(5))
+	})
+	return m
+}
+
+// New constructs a peak meter for the default sink's monitor source,
+// which shows the level of audio being played.
+func New() *Module {
+	return Source("@DEFAULT_MONITOR@")
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Peak) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures how often the meter is updated. The peak
+// shown is the highest level over the interval.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// process wraps the stdout of a running command, so that closing it
+// also terminates the command.
+type process struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	p.cmd.Process.Kill()
+	return p.cmd.Wait()
+}
+
+// openMonitor starts recording from the given device, returning a stream
+// of signed 16-bit little endian mono samples. Replaced in tests.
+var openMonitor = func(device string) (io.ReadCloser, error) {
+	cmd := exec.Command("parec", "--raw", "--format=s16le",
+		"--channels=1", "--rate=8000", "--latency-msec=50", "-d", device)
+	// Prevent SIGUSR for bar pause/resume from propagating to parec.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{stdout, cmd}, nil
+}
+
+// readPeaks reads samples from r until an error occurs, storing the
+// highest absolute sample value seen in peak.
+func readPeaks(r io.Reader, peak *int32) error {
+	buf := make([]byte, 1024)
+	have := 0
+	for {
+		n, err := r.Read(buf[have:])
+		have += n
+		i := 0
+		for ; i+1 < have; i += 2 {
+			sample := int32(int16(binary.LittleEndian.Uint16(buf[i:])))
+			if sample < 0 {
+				sample = -sample
+			}
+			for {
+				old := atomic.LoadInt32(peak)
+				if sample <= old || atomic.CompareAndSwapInt32(peak, old, sample) {
+					break
+				}
+			}
+		}
+		// Keep any trailing partial sample for the next read.
+		have = copy(buf, buf[i:have])
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	monitor, err := openMonitor(m.device)
+	if s.Error(err) {
+		return
+	}
+	defer monitor.Close()
+
+	var peak int32
+	errChan := make(chan error, 1)
+	go func() {
+		err := readPeaks(monitor, &peak)
+		if err == io.EOF {
+			err = errors.New("audio monitor stream ended")
+		}
+		errChan <- err
+	}()
+
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		select {
+		case err := <-errChan:
+			s.Error(err)
+			return
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-m.scheduler.Tick():
+			p := atomic.SwapInt32(&peak, 0)
+			s.Output(outputFunc(Peak(math.Min(float64(p)/math.MaxInt16, 1))))
+		}
+	}
+}
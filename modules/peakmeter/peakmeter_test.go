@@ -0,0 +1,106 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package peakmeter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMonitor struct{ *io.PipeReader }
+
+var monitorWriters = make(chan *io.PipeWriter, 1)
+
+func init() {
+	openMonitor = func(device string) (io.ReadCloser, error) {
+		if device == "invalid" {
+			return nil, errors.New("no such device")
+		}
+		r, w := io.Pipe()
+		monitorWriters <- w
+		return testMonitor{r}, nil
+	}
+}
+
+var monitorWriter *io.PipeWriter
+
+func writeSamples(samples ...int16) {
+	buf := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(s))
+	}
+	monitorWriter.Write(buf)
+	// Since io.Pipe is synchronous, a second write ensures that the
+	// previous samples have been fully processed.
+	monitorWriter.Write([]byte{0, 0})
+}
+
+func TestMeter(t *testing.T) {
+	require := require.New(t)
+	require.Equal("     ", Peak(0).Meter(5))
+	require.Equal("█████", Peak(1).Meter(5))
+	require.Equal("██▌  ", Peak(0.5).Meter(5))
+	require.Equal("▏", Peak(0.1).Meter(1))
+	require.Equal(50, Peak(0.5).Pct())
+}
+
+func TestPeakMeter(t *testing.T) {
+	testBar.New(t)
+	p := New().Output(func(p Peak) bar.Output {
+		return outputs.Textf("%d", p.Pct())
+	})
+	testBar.Run(p)
+	monitorWriter = <-monitorWriters
+	testBar.AssertNoOutput("until tick")
+
+	writeSamples(100, -16384, 200)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"50"}, "on tick")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0"}, "peak resets on tick")
+
+	// Split a sample across reads, to test partial samples.
+	monitorWriter.Write([]byte{0xff})
+	monitorWriter.Write([]byte{0x7f})
+	writeSamples(-32768)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"100"}, "clamps to 1")
+
+	p.Output(func(p Peak) bar.Output {
+		return outputs.Text(p.Meter(2))
+	})
+	testBar.AssertNoOutput("on output format change")
+	writeSamples(8192)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"▌ "}, "on tick after format change")
+
+	monitorWriter.Close()
+	testBar.NextOutput().AssertError("when monitor stream ends")
+}
+
+func TestInvalidDevice(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(Source("invalid"))
+	testBar.NextOutput().AssertError("on error opening monitor")
+}
@@ -0,0 +1,317 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package power provides an i3bar module that displays the current power draw,
+// read from the battery when available, or from Intel RAPL otherwise.
+package power // import "barista.run/modules/power"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+)
+
+// Source represents the source of power readings.
+type Source int
+
+const (
+	// Battery power draw, i.e. the power of the whole system,
+	// only meaningful while discharging.
+	Battery Source = iota
+	// RAPL package power, i.e. the power used by the CPU packages.
+	RAPL
+)
+
+// Info represents the current power draw.
+type Info struct {
+	// Watts is the current power draw.
+	Watts float64
+	// Source is where the readings were obtained from.
+	Source Source
+	// History contains the most recent readings, oldest first, including the
+	// current reading. The number of readings kept is set using History.
+	History []float64
+}
+
+var sparks = []rune(" ▁▂▃▄▅▆▇█")
+
+// Sparkline returns a string with one block character per reading in the
+// history, scaled such that the largest reading is a full block.
+func (i Info) Sparkline() string {
+	max := 0.0
+	for _, w := range i.History {
+		if w > max {
+			max = w
+		}
+	}
+	var out strings.Builder
+	for _, w := range i.History {
+		idx := 0
+		if max > 0 {
+			idx = int(w / max * float64(len(sparks)-1))
+		}
+		out.WriteRune(sparks[idx])
+	}
+	return out.String()
+}
+
+// Module represents a power draw bar module.
+type Module struct {
+	source     *Source // nil for automatic.
+	scheduler  timing.Scheduler
+	outputFunc value.OutputFunc[Info]
+	historyLen value.Value // of int
+}
+
+func newModule(source *Source) *Module {
+	m := &Module{source: source, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc", "historyLen")
+	m.historyLen.Set(0)
+	m.RefreshInterval(2 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.1f W", i.Watts)
+	})
+	return m
+}
+
+// New constructs a power module that reads the power draw from the batteries,
+// or from Intel RAPL if there are no batteries that report power.
+func New() *Module {
+	return newModule(nil)
+}
+
+// FromSource constructs a power module that reads the power draw from the
+// given source.
+func FromSource(source Source) *Module {
+	return newModule(&source)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for power draw.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// History sets the number of readings to keep in Info.History.
+func (m *Module) History(count int) *Module {
+	m.historyLen.Set(count)
+	return m
+}
+
+// Stream starts the module. Errors reading the power draw are displayed
+// using the error handler, but the module keeps polling, so that it can
+// recover, e.g. when a battery is connected or RAPL becomes readable.
+func (m *Module) Stream(s bar.Sink) {
+	var r reader
+	if m.source == nil {
+		r = &autoReader{}
+	} else if *m.source == Battery {
+		r = batteryReader{}
+	} else {
+		r = &raplReader{}
+	}
+
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+	var info Info
+	available := false
+	update := func() {
+		watts, ok, err := r.read()
+		if s.Error(err) {
+			available = false
+			return
+		}
+		if !ok {
+			return
+		}
+		available = true
+		if src := r.source(); src != info.Source {
+			// Readings from different sources are not comparable.
+			info.Source = src
+			info.History = nil
+		}
+		info.Watts = watts
+		history := append(info.History, watts)
+		if max := m.historyLen.Get().(int); len(history) > max {
+			history = history[len(history)-max:]
+		}
+		// Copy the history, since it's shared with the output function.
+		info.History = append([]float64(nil), history...)
+	}
+	update()
+	for {
+		if available {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-m.scheduler.Tick():
+			update()
+		}
+	}
+}
+
+// reader reads the current power draw in watts. ok is false if a reading was
+// not available yet.
+type reader interface {
+	read() (watts float64, ok bool, err error)
+	source() Source
+}
+
+var fs = afero.NewOsFs()
+
+const powerSupplyPath = "/sys/class/power_supply"
+const raplPath = "/sys/class/powercap"
+
+// autoReader reads from the batteries if any of them report power,
+// and from RAPL otherwise. The choice is made on each reading, so that
+// errors reading RAPL do not hide the battery power draw.
+type autoReader struct {
+	rapl    raplReader
+	current Source
+}
+
+func (a *autoReader) source() Source { return a.current }
+
+func (a *autoReader) read() (float64, bool, error) {
+	if watts, ok, _ := (batteryReader{}).read(); ok {
+		a.current = Battery
+		return watts, true, nil
+	}
+	a.current = RAPL
+	return a.rapl.read()
+}
+
+type batteryReader struct{}
+
+func (batteryReader) source() Source { return Battery }
+
+func (batteryReader) read() (float64, bool, error) {
+	batts, err := afero.Glob(fs, filepath.Join(powerSupplyPath, "BAT*"))
+	if err != nil {
+		return 0, false, err
+	}
+	total := 0.0
+	found := false
+	for _, batt := range batts {
+		if uw, err := readInt(filepath.Join(batt, "power_now")); err == nil {
+			total += float64(uw) / 1e6
+			found = true
+			continue
+		}
+		// Some batteries only report current and voltage.
+		ua, err := readInt(filepath.Join(batt, "current_now"))
+		if err != nil {
+			continue
+		}
+		uv, err := readInt(filepath.Join(batt, "voltage_now"))
+		if err != nil {
+			continue
+		}
+		total += float64(ua) / 1e6 * float64(uv) / 1e6
+		found = true
+	}
+	if !found {
+		return 0, false, fmt.Errorf("power: no batteries report power")
+	}
+	return total, true, nil
+}
+
+// raplReader computes the power from the energy counters of all RAPL
+// packages. Since the counters are cumulative, two readings are needed.
+type raplReader struct {
+	lastRead   time.Time
+	lastEnergy map[string]int64
+}
+
+func (*raplReader) source() Source { return RAPL }
+
+func (r *raplReader) read() (float64, bool, error) {
+	zones, err := afero.Glob(fs, filepath.Join(raplPath, "intel-rapl:*"))
+	if err != nil {
+		return 0, false, err
+	}
+	now := timing.Now()
+	energy := map[string]int64{}
+	for _, zone := range zones {
+		if strings.Count(filepath.Base(zone), ":") != 1 {
+			// Sub-zones (e.g. core, uncore) are included in the package.
+			continue
+		}
+		uj, err := readInt(filepath.Join(zone, "energy_uj"))
+		if os.IsPermission(err) {
+			return 0, false, fmt.Errorf(
+				"power: permission denied reading RAPL energy for %s", zone)
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		energy[zone] = uj
+	}
+	if len(energy) == 0 {
+		return 0, false, fmt.Errorf("power: no batteries or RAPL packages found")
+	}
+	defer func() {
+		r.lastEnergy = energy
+		r.lastRead = now
+	}()
+	if r.lastEnergy == nil {
+		return 0, false, nil
+	}
+	elapsed := now.Sub(r.lastRead).Seconds()
+	if elapsed <= 0 {
+		return 0, false, nil
+	}
+	total := 0.0
+	for zone, uj := range energy {
+		delta := uj - r.lastEnergy[zone]
+		if delta < 0 {
+			// The counter wrapped around.
+			max, err := readInt(filepath.Join(zone, "max_energy_range_uj"))
+			if err != nil {
+				return 0, false, err
+			}
+			delta += max
+		}
+		total += float64(delta) / 1e6
+	}
+	return total / elapsed, true, nil
+}
+
+func readInt(path string) (int64, error) {
+	bytes, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+}
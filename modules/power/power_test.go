@@ -0,0 +1,153 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(path string, value interface{}) {
+	afero.WriteFile(fs, path, []byte(fmt.Sprintf("%v\n", value)), 0644)
+}
+
+// noPermissionFs simulates unreadable RAPL energy counters.
+type noPermissionFs struct {
+	afero.Fs
+}
+
+func (n noPermissionFs) Open(name string) (afero.File, error) {
+	if strings.HasSuffix(name, "energy_uj") {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return n.Fs.Open(name)
+}
+
+func TestBattery(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	writeFile("/sys/class/power_supply/BAT0/power_now", 12500000)
+	writeFile("/sys/class/power_supply/BAT1/current_now", 500000)
+	writeFile("/sys/class/power_supply/BAT1/voltage_now", 11000000)
+	writeFile("/sys/class/power_supply/AC/online", 0)
+	// Should be ignored when batteries are available.
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000)
+
+	m := New().RefreshInterval(time.Second)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"18.0 W"}, "on start")
+
+	writeFile("/sys/class/power_supply/BAT0/power_now", 4500000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"10.0 W"}, "on tick")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %.1f", i.Source, i.Watts)
+	})
+	testBar.NextOutput().AssertText([]string{"0 10.0"}, "on output change")
+}
+
+func TestRAPL(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000000)
+	writeFile("/sys/class/powercap/intel-rapl:0/max_energy_range_uj", 10000000)
+	writeFile("/sys/class/powercap/intel-rapl:0:0/energy_uj", 500000)
+	writeFile("/sys/class/powercap/intel-rapl:1/energy_uj", 2000000)
+
+	m := New().RefreshInterval(time.Second).History(3).
+		Output(func(i Info) bar.Output {
+			return outputs.Textf("%v %.1f %v", i.Source, i.Watts, i.History)
+		})
+	testBar.Run(m)
+	testBar.AssertNoOutput("until two readings are available")
+
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 3000000)
+	writeFile("/sys/class/powercap/intel-rapl:0:0/energy_uj", 10000000)
+	writeFile("/sys/class/powercap/intel-rapl:1/energy_uj", 3000000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1 3.0 [3]"}, "on tick")
+
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 9000000)
+	writeFile("/sys/class/powercap/intel-rapl:1/energy_uj", 4000000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1 7.0 [3 7]"})
+
+	// Counter for package 0 wraps around.
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000000)
+	writeFile("/sys/class/powercap/intel-rapl:1/energy_uj", 5000000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1 3.0 [3 7 3]"}, "on wrap around")
+
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 2000000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1 1.0 [7 3 1]"}, "history is limited")
+}
+
+func TestErrors(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput().AssertError("with no batteries or RAPL")
+
+	fs = noPermissionFs{afero.NewMemMapFs()}
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000000)
+	testBar.New(t)
+	testBar.Run(New())
+	errs := testBar.NextOutput().AssertError("when RAPL is not readable")
+	require.Contains(t, errs[0], "permission denied")
+
+	writeFile("/sys/class/power_supply/BAT0/power_now", 12500000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"12.5 W"},
+		"battery power is shown despite RAPL errors")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/BAT0/power_now", []byte("x"), 0644)
+	testBar.Tick()
+	testBar.NextOutput().AssertError("when RAPL is not readable again")
+
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	testBar.Run(FromSource(RAPL))
+	testBar.NextOutput().AssertError("with no RAPL packages")
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000000)
+	testBar.Tick()
+	testBar.AssertNoOutput("until two RAPL readings are available")
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 3000000)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"1.0 W"}, "after recovering")
+
+	fs = afero.NewMemMapFs()
+	writeFile("/sys/class/powercap/intel-rapl:0/energy_uj", 1000000)
+	testBar.New(t)
+	testBar.Run(FromSource(Battery))
+	testBar.NextOutput().AssertError("battery source without batteries")
+}
+
+func TestSparkline(t *testing.T) {
+	require.Equal(t, "", Info{}.Sparkline())
+	require.Equal(t, "  ", Info{History: []float64{0, 0}}.Sparkline())
+	require.Equal(t, "▂▄█ ▆", Info{History: []float64{2, 4, 8, 0, 6}}.Sparkline())
+}
@@ -0,0 +1,371 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// command holds the options used to build a command, shared by the
+// repeating and tail modules.
+type command struct {
+	mu   sync.Mutex
+	name string
+	args []string
+	// pipe holds any further stages of a pipeline, each a command name
+	// followed by its arguments.
+	pipe [][]string
+	env  []string
+	dir  string
+	// stdin returns a new reader for each run of the command.
+	stdin func() io.Reader
+}
+
+// splitStages splits the stages of a pipeline, each a command name followed by
+// its arguments, into the first command and the rest of the pipeline. An empty
+// stage fails to start.
+func splitStages(stages [][]string) (name string, args []string, pipe [][]string) {
+	if len(stages) == 0 {
+		return "", nil, nil
+	}
+	if first := stages[0]; len(first) > 0 {
+		name, args = first[0], first[1:]
+	}
+	return name, args, stages[1:]
+}
+
+// setEnv adds an environment variable for the command.
+func (c *command) setEnv(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.env = append(c.env, key+"="+value)
+}
+
+// setDir sets the working directory for the command.
+func (c *command) setDir(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dir = path
+}
+
+// setStdin sets the source of input for each run of the command.
+func (c *command) setStdin(stdin func() io.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stdin = stdin
+}
+
+// build returns a new pipeline using the configured options. Environment
+// variables are merged onto the inherited environment, with later values
+// taking precedence. The commands are killed if the context is done before
+// they exit.
+func (c *command) build(ctx context.Context) *pipeline {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &pipeline{}
+	stages := append([][]string{append([]string{c.name}, c.args...)}, c.pipe...)
+	for _, stage := range stages {
+		var name string
+		var args []string
+		if len(stage) > 0 {
+			name, args = stage[0], stage[1:]
+		}
+		cmd := exec.CommandContext(ctx, name, args...)
+		if len(c.env) > 0 {
+			cmd.Env = append(os.Environ(), c.env...)
+		}
+		cmd.Dir = c.dir
+		p.cmds = append(p.cmds, cmd)
+	}
+	if c.stdin != nil {
+		// Since this is not an *os.File, exec copies it to the command's
+		// stdin in a separate goroutine, and closes the pipe when done.
+		p.cmds[0].Stdin = c.stdin()
+	}
+	return p
+}
+
+// pipeline is a chain of commands, where the stdout of each command is
+// connected to the stdin of the next. A single command is a pipeline with
+// one stage.
+type pipeline struct {
+	cmds []*exec.Cmd
+	// setpgid starts all commands in a new process group, led by the first
+	// command, so that they can be signalled together.
+	setpgid bool
+}
+
+// last returns the last command, whose stdout is the pipeline's output.
+func (p *pipeline) last() *exec.Cmd {
+	return p.cmds[len(p.cmds)-1]
+}
+
+// setStderr sets the stderr of all commands in the pipeline.
+func (p *pipeline) setStderr(stderr io.Writer) {
+	for _, cmd := range p.cmds {
+		cmd.Stderr = stderr
+	}
+}
+
+// pid returns the process ID of the first command, which is also the process
+// group ID if setpgid is set. It must only be called after start.
+func (p *pipeline) pid() int {
+	return p.cmds[0].Process.Pid
+}
+
+// start connects the commands using pipes and starts them. If any command
+// fails to start, the commands already started are killed.
+func (p *pipeline) start() error {
+	// Check all commands up front, so that a missing command doesn't
+	// start (and kill) the earlier stages.
+	for i, cmd := range p.cmds {
+		if err := lookPath(cmd.Args[0]); err != nil {
+			return p.stageError(i, err)
+		}
+	}
+	var pipes []*os.File
+	// The commands hold their own copies of the pipes once started.
+	defer func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}()
+	for i := 1; i < len(p.cmds); i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		pipes = append(pipes, r, w)
+		p.cmds[i-1].Stdout = w
+		p.cmds[i].Stdin = r
+	}
+	for i, cmd := range p.cmds {
+		if p.setpgid {
+			// Prevent SIGUSR for bar pause/resume from propagating to the
+			// child processes. Some commands don't play nice with signals.
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+			if i > 0 {
+				cmd.SysProcAttr.Pgid = p.pid()
+			}
+		}
+		if err := cmd.Start(); err != nil {
+			for _, started := range p.cmds[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			return p.stageError(i, err)
+		}
+	}
+	return nil
+}
+
+// wait waits for all commands to exit. Like `set -o pipefail`, it returns
+// the error from the last command that failed, but ignores earlier commands
+// killed by SIGPIPE, since that only means that a later command stopped
+// reading their output.
+func (p *pipeline) wait() error {
+	var err error
+	for i, cmd := range p.cmds {
+		e := cmd.Wait()
+		if e == nil || (i < len(p.cmds)-1 && brokenPipe(e)) {
+			continue
+		}
+		err = p.stageError(i, e)
+	}
+	return err
+}
+
+// output runs the pipeline and returns its output.
+func (p *pipeline) output() ([]byte, error) {
+	var out bytes.Buffer
+	p.last().Stdout = &out
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	err := p.wait()
+	return out.Bytes(), err
+}
+
+// stageError adds the failing stage to an error from a pipeline with more
+// than one command.
+func (p *pipeline) stageError(i int, err error) error {
+	if len(p.cmds) == 1 {
+		return err
+	}
+	return &stageError{stage: i, name: p.cmds[i].Args[0], err: err}
+}
+
+type stageError struct {
+	stage int
+	name  string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s[%d]: %v", e.name, e.stage, e.err)
+}
+
+func (e *stageError) Unwrap() error { return e.err }
+
+// pathError is the error reported when a command cannot be found or is not
+// executable. It wraps the error from exec, so errors.Is(err, exec.ErrNotFound)
+// is true if the command is not found, and errors.Is(err, os.ErrPermission) if
+// it cannot be executed.
+type pathError struct {
+	name string
+	err  error
+}
+
+func (e *pathError) Error() string {
+	switch {
+	case errors.Is(e.err, os.ErrPermission):
+		return fmt.Sprintf("shell: %q is not executable (permission denied)", e.name)
+	case strings.Contains(e.name, "/"):
+		return fmt.Sprintf("shell: %q not found", e.name)
+	default:
+		return fmt.Sprintf("shell: %q not found in PATH", e.name)
+	}
+}
+
+func (e *pathError) Unwrap() error { return e.err }
+
+// lookPath returns a *pathError if the named command cannot be run.
+func lookPath(name string) error {
+	_, err := exec.LookPath(name)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) && !strings.Contains(name, "/") {
+		// LookPath skips files in PATH that are not executable, but that
+		// is more likely to be a permissions problem than a missing command.
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			if dir == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if info, statErr := os.Stat(path); statErr == nil && !info.IsDir() {
+				err = &os.PathError{Op: "exec", Path: path, Err: os.ErrPermission}
+				break
+			}
+		}
+	}
+	return &pathError{name, err}
+}
+
+// brokenPipe returns true if the error is from a command killed by SIGPIPE.
+func brokenPipe(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == syscall.SIGPIPE
+}
+
+// CommandError is the error reported when a command fails to start, or exits
+// with an error. Error handlers can type-assert to this (or use errors.As) to
+// distinguish between failure modes, e.g. by exit code.
+type CommandError struct {
+	// Err is the original error, e.g. an *exec.ExitError, or an
+	// *exec.Error if the command was not found.
+	Err error
+	// ExitCode is the exit code of the command, or -1 if the command
+	// could not be started or was terminated by a signal.
+	ExitCode int
+	// Stderr holds the last few lines written by the command to stderr,
+	// if captured. For pipelines, this includes all commands.
+	Stderr string
+	// Command is the name of the failing command in a pipeline, and Stage
+	// is its index. Both are unset for a single command.
+	Command string
+	Stage   int
+}
+
+func (e *CommandError) Error() string {
+	msg := e.Err.Error()
+	if e.Command != "" {
+		msg = fmt.Sprintf("%s[%d]: %s", e.Command, e.Stage, msg)
+	}
+	if e.Stderr == "" {
+		return msg
+	}
+	return msg + ": " + e.Stderr
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// commandError wraps a non-nil error from running a command in a
+// *CommandError, and returns nil otherwise.
+func commandError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	cmdErr := &CommandError{Err: err, ExitCode: -1, Stderr: stderr}
+	if se, ok := err.(*stageError); ok {
+		cmdErr.Err, cmdErr.Command, cmdErr.Stage = se.err, se.name, se.stage
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	return cmdErr
+}
+
+// exitCode returns the exit code from an error returned by commandError, or
+// -1 for any other error.
+func exitCode(err error) int {
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.ExitCode
+	}
+	return -1
+}
+
+// maxStderr is the maximum amount of stderr output kept for error messages.
+const maxStderr = 1024
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the buffered output, with surrounding whitespace removed.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+)
+
+// JSONTailModule represents a bar.Module that displays the last JSON value
+// output by a long running command, e.g. a command with a "--format json"
+// flag.
+type JSONTailModule struct {
+	tail       *TailModule
+	showErrors value.TypedValue[bool]
+}
+
+// TailJSON constructs a module that displays the last JSON value output by a
+// long running command, one value per line. Lines that cannot be parsed are
+// skipped, keeping the output for the last successfully parsed value, unless
+// ShowErrors is used.
+func TailJSON(cmd string, args ...string) *JSONTailModule {
+	m := &JSONTailModule{tail: Tail(cmd, args...)}
+	m.Output(func(v map[string]interface{}) bar.Output {
+		out, _ := json.Marshal(v)
+		return outputs.Text(string(out))
+	})
+	return m
+}
+
+// Stream starts the module.
+func (m *JSONTailModule) Stream(s bar.Sink) {
+	m.StreamContext(context.Background(), s)
+}
+
+// StreamContext starts the module, and returns once the context is done,
+// killing the command and any child processes if it is running.
+func (m *JSONTailModule) StreamContext(ctx context.Context, s bar.Sink) {
+	m.tail.StreamContext(ctx, func(o bar.Output) {
+		if _, ok := o.(skipLine); !ok {
+			s.Output(o)
+		}
+	})
+}
+
+// skipLine is the output for lines that cannot be decoded. It is dropped by
+// the sink in StreamContext, so the previous output stays on the bar.
+type skipLine struct{}
+
+func (skipLine) Segments() []*bar.Segment { return nil }
+
+// Output sets the output format to a function that receives each line of
+// output parsed as a JSON object.
+func (m *JSONTailModule) Output(format func(map[string]interface{}) bar.Output) *JSONTailModule {
+	return m.Decode(func(line []byte) (bar.Output, error) {
+		var v map[string]interface{}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return nil, err
+		}
+		return format(v), nil
+	})
+}
+
+// Decode sets the output format to a function that decodes each line of
+// output, e.g. into a user-defined struct using json.Unmarshal, and returns
+// the output for it. If decode returns an error, the line is skipped.
+func (m *JSONTailModule) Decode(decode func(line []byte) (bar.Output, error)) *JSONTailModule {
+	m.tail.Output(func(line string) bar.Output {
+		out, err := decode([]byte(line))
+		if err == nil {
+			return out
+		}
+		if m.showErrors.Get() {
+			return outputs.Error(err)
+		}
+		return skipLine{}
+	})
+	return m
+}
+
+// ShowErrors configures whether lines that cannot be decoded are shown as an
+// error segment, instead of being skipped. The command continues to run, and
+// the next valid line replaces the error.
+func (m *JSONTailModule) ShowErrors(show bool) *JSONTailModule {
+	m.showErrors.Set(show)
+	return m
+}
+
+// Restart configures the module to restart the command when it exits.
+// See TailModule.Restart.
+func (m *JSONTailModule) Restart() *JSONTailModule {
+	m.tail.Restart()
+	return m
+}
+
+// RestartWithBackoff configures the module to restart the command when it
+// exits, with the given backoff. See TailModule.RestartWithBackoff.
+func (m *JSONTailModule) RestartWithBackoff(min, max time.Duration) *JSONTailModule {
+	m.tail.RestartWithBackoff(min, max)
+	return m
+}
+
+// MaxRecordSize sets the maximum size of a single line of output.
+// See TailModule.MaxRecordSize.
+func (m *JSONTailModule) MaxRecordSize(size int) *JSONTailModule {
+	m.tail.MaxRecordSize(size)
+	return m
+}
+
+// Env sets an environment variable for the command. See TailModule.Env.
+func (m *JSONTailModule) Env(key, value string) *JSONTailModule {
+	m.tail.Env(key, value)
+	return m
+}
+
+// Dir sets the working directory for the command.
+func (m *JSONTailModule) Dir(path string) *JSONTailModule {
+	m.tail.Dir(path)
+	return m
+}
+
+// Timeout sets an idle timeout for the command. See TailModule.Timeout.
+func (m *JSONTailModule) Timeout(timeout time.Duration) *JSONTailModule {
+	m.tail.Timeout(timeout)
+	return m
+}
+
+// SendSignal sends a signal to the command and any child processes.
+// See TailModule.SendSignal.
+func (m *JSONTailModule) SendSignal(sig os.Signal) error {
+	return m.tail.SendSignal(sig)
+}
+
+// Refresh refreshes the output using the last line of output.
+// See TailModule.Refresh.
+func (m *JSONTailModule) Refresh() {
+	m.tail.Refresh()
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+)
+
+func TestTailJSON(t *testing.T) {
+	testBar.New(t)
+	tail := TailJSON("bash", "-c", `for l in '{"a": 1}' 'oops' '{"a": 2, "b": "x"}'; do
+		echo "$l"; sleep 0.075; done`).
+		Output(func(v map[string]interface{}) bar.Output {
+			return outputs.Textf("a=%v b=%v", v["a"], v["b"])
+		})
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"a=1 b=<nil>"})
+	testBar.NextOutput().AssertText([]string{"a=2 b=x"},
+		"skips invalid lines")
+
+	testBar.New(t)
+	tail = TailJSON("bash", "-c", `for l in '{"name": "foo", "count": 3}' '[1]'; do
+		echo "$l"; sleep 0.075; done`).
+		Timeout(time.Minute).
+		ShowErrors(true).
+		Decode(func(line []byte) (bar.Output, error) {
+			var v struct {
+				Name  string
+				Count int
+			}
+			if err := json.Unmarshal(line, &v); err != nil {
+				return nil, err
+			}
+			return outputs.Textf("%s: %d", v.Name, v.Count), nil
+		})
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"foo: 3"}, "decodes into struct")
+	testBar.NextOutput().AssertError("shows decode errors")
+}
@@ -16,38 +16,106 @@
 Package shell provides modules to display the output of shell commands.
 It supports both long-running commands, where the output is the last line,
 e.g. dmesg or tail -f /var/log/some.log, and repeatedly running commands,
-e.g. whoami, date +%s.
+e.g. whoami, date +%s, either on a timer or when a file changes.
 */
 package shell // import "barista.run/modules/shell"
 
 import (
-	"os/exec"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"barista.run/bar"
 	"barista.run/base/notifier"
 	"barista.run/base/value"
+	"barista.run/base/watchers/file"
+	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
 )
 
 // Module represents a shell command module that can be updated
-// on a timer, or on demand.
+// on a timer, on demand, or when a file changes.
 type Module struct {
-	cmd       string
-	args      []string
-	outf      value.Value // of func(string) bar.Output
-	notifyCh  <-chan struct{}
-	notifyFn  func()
+	cmd       command
+	outf      value.OutputFunc[string]
 	scheduler timing.Scheduler
+	// refresh combines the scheduler with on-demand refreshes.
+	refresh *timing.Merged
+
+	// watch is the file that triggers a refresh when changed, if non-empty.
+	// Changes are debounced using the changed notifier.
+	watch     string
+	changedFn func()
+	changedCh <-chan struct{}
+
+	// The schedule is kept so that it can be set up again after the initial
+	// delay. For jittered repeats, the scheduler is re-armed after each run,
+	// since each delay is different. next is the time of the next repeat, so
+	// that on-demand refreshes do not re-arm it.
+	schedMu  sync.Mutex
+	interval time.Duration
+	sched    timing.Schedule
+	jitter   float64
+	next     time.Time
+
+	initialDelay value.TypedValue[time.Duration]
+	delay        timing.Scheduler
 }
 
+// randFloat is swappable in tests to control jitter.
+var randFloat = rand.Float64
+
 // New constructs a new shell module.
 func New(cmd string, args ...string) *Module {
-	m := &Module{cmd: cmd, args: args}
-	m.notifyFn, m.notifyCh = notifier.New()
+	return newModule(cmd, args, nil)
+}
+
+// NewPipe constructs a new shell module for a pipeline of commands. Each stage
+// is a command name followed by its arguments, and the stdout of each command
+// is connected to the stdin of the next without starting a shell. See
+// TailPipe.
+func NewPipe(stages ...[]string) *Module {
+	return newModule(splitStages(stages))
+}
+
+// changeDebounce is the quiet period after a change to a watched file before
+// the command is run, so that bursts of changes only run it once.
+const changeDebounce = 50 * time.Millisecond
+
+// OnChange constructs a shell module that runs the command when the module
+// starts, and again whenever the file at path changes, instead of on a timer.
+// Bursts of changes are coalesced into a single run. The file is watched by
+// name, so it is still watched if it is replaced (e.g. written atomically by
+// renaming a temporary file), or removed and created again. If the file can
+// no longer be watched, an error is shown until the output is next updated,
+// and refreshes set using Every, On, or Refresh continue.
+func OnChange(path string, cmd string, args ...string) *Module {
+	m := newModule(cmd, args, nil)
+	m.watch = path
+	m.changedFn, m.changedCh = notifier.Debounced(changeDebounce)
+	l.SetFields(m, "cmd", cmd, "watch", path)
+	return m
+}
+
+// watchFile watches a file for changes, returning channels for changes and
+// errors, and a function to stop watching. Swappable in tests.
+var watchFile = func(path string) (<-chan struct{}, <-chan error, func()) {
+	w := file.Watch(path)
+	return w.Updates, w.Errors, w.Unsubscribe
+}
+
+func newModule(cmd string, args []string, pipe [][]string) *Module {
+	m := &Module{cmd: command{name: cmd, args: args, pipe: pipe}}
+	l.SetFields(m, "cmd", cmd)
 	m.scheduler = timing.NewScheduler()
+	m.refresh = timing.Merge(m.scheduler)
+	m.delay = timing.NewScheduler()
 	m.outf.Set(func(text string) bar.Output {
 		return outputs.Text(text)
 	})
@@ -56,24 +124,83 @@ func New(cmd string, args ...string) *Module {
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	out, err := exec.Command(m.cmd, m.args...).Output()
-	outf := m.outf.Get().(func(string) bar.Output)
+	m.StreamContext(context.Background(), s)
+}
+
+// StreamContext starts the module, and returns once the context is done,
+// killing the command if it is running.
+func (m *Module) StreamContext(ctx context.Context, s bar.Sink) {
+	var changes <-chan struct{}
+	var watchErrs <-chan error
+	if m.watch != "" {
+		var stop func()
+		changes, watchErrs, stop = watchFile(m.watch)
+		defer stop()
+	}
+	if d := m.initialDelay.Get(); d > 0 {
+		// Repeats are counted from the first run, so they are staggered too.
+		m.scheduler.Stop()
+		m.delay.After(d)
+		select {
+		case <-m.delay.Tick():
+		case <-m.refresh.Tick():
+			m.delay.Stop()
+		case <-ctx.Done():
+			m.delay.Stop()
+			return
+		}
+		m.schedMu.Lock()
+		m.schedule()
+		m.schedMu.Unlock()
+	}
+	out, err := m.exec(ctx)
+	outf := m.outf.Get()
+	render := true
 	for {
-		if s.Error(err) {
+		if ctx.Err() != nil || s.Error(err) {
 			return
 		}
-		s.Output(outf(strings.TrimSpace(string(out))))
+		if render {
+			s.Output(value.SafeOutput(outf, strings.TrimSpace(string(out))))
+		}
+		render = true
 		select {
 		case <-m.outf.Next():
-			outf = m.outf.Get().(func(string) bar.Output)
-		case <-m.notifyCh:
-			out, err = exec.Command(m.cmd, m.args...).Output()
-		case <-m.scheduler.Tick():
-			out, err = exec.Command(m.cmd, m.args...).Output()
+			outf = m.outf.Get()
+		case <-m.refresh.Tick():
+			m.rearm()
+			out, err = m.exec(ctx)
+		case <-changes:
+			m.changedFn()
+			render = false
+		case <-m.changedCh:
+			out, err = m.exec(ctx)
+		case watchErr := <-watchErrs:
+			// The watcher stops on errors, but the command is still run on
+			// refreshes, so show the error until then instead of stopping.
+			l.Warn(m, "watch failed", "err", watchErr)
+			s.Error(watchErr)
+			changes, watchErrs = nil, nil
+			render = false
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+// exec runs the command and returns its output, or a *CommandError.
+func (m *Module) exec(ctx context.Context) ([]byte, error) {
+	p := m.cmd.build(ctx)
+	stderr := &tailBuffer{max: maxStderr}
+	p.setStderr(stderr)
+	out, err := p.output()
+	err = commandError(err, stderr.String())
+	if err != nil {
+		l.Warn(m, "command failed", "code", exitCode(err), "err", err)
+	}
+	return out, err
+}
+
 // Output sets the output format. The format func will be passed the
 // entire trimmed output from the command once it's done executing.
 // To process output by lines, see Tail().
@@ -86,15 +213,128 @@ func (m *Module) Output(format func(string) bar.Output) *Module {
 // executed repeatedly at the given interval, and the output updated.
 // A zero interval stops automatic repeats (but Refresh will still work).
 func (m *Module) Every(interval time.Duration) *Module {
-	if interval == 0 {
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	m.interval, m.sched = interval, nil
+	m.schedule()
+	return m
+}
+
+// Jitter randomises each interval set using Every by up to ±fraction of the
+// interval, so that several modules repeating at the same interval do not all
+// run their commands at the same time. Fractions outside [0,1] are clamped.
+// It only affects repeats set using Every, not schedules set using On, or
+// tailed commands.
+func (m *Module) Jitter(fraction float64) *Module {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	m.jitter = fraction
+	if m.interval > 0 {
+		m.schedule()
+	}
+	return m
+}
+
+// InitialDelay delays the first run of the command by the given duration when
+// the module starts, e.g. to stagger the startup of several modules. Repeats
+// set using Every are counted from the first run, so they remain staggered.
+// A call to Refresh during the delay runs the command immediately.
+func (m *Module) InitialDelay(d time.Duration) *Module {
+	m.initialDelay.Set(d)
+	return m
+}
+
+// schedule configures the scheduler for the current schedule, or interval and
+// jitter. Must be called with schedMu held.
+func (m *Module) schedule() {
+	m.next = time.Time{}
+	switch {
+	case m.sched != nil:
+		m.scheduler.On(m.sched)
+	case m.interval == 0:
 		m.scheduler.Stop()
-	} else {
-		m.scheduler.Every(interval)
+	case m.jitter == 0:
+		m.scheduler.Every(m.interval)
+	default:
+		delay := m.jittered()
+		m.next = timing.Now().Add(delay)
+		m.scheduler.After(delay)
 	}
+}
+
+// jittered returns the interval, randomised by the jitter fraction.
+// Must be called with schedMu held.
+func (m *Module) jittered() time.Duration {
+	offset := (2*randFloat() - 1) * m.jitter * float64(m.interval)
+	return m.interval + time.Duration(offset)
+}
+
+// rearm schedules the next jittered repeat, if the current one is due.
+func (m *Module) rearm() {
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	if m.next.IsZero() || timing.Now().Before(m.next) {
+		return
+	}
+	delay := m.jittered()
+	m.next = timing.Now().Add(delay)
+	m.scheduler.After(delay)
+}
+
+// On sets a schedule for the module, e.g. from timing.CronSchedule. The
+// command will be executed at each of the scheduled times, replacing any
+// interval set using Every.
+func (m *Module) On(sched timing.Schedule) *Module {
+	m.schedMu.Lock()
+	defer m.schedMu.Unlock()
+	m.interval, m.sched = 0, sched
+	m.schedule()
+	return m
+}
+
+// Env sets an environment variable for the command. The command inherits the
+// environment of the bar, with any variables set here taking precedence.
+func (m *Module) Env(key, value string) *Module {
+	m.cmd.setEnv(key, value)
+	return m
+}
+
+// Dir sets the working directory for the command.
+func (m *Module) Dir(path string) *Module {
+	m.cmd.setDir(path)
+	return m
+}
+
+// StdIn sets the input for the command. The reader is read to completion
+// before the first run, and the same data is written to the command's stdin
+// on each run. If reading fails, the command receives the data read until
+// the error.
+func (m *Module) StdIn(r io.Reader) *Module {
+	var once sync.Once
+	var data []byte
+	m.cmd.setStdin(func() io.Reader {
+		once.Do(func() { data, _ = ioutil.ReadAll(r) })
+		return bytes.NewReader(data)
+	})
+	return m
+}
+
+// StdInString sets the input for the command to the given string, which is
+// written to the command's stdin on each run.
+func (m *Module) StdInString(in string) *Module {
+	m.cmd.setStdin(func() io.Reader {
+		return strings.NewReader(in)
+	})
 	return m
 }
 
 // Refresh executes the command and updates the output.
 func (m *Module) Refresh() {
-	m.notifyFn()
+	m.refresh.Trigger()
 }
@@ -15,6 +15,13 @@
 package shell
 
 import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -80,3 +87,296 @@ func TestUpdate(t *testing.T) {
 	m.Refresh()
 	testBar.NextOutput("on refresh").AssertText([]string{"*bar*"})
 }
+
+func TestEnvAndDir(t *testing.T) {
+	testBar.New(t)
+	os.Setenv("BARISTA_SHELL_INHERITED", "inherited")
+	defer os.Unsetenv("BARISTA_SHELL_INHERITED")
+
+	m := New("sh", "-c", `echo "$FOO $BARISTA_SHELL_INHERITED $(pwd)"`).
+		Env("FOO", "foo").
+		Dir("/")
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"foo inherited /"})
+
+	m.Env("FOO", "bar").Env("BARISTA_SHELL_INHERITED", "overridden")
+	m.Refresh()
+	testBar.NextOutput().AssertText([]string{"bar overridden /"},
+		"later values take precedence")
+
+	testBar.New(t)
+	tail := Tail("sh", "-c", `echo "$FOO $BARISTA_SHELL_INHERITED"; sleep 0.075; pwd`).
+		Env("FOO", "tail").
+		Dir("/tmp")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"tail inherited"})
+	testBar.NextOutput().AssertText([]string{"/tmp"})
+}
+
+func TestStdIn(t *testing.T) {
+	testBar.New(t)
+	m := New("tr", "a-z", "A-Z").StdInString("hello").Every(time.Second)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"HELLO"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"HELLO"}, "input is re-supplied")
+
+	testBar.New(t)
+	m = New("wc", "-l").StdIn(strings.NewReader("a\nb\nc\n")).Every(time.Second)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"3"})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3"}, "reader is replayed")
+
+	testBar.New(t)
+	m = New("sh", "-c", "sleep 0.075; head -c 3").
+		StdInString(strings.Repeat("x", 1<<20))
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"xxx"},
+		"when the command does not read all input")
+}
+
+func TestCommandError(t *testing.T) {
+	testBar.New(t)
+	m := New("sh", "-c", "echo out; echo oops >&2; exit 3")
+	testBar.Run(m)
+	err := testBar.NextOutput().At(0).Segment().GetError()
+	cmdErr, ok := err.(*CommandError)
+	require.True(t, ok, "error is a *CommandError")
+	require.Equal(t, 3, cmdErr.ExitCode)
+	require.Equal(t, "oops", cmdErr.Stderr)
+	require.Equal(t, "exit status 3: oops", cmdErr.Error())
+
+	testBar.New(t)
+	m = New("this-is-not-a-valid-command")
+	testBar.Run(m)
+	err = testBar.NextOutput().At(0).Segment().GetError()
+	require.IsType(t, &CommandError{}, err)
+	require.Equal(t, -1, err.(*CommandError).ExitCode)
+	require.True(t, errors.Is(err, exec.ErrNotFound), "wraps original error")
+
+	testBar.New(t)
+	tail := Tail("sh", "-c", "echo out; sleep 0.075; echo oops >&2; exit 4")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"out"})
+	err = testBar.NextOutput().At(0).Segment().GetError()
+	require.Equal(t, &CommandError{
+		Err:      err.(*CommandError).Err,
+		ExitCode: 4,
+		Stderr:   "oops",
+	}, err, "from tail command")
+
+	testBar.New(t)
+	tail = Tail("this-is-not-a-valid-command")
+	testBar.Run(tail)
+	err = testBar.NextOutput().At(0).Segment().GetError()
+	require.IsType(t, &CommandError{}, err, "when tail command fails to start")
+	require.True(t, errors.Is(err, exec.ErrNotFound))
+}
+
+func TestSchedule(t *testing.T) {
+	testBar.New(t)
+	sched, err := timing.CronSchedule("0 9 * * *")
+	require.NoError(t, err)
+	m := New("echo", "foo").On(sched)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"foo"}, "on start")
+	require.Equal(t, "09:00", timing.NextTick().Format("15:04"))
+	testBar.NextOutput().AssertText([]string{"foo"}, "on schedule")
+}
+
+func TestPipe(t *testing.T) {
+	testBar.New(t)
+	m := NewPipe([]string{"echo", "hello world"}, []string{"tr", "a-z", "A-Z"})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"HELLO WORLD"})
+
+	testBar.New(t)
+	m = NewPipe([]string{"echo", "foo"}, []string{"sh", "-c", "cat; echo oops >&2; exit 2"},
+		[]string{"cat"})
+	testBar.Run(m)
+	err := testBar.NextOutput().At(0).Segment().GetError()
+	require.Equal(t, &CommandError{
+		Err:      err.(*CommandError).Err,
+		ExitCode: 2,
+		Stderr:   "oops",
+		Command:  "sh",
+		Stage:    1,
+	}, err, "identifies the failed stage")
+	require.Equal(t, "sh[1]: exit status 2: oops", err.Error())
+
+	testBar.New(t)
+	m = NewPipe([]string{"yes"}, []string{"head", "-n", "2"})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"y\ny"},
+		"ignores SIGPIPE in earlier stages")
+
+	testBar.New(t)
+	m = NewPipe([]string{"echo", "foo"}, []string{"this-is-not-a-valid-command"})
+	testBar.Run(m)
+	err = testBar.NextOutput().At(0).Segment().GetError()
+	require.True(t, errors.Is(err, exec.ErrNotFound), "when a stage fails to start")
+	require.Equal(t, 1, err.(*CommandError).Stage)
+
+	testBar.New(t)
+	m = NewPipe()
+	testBar.Run(m)
+	testBar.NextOutput().AssertError("on empty pipeline")
+}
+
+func TestOnChange(t *testing.T) {
+	testBar.New(t)
+	clock := timing.NewTestClock()
+	dir, err := ioutil.TempDir("", "barista-onchange")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	status := filepath.Join(dir, "status")
+	write := func(file, content string) {
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	// awaitChange waits for a change to be noticed, then fires the debounce
+	// timer once the burst of events has settled.
+	awaitChange := func(msg string) {
+		require.Eventually(t, func() bool {
+			return !clock.NextTickTime().IsZero()
+		}, time.Second, time.Millisecond, msg)
+		time.Sleep(20 * time.Millisecond)
+		timing.NextTick()
+	}
+
+	write(status, "Charging\n")
+	m := OnChange(status, "cat", status)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"Charging"}, "on start")
+	// Give the watch time to be established.
+	time.Sleep(50 * time.Millisecond)
+
+	write(status, "Full\n")
+	write(status, "Discharging\n")
+	testBar.AssertNoOutput("until debounced")
+	awaitChange("on write")
+	testBar.NextOutput().AssertText([]string{"Discharging"}, "on write")
+	testBar.AssertNoOutput("burst of writes is coalesced")
+
+	tmp := filepath.Join(dir, "status.tmp")
+	write(tmp, "Charging\n")
+	require.NoError(t, os.Rename(tmp, status))
+	awaitChange("on atomic replace")
+	testBar.NextOutput().AssertText([]string{"Charging"}, "on atomic replace")
+
+	time.Sleep(50 * time.Millisecond)
+	write(status, "Full\n")
+	awaitChange("after replace")
+	testBar.NextOutput().AssertText([]string{"Full"},
+		"still watching after replace")
+
+	m.Refresh()
+	testBar.NextOutput().AssertText([]string{"Full"}, "on refresh")
+
+	// Removing the file also triggers a run, which fails and stops the module,
+	// so that it does not interfere with later tests.
+	require.NoError(t, os.RemoveAll(dir))
+	awaitChange("on remove")
+	testBar.NextOutput().AssertError("on remove")
+}
+
+func TestOnChangeWatchError(t *testing.T) {
+	testBar.New(t)
+	errs := make(chan error, 1)
+	oldWatch := watchFile
+	watchFile = func(string) (<-chan struct{}, <-chan error, func()) {
+		return nil, errs, func() {}
+	}
+	defer func() { watchFile = oldWatch }()
+
+	m := OnChange("/status", "echo", "foo").Every(time.Minute)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"foo"}, "on start")
+
+	errs <- errors.New("watch failed")
+	testBar.NextOutput().AssertError("on watch error")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"foo"},
+		"keeps running on schedule after watch error")
+}
+
+func TestOutputPanic(t *testing.T) {
+	testBar.New(t)
+	var m map[string]string
+	rep := New("echo", "foo").Every(time.Second).Output(func(s string) bar.Output {
+		if m[s] == "" {
+			m[s] = s // panics, since m is nil.
+		}
+		return outputs.Text(s)
+	})
+	testBar.Run(rep)
+	out := testBar.NextOutput("on panic")
+	require.Error(t, out.At(0).Segment().GetError())
+
+	m = map[string]string{}
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"foo"}, "keeps running after panic")
+}
+
+func TestJitter(t *testing.T) {
+	testBar.New(t)
+	defer func() { randFloat = rand.Float64 }()
+	rands := []float64{0, 1, 0.5}
+	randFloat = func() float64 {
+		r := rands[0]
+		rands = append(rands[1:], r)
+		return r
+	}
+
+	start := timing.Now()
+	rep := New("echo", "foo").Every(10 * time.Second).Jitter(0.2)
+	testBar.Run(rep)
+	testBar.NextOutput().AssertText([]string{"foo"}, "on start")
+
+	require.Equal(t, start.Add(8*time.Second), timing.NextTick(), "-20%")
+	testBar.NextOutput().Expect("on tick")
+	require.Equal(t, start.Add(20*time.Second), timing.NextTick(), "+20%")
+	testBar.NextOutput().Expect("on tick")
+
+	timing.AdvanceBy(3 * time.Second)
+	rep.Refresh()
+	testBar.NextOutput().Expect("on refresh")
+	require.Equal(t, start.Add(30*time.Second), timing.NextTick(),
+		"refresh does not change the schedule")
+	testBar.NextOutput().Expect("on tick")
+
+	randFloat = rand.Float64
+	for i := 0; i < 20; i++ {
+		prev := timing.Now()
+		now := timing.NextTick()
+		testBar.NextOutput().Expect("on tick")
+		require.True(t, now.Sub(prev) >= 8*time.Second && now.Sub(prev) <= 12*time.Second,
+			"jittered interval %v out of bounds", now.Sub(prev))
+	}
+
+	rep.Jitter(0)
+	prev := timing.Now()
+	require.Equal(t, prev.Add(10*time.Second), timing.NextTick(), "without jitter")
+	testBar.NextOutput().Expect("on tick")
+}
+
+func TestInitialDelay(t *testing.T) {
+	testBar.New(t)
+	start := timing.Now()
+	rep := New("echo", "foo").Every(time.Second).InitialDelay(5 * time.Second)
+	testBar.Run(rep)
+	testBar.AssertNoOutput("during initial delay")
+
+	require.Equal(t, start.Add(5*time.Second), timing.NextTick())
+	testBar.NextOutput().AssertText([]string{"foo"}, "after initial delay")
+	require.Equal(t, start.Add(6*time.Second), timing.NextTick(),
+		"repeats are counted from the first run")
+	testBar.NextOutput().Expect("on tick")
+
+	testBar.New(t)
+	rep = New("echo", "bar").InitialDelay(time.Minute)
+	testBar.Run(rep)
+	testBar.AssertNoOutput("during initial delay")
+	rep.Refresh()
+	testBar.NextOutput().AssertText([]string{"bar"}, "refresh during initial delay")
+}
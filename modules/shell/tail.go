@@ -22,32 +22,84 @@ package shell
 
 import (
 	"bufio"
-	"os/exec"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sync"
 	"syscall"
+	"time"
 
 	"barista.run/bar"
+	"barista.run/base/click"
 	"barista.run/base/notifier"
 	"barista.run/base/value"
+	l "barista.run/logging"
 	"barista.run/outputs"
+	"barista.run/timing"
 )
 
 // TailModule represents a bar.Module that displays the last line
 // of output from a shell command in the bar.
 type TailModule struct {
-	cmd       string
-	args      []string
-	outf      value.Value // of func(string) bar.Output
+	cmd       command
+	outf      value.OutputFunc[[]string]
+	lines     value.TypedValue[int]
 	refreshCh <-chan struct{}
 	refreshFn func()
+	timeout   value.TypedValue[time.Duration]
+	// includeStderr is read when the command is started, so changes
+	// only apply on restart.
+	includeStderr value.TypedValue[bool]
+	backoff       value.TypedValue[restartBackoff]
+	split         value.TypedValue[bufio.SplitFunc]
+	maxRecordSize value.TypedValue[int]
+	idle          timing.Scheduler
+	restart       timing.Scheduler
+
+	// pgid of the running command, or 0 if the command is not running.
+	pgid   int
+	pgidMu sync.Mutex
+}
+
+// restartBackoff holds the range of delays before restarting the command.
+// A zero max disables restarts.
+type restartBackoff struct {
+	min, max time.Duration
 }
 
 // Tail constructs a module that displays the last line of output from
 // a long running command. Use the reformat module to adjust the output
 // if necessary.
 func Tail(cmd string, args ...string) *TailModule {
-	t := &TailModule{cmd: cmd, args: args}
-	t.refreshFn, t.refreshCh = notifier.New()
-	t.outf.Set(func(text string) bar.Output {
+	return newTail(cmd, args, nil)
+}
+
+// TailPipe constructs a module that displays the last line of output from
+// a long running pipeline of commands. Each stage is a command name followed
+// by its arguments, and the stdout of each command is connected to the stdin
+// of the next without starting a shell, e.g.
+//     shell.TailPipe([]string{"journalctl", "-f"}, []string{"grep", "error"})
+// is equivalent to `journalctl -f | grep error`.
+func TailPipe(stages ...[]string) *TailModule {
+	return newTail(splitStages(stages))
+}
+
+func newTail(cmd string, args []string, pipe [][]string) *TailModule {
+	t := &TailModule{
+		cmd:     command{name: cmd, args: args, pipe: pipe},
+		idle:    timing.NewScheduler(),
+		restart: timing.NewScheduler(),
+	}
+	l.SetFields(t, "cmd", cmd)
+	t.refreshFn, t.refreshCh = notifier.Debounced(refreshDebounce)
+	t.split.Set(bufio.ScanLines)
+	t.lines.Set(1)
+	t.Output(func(text string) bar.Output {
 		return outputs.Text(text)
 	})
 	return t
@@ -55,56 +107,414 @@ func Tail(cmd string, args ...string) *TailModule {
 
 // Stream starts the module.
 func (m *TailModule) Stream(s bar.Sink) {
-	cmd := exec.Command(m.cmd, m.args...)
-	// Prevent SIGUSR for bar pause/resume from propagating to the
-	// child process. Some commands don't play nice with signals.
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-		Pgid:    0,
-	}
-	stdout, err := cmd.StdoutPipe()
-	if s.Error(err) {
-		return
-	}
-	if s.Error(cmd.Start()) {
-		return
-	}
-	var out *string
-	outf := m.outf.Get().(func(string) bar.Output)
-	errChan := make(chan error)
+	m.StreamContext(context.Background(), s)
+}
+
+// StreamContext starts the module, and returns once the context is done,
+// killing the command and any child processes if it is running.
+func (m *TailModule) StreamContext(ctx context.Context, s bar.Sink) {
+	st := &tailState{outf: m.outf.Get(), nextOutf: m.outf.Next()}
+	var delay time.Duration
+	restarted := false
+	for {
+		started := timing.Now()
+		err := m.run(ctx, s, st)
+		if ctx.Err() != nil {
+			return
+		}
+		if se, ok := err.(startError); ok {
+			var pe *pathError
+			if !errors.As(se, &pe) {
+				s.Error(se.error)
+				return
+			}
+			// The command might be installed or fixed later, so keep the
+			// module running until the user asks to retry.
+			if !m.waitRetry(ctx, s, se.error) {
+				return
+			}
+			continue
+		}
+		b := m.backoff.Get()
+		if b.max <= 0 {
+			if err != nil {
+				l.Warn(m, "command failed", "code", exitCode(err), "err", err)
+			}
+			s.Error(err)
+			return
+		}
+		// Reset the delay if the command ran for a while, otherwise back
+		// off exponentially.
+		if !restarted || timing.Now().Sub(started) >= b.max {
+			delay = b.min
+		} else if delay *= 2; delay > b.max {
+			delay = b.max
+		}
+		restarted = true
+		l.Warn(m, "command exited, restarting",
+			"code", exitCode(err), "err", err, "delay", delay)
+		if !m.waitRestart(ctx, s, st, delay) {
+			return
+		}
+	}
+}
+
+// tailState holds the output state of the module across restarts of the
+// command.
+type tailState struct {
+	lines    []string
+	outf     func([]string) bar.Output
+	nextOutf <-chan struct{}
+}
+
+// push adds a line, keeping at most max lines.
+func (st *tailState) push(line string, max int) {
+	st.lines = append(st.lines, line)
+	if over := len(st.lines) - max; over > 0 {
+		st.lines = append(st.lines[:0], st.lines[over:]...)
+	}
+}
+
+// render sends the output for the retained lines, if any, to the sink.
+func (st *tailState) render(s bar.Sink) {
+	if len(st.lines) > 0 {
+		s.Output(value.SafeOutput(st.outf, st.lines))
+	}
+}
+
+// startError wraps errors from starting the command, which are not retried
+// automatically.
+type startError struct{ error }
+
+func (e startError) Unwrap() error { return e.error }
+
+// run starts the command, and displays its output until it exits or the
+// context is done.
+func (m *TailModule) run(ctx context.Context, s bar.Sink, st *tailState) error {
+	p := m.cmd.build(ctx)
+	p.setpgid = true
+	var stdout io.Reader
+	var stderr *tailBuffer
+	if m.includeStderr.Get() {
+		// Use the same pipe for both, so lines from stdout and stderr
+		// are interleaved in the order they were written.
+		r, w, err := os.Pipe()
+		if err != nil {
+			return startError{err}
+		}
+		defer r.Close()
+		p.last().Stdout = w
+		p.setStderr(w)
+		stdout = r
+		err = p.start()
+		w.Close()
+		if err != nil {
+			return startError{commandError(err, "")}
+		}
+	} else {
+		pipe, err := p.last().StdoutPipe()
+		if err != nil {
+			return startError{commandError(err, "")}
+		}
+		stdout = pipe
+		stderr = &tailBuffer{max: maxStderr}
+		p.setStderr(stderr)
+		if err := p.start(); err != nil {
+			return startError{commandError(err, "")}
+		}
+	}
+	m.setPgid(p.pid())
+	errChan := make(chan error, 1)
 	outChan := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(m.split.Get())
+	if max := m.maxRecordSize.Get(); max > 0 {
+		scanner.Buffer(nil, max)
+	}
 	go func() {
-		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
-			outChan <- scanner.Text()
+			select {
+			case outChan <- scanner.Text():
+			case <-done:
+				// Drain the remaining output so the command can exit.
+				for scanner.Scan() {
+				}
+			}
+		}
+		scanErr := scanner.Err()
+		if scanErr != nil {
+			// The scanner cannot resume after an error (e.g. a record that
+			// is too long), so stop the command rather than waiting for it
+			// to exit on its own.
+			syscall.Kill(-p.pid(), syscall.SIGKILL)
+			io.Copy(ioutil.Discard, stdout)
+		}
+		err := p.wait()
+		m.setPgid(0)
+		if err == nil || scanErr != nil {
+			errChan <- scanErr
+			return
 		}
-		errChan <- cmd.Wait()
+		var msg string
+		if stderr != nil {
+			msg = stderr.String()
+		}
+		errChan <- commandError(err, msg)
 	}()
+	m.resetIdle()
+	defer m.idle.Stop()
 	for {
 		select {
-		case e := <-errChan:
-			s.Error(e)
-			return
-		case <-m.outf.Next():
-			outf = m.outf.Get().(func(string) bar.Output)
+		case err := <-errChan:
+			return err
+		case <-st.nextOutf:
+			st.nextOutf = m.outf.Next()
+			st.outf = m.outf.Get()
 		case txt := <-outChan:
-			out = &txt
+			st.push(txt, m.lines.Get())
+			m.resetIdle()
+		case <-m.idle.Tick():
+			timeout := m.timeout.Get()
+			killAndWait(p.pid(), outChan, errChan)
+			return fmt.Errorf("%s: no output for %v", m.cmd.name, timeout)
+		case <-ctx.Done():
+			killAndWait(p.pid(), outChan, errChan)
+			return ctx.Err()
 		case <-m.refreshCh:
 		}
-		if out != nil {
-			s.Output(outf(*out))
+		st.render(s)
+	}
+}
+
+// killAndWait kills the entire process group, so all commands in the pipeline
+// and any of their children are also terminated, and waits for the commands to
+// exit so that the pipes are cleaned up.
+func killAndWait(pgid int, outChan <-chan string, errChan <-chan error) {
+	syscall.Kill(-pgid, syscall.SIGKILL)
+	for {
+		select {
+		case <-outChan:
+		case <-errChan:
+			return
 		}
 	}
 }
 
+// waitRetry shows the error until it is clicked, and returns true when the
+// command should be restarted, or false if the context is done.
+func (m *TailModule) waitRetry(ctx context.Context, s bar.Sink, err error) bool {
+	retryFn, retryCh := notifier.New()
+	s.Output(outputs.Error(err).OnClick(click.Click(retryFn)))
+	select {
+	case <-retryCh:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitRestart waits for the restart delay, while continuing to update the
+// output if needed. It returns true when the command should be restarted,
+// or false if the context is done.
+func (m *TailModule) waitRestart(ctx context.Context, s bar.Sink, st *tailState, delay time.Duration) bool {
+	m.restart.After(delay)
+	defer m.restart.Stop()
+	for {
+		select {
+		case <-m.restart.Tick():
+			return true
+		case <-ctx.Done():
+			return false
+		case <-st.nextOutf:
+			st.nextOutf = m.outf.Next()
+			st.outf = m.outf.Get()
+		case <-m.refreshCh:
+		}
+		st.render(s)
+	}
+}
+
 // Output sets the output format for each line of output.
 func (m *TailModule) Output(format func(string) bar.Output) *TailModule {
+	return m.OutputMulti(func(lines []string) bar.Output {
+		return format(lines[len(lines)-1])
+	})
+}
+
+// OutputMulti sets the output format to a function that receives the most
+// recent lines of output, oldest first. The number of lines is configured
+// using Lines, and may be fewer than that before the command has produced
+// enough output. The slice must not be retained by the format func.
+func (m *TailModule) OutputMulti(format func([]string) bar.Output) *TailModule {
 	m.outf.Set(format)
 	return m
 }
 
+// Lines sets the number of most recent lines of output retained for
+// OutputMulti. The default is 1, i.e. only the last line is kept.
+func (m *TailModule) Lines(n int) *TailModule {
+	if n < 1 {
+		n = 1
+	}
+	m.lines.Set(n)
+	return m
+}
+
+// Parse sets the output format to a function that receives the named
+// capture groups from matching each line of output against the given regular
+// expression, e.g. `(?P<cpu>\d+)% (?P<mem>\d+)%` yields the fields "cpu" and
+// "mem". Lines that don't match are ignored, and the last successfully parsed
+// fields are used instead. This replaces any format set using Output.
+func (m *TailModule) Parse(re *regexp.Regexp, format func(map[string]string) bar.Output) *TailModule {
+	var lastFields map[string]string
+	return m.Output(func(line string) bar.Output {
+		if fields := parseFields(re, line); fields != nil {
+			lastFields = fields
+		}
+		if lastFields == nil {
+			return nil
+		}
+		return format(lastFields)
+	})
+}
+
+// parseFields returns the named capture groups from matching the line against
+// the regular expression, or nil if it does not match.
+func parseFields(re *regexp.Regexp, line string) map[string]string {
+	match := re.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	fields := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			fields[name] = match[i]
+		}
+	}
+	return fields
+}
+
+// Restart configures the module to restart the command when it exits, using a
+// backoff between 1 second and 1 minute. See RestartWithBackoff.
+func (m *TailModule) Restart() *TailModule {
+	return m.RestartWithBackoff(time.Second, time.Minute)
+}
+
+// RestartWithBackoff configures the module to restart the command when it
+// exits, instead of showing an error. The command is restarted after min, and
+// the delay doubles on each consecutive restart up to max. Once the command
+// runs for at least max, the delay is reset to min. Errors starting the
+// command are still shown, since they are unlikely to resolve on their own.
+// Both durations should be positive; a zero max disables restarts.
+func (m *TailModule) RestartWithBackoff(min, max time.Duration) *TailModule {
+	m.backoff.Set(restartBackoff{min, max})
+	return m
+}
+
+// SplitFunc sets the function used to split the command's output into records,
+// instead of the default bufio.ScanLines. Each record is passed to the output
+// function as one "line". Changes apply the next time the command is started.
+func (m *TailModule) SplitFunc(split bufio.SplitFunc) *TailModule {
+	m.split.Set(split)
+	return m
+}
+
+// SplitOn splits the command's output into records separated by the given
+// delimiter, e.g. '\x00' for commands with a "--null" flag.
+func (m *TailModule) SplitOn(delim byte) *TailModule {
+	return m.SplitFunc(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+}
+
+// MaxRecordSize sets the maximum size of a single record (line) of output.
+// The default is bufio.MaxScanTokenSize (64KiB). If the command outputs a
+// longer record, the command is killed, and the module shows an error, or
+// restarts the command if configured using Restart. Changes apply the next
+// time the command is started.
+func (m *TailModule) MaxRecordSize(size int) *TailModule {
+	m.maxRecordSize.Set(size)
+	return m
+}
+
+// IncludeStderr configures whether the command's stderr is displayed along with
+// its stdout. If false (the default), stderr is only used to add detail to the
+// error shown when the command fails.
+func (m *TailModule) IncludeStderr(include bool) *TailModule {
+	m.includeStderr.Set(include)
+	return m
+}
+
+// Env sets an environment variable for the command. The command inherits the
+// environment of the bar, with any variables set here taking precedence.
+func (m *TailModule) Env(key, value string) *TailModule {
+	m.cmd.setEnv(key, value)
+	return m
+}
+
+// Dir sets the working directory for the command.
+func (m *TailModule) Dir(path string) *TailModule {
+	m.cmd.setDir(path)
+	return m
+}
+
+// Timeout sets an idle timeout for the command. If the command does not
+// output a line within the timeout, it is killed along with any child
+// processes, and the module shows an error. A zero timeout (the default)
+// waits for output indefinitely.
+func (m *TailModule) Timeout(timeout time.Duration) *TailModule {
+	m.timeout.Set(timeout)
+	return m
+}
+
+// resetIdle restarts the idle timeout, if any.
+func (m *TailModule) resetIdle() {
+	if timeout := m.timeout.Get(); timeout > 0 {
+		m.idle.After(timeout)
+	} else {
+		m.idle.Stop()
+	}
+}
+
+var errNotRunning = errors.New("command is not running")
+
+// SendSignal sends a signal to the command and any child processes, e.g. to
+// ask the command to update its output. It is safe to call from a click
+// handler, and returns an error if the command is not running.
+func (m *TailModule) SendSignal(sig os.Signal) error {
+	sysSig, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal: %v", sig)
+	}
+	m.pgidMu.Lock()
+	defer m.pgidMu.Unlock()
+	if m.pgid == 0 {
+		return errNotRunning
+	}
+	return syscall.Kill(-m.pgid, sysSig)
+}
+
+func (m *TailModule) setPgid(pgid int) {
+	m.pgidMu.Lock()
+	defer m.pgidMu.Unlock()
+	m.pgid = pgid
+}
+
+// refreshDebounce is the quiet period after a call to Refresh before the
+// output is refreshed, so that bursts of calls only refresh once.
+const refreshDebounce = 50 * time.Millisecond
+
 // Refresh refreshes the output using the last line of output format func.
 // Useful when paired with a scheduler if your output format has a relative time.
+// Multiple calls in quick succession are coalesced into a single refresh.
 func (m *TailModule) Refresh() {
 	m.refreshFn()
 }
@@ -15,13 +15,28 @@
 package shell
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/sink"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestTail(t *testing.T) {
@@ -72,7 +87,383 @@ func TestTailRefresh(t *testing.T) {
 
 	timing.AdvanceBy(15 * time.Second)
 	tail.Refresh()
+	tail.Refresh()
+	testBar.AssertNoOutput("refresh is debounced")
+	timing.NextTick()
 
 	testBar.NextOutput().AssertText([]string{"[47:15] 1"})
 	testBar.AssertNoOutput("sleep is still too long (75s)")
 }
+
+func TestTailParse(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", `for l in "starting" "10% 20%" "oops" "30% 45%" ""; do
+		echo "$l"; sleep 0.075; done`).
+		Parse(regexp.MustCompile(`(?P<cpu>\d+)% (?P<mem>\d+)%`),
+			func(f map[string]string) bar.Output {
+				return outputs.Group(
+					outputs.Textf("cpu %s", f["cpu"]),
+					outputs.Textf("mem %s", f["mem"]),
+				)
+			})
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertEmpty("before any lines match")
+	testBar.NextOutput().AssertText([]string{"cpu 10", "mem 20"})
+	testBar.NextOutput().AssertText([]string{"cpu 10", "mem 20"},
+		"keeps last good parse on non-matching line")
+	testBar.NextOutput().AssertText([]string{"cpu 30", "mem 45"})
+	testBar.NextOutput().AssertText([]string{"cpu 30", "mem 45"},
+		"keeps last good parse on empty line")
+}
+
+func TestTailTimeout(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", "echo 1; sleep 0.075; echo 2; sleep 75").
+		Timeout(10 * time.Second)
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"1"})
+	timing.AdvanceBy(6 * time.Second)
+	testBar.NextOutput().AssertText([]string{"2"})
+	timing.AdvanceBy(6 * time.Second)
+	testBar.AssertNoOutput("timeout is reset on each line")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertError("when command times out")
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", "echo 1; sleep 0.075; echo 2")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"1"})
+	timing.AdvanceBy(time.Hour)
+	testBar.NextOutput().AssertText([]string{"2"}, "no timeout by default")
+	testBar.NextOutput("sets restart click handler").
+		AssertText([]string{"2"}, "when command terminates normally")
+}
+
+func TestTailStderr(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", "echo out; sleep 0.075; echo oops >&2; exit 2")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"out"})
+	errs := testBar.NextOutput().AssertError("when command fails")
+	require.Equal(t, []string{"exit status 2: oops"}, errs)
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", "echo out; sleep 0.075; echo err >&2; sleep 0.075; echo out2").
+		IncludeStderr(true)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"out"})
+	testBar.NextOutput().AssertText([]string{"err"}, "includes stderr lines")
+	testBar.NextOutput().AssertText([]string{"out2"})
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", "head -c 200000 /dev/zero | tr '\\0' x >&2; echo done")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"done"},
+		"does not block when stderr fills its pipe")
+
+	b := &tailBuffer{max: 4}
+	b.Write([]byte("abc"))
+	b.Write([]byte("defg"))
+	require.Equal(t, "defg", b.String())
+}
+
+// nextRestart waits for the module to schedule a restart, and triggers it,
+// returning the delay before the restart.
+func TestTailSplit(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", `printf 'a b\0'; sleep 0.075; printf 'c\nd\0'; sleep 0.075; printf 'e'`).
+		SplitOn(0)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"a b"})
+	testBar.NextOutput().AssertText([]string{"c\nd"}, "newlines are not delimiters")
+	testBar.NextOutput().AssertText([]string{"e"}, "trailing record without delimiter")
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", `printf 'a\nb\n\n'; sleep 0.075; printf 'c\n\n'`).
+		SplitFunc(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+				return i + 2, data[:i], nil
+			}
+			return 0, nil, nil
+		})
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"a\nb"}, "custom split function")
+	testBar.NextOutput().AssertText([]string{"c"})
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", "echo short; sleep 0.075; head -c 200 /dev/zero | tr '\\0' x; echo; echo after; sleep 60").
+		MaxRecordSize(100)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"short"})
+	errs := testBar.NextOutput().AssertError("when a record is too long")
+	require.Equal(t, []string{bufio.ErrTooLong.Error()}, errs,
+		"without waiting for the command to exit")
+
+	testBar.New(t)
+	tail = Tail("bash", "-c", "echo short; sleep 0.075; head -c 200 /dev/zero | tr '\\0' x; sleep 60").
+		MaxRecordSize(100).
+		RestartWithBackoff(time.Second, time.Minute)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"short"})
+	require.Equal(t, time.Second, nextRestart(t), "restarts after a record is too long")
+	testBar.NextOutput().AssertText([]string{"short"}, "on restart")
+}
+
+func nextRestart(t *testing.T) time.Duration {
+	start := timing.Now()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if now := timing.NextTick(); now.After(start) {
+			return now.Sub(start)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Fail(t, "Expected a restart to be scheduled")
+	return 0
+}
+
+func TestTailRestart(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "tail-restart")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tail := Tail("bash", "-c", `n=$(($(cat runs 2>/dev/null || echo 0) + 1))
+		echo $n > runs; echo "run $n"; [ $n -lt 3 ]`).
+		Dir(dir).
+		RestartWithBackoff(time.Second, 4*time.Second)
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"run 1"})
+	require.Equal(t, time.Second, nextRestart(t), "first restart after min")
+	testBar.NextOutput().AssertText([]string{"run 2"})
+	require.Equal(t, 2*time.Second, nextRestart(t), "backs off")
+	testBar.NextOutput().AssertText([]string{"run 3"}, "restarts after error")
+	require.Equal(t, 4*time.Second, nextRestart(t))
+	testBar.NextOutput().AssertText([]string{"run 4"})
+
+	tail.Output(func(in string) bar.Output { return outputs.Textf("> %s", in) })
+	testBar.NextOutput().AssertText([]string{"> run 4"},
+		"updates output while waiting to restart")
+	require.Equal(t, 4*time.Second, nextRestart(t), "capped at max")
+	testBar.NextOutput().AssertText([]string{"> run 5"})
+
+	testBar.New(t)
+	tail = Tail("this-is-not-a-valid-command").Restart()
+	testBar.Run(tail)
+	testBar.NextOutput().AssertError("when starting an invalid command")
+}
+
+func TestTailRestartResetsBackoff(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", "echo start; sleep 75").
+		Timeout(5*time.Second).
+		RestartWithBackoff(time.Second, 4*time.Second)
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"start"})
+	timing.AdvanceBy(5 * time.Second)
+	require.Equal(t, time.Second, nextRestart(t), "restarts after timeout")
+	testBar.NextOutput().AssertText([]string{"start"})
+	timing.AdvanceBy(5 * time.Second)
+	require.Equal(t, time.Second, nextRestart(t),
+		"backoff reset after running for at least max")
+	testBar.NextOutput().AssertText([]string{"start"})
+}
+
+func TestTailLines(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", "for i in `seq 1 5`; do echo $i; sleep 0.075; done; sleep 75").
+		Lines(3).
+		OutputMulti(func(lines []string) bar.Output {
+			g := outputs.Group()
+			for _, l := range lines {
+				g.Append(outputs.Text(l))
+			}
+			return g
+		})
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"1"})
+	testBar.NextOutput().AssertText([]string{"1", "2"})
+	testBar.NextOutput().AssertText([]string{"1", "2", "3"})
+	testBar.NextOutput().AssertText([]string{"2", "3", "4"}, "keeps last n lines")
+	testBar.NextOutput().AssertText([]string{"3", "4", "5"})
+
+	tail.Output(func(in string) bar.Output { return outputs.Textf("[%s]", in) })
+	testBar.NextOutput().AssertText([]string{"[5]"},
+		"single line output uses last line")
+}
+
+func TestTailSendSignal(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", `trap 'echo hup' HUP; echo ready
+		while true; do sleep 0.05; done`)
+	require.Equal(t, errNotRunning, tail.SendSignal(syscall.SIGHUP),
+		"before stream starts")
+	testBar.Run(tail)
+
+	testBar.NextOutput().AssertText([]string{"ready"})
+	require.NoError(t, tail.SendSignal(syscall.SIGHUP))
+	testBar.NextOutput().AssertText([]string{"hup"}, "on signal")
+
+	require.NoError(t, tail.SendSignal(syscall.SIGTERM))
+	testBar.NextOutput().AssertError("when command is terminated")
+	require.Equal(t, errNotRunning, tail.SendSignal(syscall.SIGHUP),
+		"after command exits")
+}
+
+// alive returns true if the process is running, i.e. it exists and is not
+// a zombie waiting to be reaped by a parent other than this process.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestTailContext(t *testing.T) {
+	tail := Tail("bash", "-c", "sleep 75 & echo $$ $!; wait")
+	ctx, cancel := context.WithCancel(context.Background())
+	outs, s := sink.Buffered(10)
+	returned := make(chan struct{})
+	go func() {
+		tail.StreamContext(ctx, s)
+		close(returned)
+	}()
+
+	var out bar.Output
+	select {
+	case out = <-outs:
+	case <-time.After(time.Second):
+		require.Fail(t, "no output from command")
+	}
+	txt, _ := out.Segments()[0].Content()
+	var shPid, childPid int
+	_, err := fmt.Sscan(txt, &shPid, &childPid)
+	require.NoError(t, err)
+	require.True(t, alive(shPid))
+	require.True(t, alive(childPid))
+
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		require.Fail(t, "StreamContext did not return on cancel")
+	}
+	require.Eventually(t, func() bool { return !alive(shPid) && !alive(childPid) },
+		time.Second, 10*time.Millisecond, "command and children are killed")
+	require.Equal(t, errNotRunning, tail.SendSignal(syscall.SIGUSR1),
+		"command is reaped on cancel")
+	require.Empty(t, outs, "no error on cancel")
+}
+
+func TestTailPipe(t *testing.T) {
+	testBar.New(t)
+	tail := TailPipe(
+		[]string{"bash", "-c", "for i in `seq 1 3`; do echo $i; sleep 0.075; done"},
+		[]string{"bash", "-c", "while read l; do echo x$l; done"},
+	)
+	testBar.Run(tail)
+	for _, i := range []string{"x1", "x2", "x3"} {
+		testBar.NextOutput().AssertText([]string{i})
+	}
+	testBar.NextOutput("sets restart click handler")
+
+	testBar.New(t)
+	tail = TailPipe(
+		[]string{"bash", "-c", "echo out; sleep 0.075; echo oops >&2; exit 3"},
+		[]string{"cat"},
+	)
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"out"})
+	err := testBar.NextOutput().At(0).Segment().GetError()
+	require.Equal(t, &CommandError{
+		Err:      err.(*CommandError).Err,
+		ExitCode: 3,
+		Stderr:   "oops",
+		Command:  "bash",
+		Stage:    0,
+	}, err, "when an earlier stage fails")
+
+	// All stages share a process group, so that they can be signalled and
+	// killed together.
+	tail = TailPipe(
+		[]string{"bash", "-c", "echo $$; sleep 75"},
+		[]string{"bash", "-c", "read p; echo $p $$; sleep 75"},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	outs, s := sink.Buffered(10)
+	returned := make(chan struct{})
+	go func() {
+		tail.StreamContext(ctx, s)
+		close(returned)
+	}()
+	var out bar.Output
+	select {
+	case out = <-outs:
+	case <-time.After(time.Second):
+		require.Fail(t, "no output from pipeline")
+	}
+	txt, _ := out.Segments()[0].Content()
+	var pid0, pid1 int
+	_, err = fmt.Sscan(txt, &pid0, &pid1)
+	require.NoError(t, err)
+	pgid0, _ := syscall.Getpgid(pid0)
+	pgid1, _ := syscall.Getpgid(pid1)
+	require.Equal(t, pid0, pgid0, "first stage leads the process group")
+	require.Equal(t, pid0, pgid1, "later stages join the process group")
+
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		require.Fail(t, "StreamContext did not return on cancel")
+	}
+	require.Eventually(t, func() bool { return !alive(pid0) && !alive(pid1) },
+		time.Second, 10*time.Millisecond, "all stages are killed")
+}
+
+func TestTailMissingCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "barista-shell")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+
+	testBar.New(t)
+	tail := Tail("barista-test-cmd")
+	testBar.Run(tail)
+	out := testBar.NextOutput("when command is missing")
+	errs := out.AssertError()
+	require.Equal(t, []string{`shell: "barista-test-cmd" not found in PATH`}, errs)
+	err = out.At(0).Segment().GetError()
+	require.True(t, errors.Is(err, exec.ErrNotFound))
+
+	script := filepath.Join(dir, "barista-test-cmd")
+	require.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\necho ok\n"), 0644))
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on retry, with non-executable file")
+	errs = out.AssertError()
+	require.Equal(t, []string{`shell: "barista-test-cmd" is not executable (permission denied)`}, errs)
+	require.True(t, errors.Is(out.At(0).Segment().GetError(), os.ErrPermission))
+
+	require.NoError(t, os.Chmod(script, 0755))
+	out.At(0).LeftClick()
+	testBar.NextOutput("on retry, once fixed").AssertText([]string{"ok"})
+
+	testBar.New(t)
+	tail = Tail("./does/not/exist")
+	testBar.Run(tail)
+	errs = testBar.NextOutput().AssertError()
+	require.Equal(t, []string{`shell: "./does/not/exist" not found`}, errs)
+}
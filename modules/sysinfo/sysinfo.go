@@ -75,7 +75,7 @@ func RefreshInterval(interval time.Duration) {
 
 // Module represents a bar.Module that displays memory information.
 type Module struct {
-	outputFunc value.Value
+	outputFunc value.OutputFunc[Info]
 }
 
 func defaultOutput(i Info) bar.Output {
@@ -100,7 +100,7 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 // Stream subscribes to sysinfo and updates the module's output.
 func (m *Module) Stream(s bar.Sink) {
 	i, err := currentInfo.Get()
-	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		nextInfo := currentInfo.Next()
@@ -112,7 +112,7 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		case <-nextInfo:
 			i, err = currentInfo.Get()
 		}
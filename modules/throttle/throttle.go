@@ -0,0 +1,151 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package throttle provides an i3bar module that shows when the CPU is being
+// thermally throttled, using the thermal_throttle counters exposed by the
+// kernel for Intel CPUs.
+package throttle // import "barista.run/modules/throttle"
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+)
+
+// Info represents the thermal throttling state of the CPU.
+type Info struct {
+	// Available is false if the CPU or kernel does not report throttling.
+	Available bool
+	// Throttling is true if the CPU was throttled since the last refresh.
+	Throttling bool
+	// Recent is the number of throttling events since the last refresh.
+	Recent int64
+	// Count is the number of throttling events since the module started.
+	Count int64
+	// CoreEvents and PackageEvents are the number of core and package
+	// throttling events since boot, summed over all cores and packages.
+	CoreEvents    int64
+	PackageEvents int64
+}
+
+// Module represents a CPU throttling bar module.
+type Module struct {
+	scheduler  timing.Scheduler
+	outputFunc value.OutputFunc[Info]
+}
+
+// New constructs a CPU throttling module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(5 * time.Second)
+	// Default output is a warning while throttling, and nothing otherwise.
+	m.Output(func(i Info) bar.Output {
+		if !i.Throttling {
+			return nil
+		}
+		return outputs.WithSeverity(outputs.Text("throttled"), outputs.Warning)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for throttling counters.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get()
+	nextOutputFunc := m.outputFunc.Next()
+	info := read()
+	start := info.CoreEvents + info.PackageEvents
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get()
+		case <-m.scheduler.Tick():
+			prev := info.CoreEvents + info.PackageEvents
+			info = read()
+			total := info.CoreEvents + info.PackageEvents
+			if total < prev {
+				// A CPU went offline, taking its counters with it.
+				start -= prev - total
+				prev = total
+			}
+			info.Recent = total - prev
+			info.Throttling = info.Recent > 0
+			info.Count = total - start
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+const cpuPath = "/sys/devices/system/cpu"
+
+// read sums the throttle counters of all CPUs. Package counters are reported
+// by every CPU in the package, so they are only counted once per package.
+func read() Info {
+	info := Info{}
+	dirs, _ := afero.Glob(fs, filepath.Join(cpuPath, "cpu[0-9]*", "thermal_throttle"))
+	packages := map[string]bool{}
+	for _, dir := range dirs {
+		core, err := readInt(filepath.Join(dir, "core_throttle_count"))
+		if err != nil {
+			continue
+		}
+		info.Available = true
+		info.CoreEvents += core
+		pkgID := "0"
+		if id, err := afero.ReadFile(fs,
+			filepath.Join(filepath.Dir(dir), "topology", "physical_package_id")); err == nil {
+			pkgID = strings.TrimSpace(string(id))
+		}
+		if packages[pkgID] {
+			continue
+		}
+		if pkg, err := readInt(filepath.Join(dir, "package_throttle_count")); err == nil {
+			packages[pkgID] = true
+			info.PackageEvents += pkg
+		}
+	}
+	return info
+}
+
+func readInt(path string) (int64, error) {
+	bytes, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCPU(cpu, pkg int, core, pkgCount int64) {
+	dir := fmt.Sprintf("/sys/devices/system/cpu/cpu%d", cpu)
+	afero.WriteFile(fs, dir+"/topology/physical_package_id",
+		[]byte(fmt.Sprintf("%d\n", pkg)), 0644)
+	afero.WriteFile(fs, dir+"/thermal_throttle/core_throttle_count",
+		[]byte(fmt.Sprintf("%d\n", core)), 0644)
+	afero.WriteFile(fs, dir+"/thermal_throttle/package_throttle_count",
+		[]byte(fmt.Sprintf("%d\n", pkgCount)), 0644)
+}
+
+func TestThrottle(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	writeCPU(0, 0, 3, 10)
+	writeCPU(1, 0, 4, 10)
+	writeCPU(2, 1, 0, 1)
+	afero.WriteFile(fs, "/sys/devices/system/cpu/cpufreq/policy0", nil, 0644)
+
+	m := New().RefreshInterval(time.Second)
+	testBar.Run(m)
+	testBar.NextOutput().AssertEmpty("not throttling on start")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertEmpty("no new events")
+
+	writeCPU(1, 0, 6, 11)
+	writeCPU(0, 0, 3, 11)
+	testBar.Tick()
+	out := testBar.NextOutput("throttling")
+	out.AssertText([]string{"throttled"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent, "throttling is a warning")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %v %d/%d %d+%d", i.Available, i.Throttling,
+			i.Recent, i.Count, i.CoreEvents, i.PackageEvents)
+	})
+	testBar.NextOutput().AssertText([]string{"true true 3/3 9+12"}, "on output change")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"true false 0/3 9+12"}, "throttling stopped")
+
+	writeCPU(2, 1, 1, 2)
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"true true 2/5 10+13"}, "throttling again")
+}
+
+func TestUnavailable(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	afero.WriteFile(fs, "/sys/devices/system/cpu/cpu0/topology/physical_package_id",
+		[]byte("0\n"), 0644)
+
+	m := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %v", i.Available, i.Throttling)
+	})
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"false false"}, "on start")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"false false"}, "on tick")
+}
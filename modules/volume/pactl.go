@@ -0,0 +1,223 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package volume
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"barista.run/base/value"
+	l "barista.run/logging"
+)
+
+// pactlNorm is the raw volume for 100%, PA_VOLUME_NORM.
+const pactlNorm = 65536
+
+// pactl implementation, which controls sinks using the pactl command. Unlike
+// the D-Bus implementation, this also works with PipeWire's pulse
+// compatibility layer (pipewire-pulse).
+type pactlModule struct {
+	sinkName string // empty to follow the default sink.
+
+	// run runs pactl with the given arguments and returns its output, and
+	// subscribe starts `pactl subscribe` and returns its output. Both are
+	// replaced in tests.
+	run       func(args ...string) ([]byte, error)
+	subscribe func() (io.ReadCloser, error)
+
+	// The sink being controlled, which changes with the default sink if
+	// following it. Protected by mu, since it is also used by the
+	// click handler.
+	sink string
+	mu   sync.Mutex
+}
+
+// PipeWireSink creates a volume module for a named sink, controlled using
+// pactl. This works with both PipeWire (through pipewire-pulse) and
+// PulseAudio, and requires pactl from PulseAudio 15 or later.
+func PipeWireSink(sinkName string) *Module {
+	m := createModule(&pactlModule{
+		sinkName:  sinkName,
+		run:       runPactl,
+		subscribe: subscribePactl,
+	})
+	if sinkName == "" {
+		sinkName = "default"
+	}
+	l.Labelf(m, "pactl:%s", sinkName)
+	return m
+}
+
+// PipeWireDefaultSink creates a volume module that follows the default sink,
+// controlled using pactl. When the default sink changes, for example when
+// switching outputs, the module switches to the new sink immediately.
+func PipeWireDefaultSink() *Module {
+	return PipeWireSink("")
+}
+
+func runPactl(args ...string) ([]byte, error) {
+	return exec.Command("pactl", args...).Output()
+}
+
+// pactlEvents is the output of `pactl subscribe`, and kills it on Close.
+type pactlEvents struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (p pactlEvents) Close() error {
+	p.cmd.Process.Kill()
+	p.ReadCloser.Close()
+	return p.cmd.Wait()
+}
+
+func subscribePactl() (io.ReadCloser, error) {
+	cmd := exec.Command("pactl", "subscribe")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return pactlEvents{out, cmd}, nil
+}
+
+func (m *pactlModule) currentSink() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sink == "" {
+		return "", fmt.Errorf("Sink not ready")
+	}
+	return m.sink, nil
+}
+
+func (m *pactlModule) setVolume(newVol int64) error {
+	sink, err := m.currentSink()
+	if err != nil {
+		return err
+	}
+	_, err = m.run("set-sink-volume", sink, strconv.FormatInt(newVol, 10))
+	return err
+}
+
+func (m *pactlModule) setMuted(muted bool) error {
+	sink, err := m.currentSink()
+	if err != nil {
+		return err
+	}
+	mute := "0"
+	if muted {
+		mute = "1"
+	}
+	_, err = m.run("set-sink-mute", sink, mute)
+	return err
+}
+
+// parsePactlVolume returns the average raw volume across all channels from the
+// output of `pactl get-sink-volume`, e.g.
+//
+//	Volume: front-left: 32768 /  50% / -18.06 dB,   front-right: ...
+func parsePactlVolume(out string) (int64, error) {
+	var total, channels int64
+	for _, ch := range strings.Split(out, ",") {
+		i := strings.LastIndex(ch, ": ")
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(ch[i+2:])
+		if len(fields) == 0 {
+			continue
+		}
+		vol, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		total += vol
+		channels++
+	}
+	if channels == 0 {
+		return 0, fmt.Errorf("pactl: unexpected volume %q", out)
+	}
+	return total / channels, nil
+}
+
+// update reads the default sink, switching to it if following the default,
+// and then reads the volume of the controlled sink.
+func (m *pactlModule) update(s *value.ErrorValue) {
+	out, err := m.run("get-default-sink")
+	if s.Error(err) {
+		return
+	}
+	def := strings.TrimSpace(string(out))
+	sink := m.sinkName
+	if sink == "" {
+		sink = def
+	}
+	m.mu.Lock()
+	if m.sink != sink {
+		l.Fine("%s: now controlling %s", l.ID(m), sink)
+	}
+	m.sink = sink
+	m.mu.Unlock()
+
+	v := Volume{Min: 0, Max: pactlNorm, Name: sink, Default: sink == def}
+	out, err = m.run("get-sink-volume", sink)
+	if s.Error(err) {
+		return
+	}
+	if v.Vol, err = parsePactlVolume(string(out)); s.Error(err) {
+		return
+	}
+	out, err = m.run("get-sink-mute", sink)
+	if s.Error(err) {
+		return
+	}
+	v.Mute = strings.TrimSpace(string(out)) == "Mute: yes"
+	s.Set(v)
+}
+
+// isSinkOrServerEvent returns true for `pactl subscribe` events that may
+// change the volume or the default sink, e.g. "Event 'change' on sink #55",
+// or "Event 'change' on server #-1" when the default sink changes.
+func isSinkOrServerEvent(event string) bool {
+	return strings.Contains(event, " on sink #") ||
+		strings.Contains(event, " on server #")
+}
+
+func (m *pactlModule) worker(s *value.ErrorValue) {
+	events, err := m.subscribe()
+	if s.Error(err) {
+		return
+	}
+	defer events.Close()
+	m.update(s)
+	scanner := bufio.NewScanner(events)
+	for scanner.Scan() {
+		if isSinkOrServerEvent(scanner.Text()) {
+			m.update(s)
+		}
+	}
+	err = scanner.Err()
+	if err == nil {
+		err = fmt.Errorf("pactl subscribe exited")
+	}
+	s.Error(err)
+}
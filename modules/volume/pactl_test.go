@@ -0,0 +1,184 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package volume
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/base/value"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakePactl serves pactl output for a set of sinks, and records the commands
+// that change them.
+type fakePactl struct {
+	sync.Mutex
+	def     string
+	volumes map[string]string
+	muted   map[string]bool
+	set     []string
+	events  *io.PipeWriter
+}
+
+func newFakePactl() *fakePactl {
+	return &fakePactl{
+		def: "speakers",
+		volumes: map[string]string{
+			"speakers": "Volume: front-left: 26214 /  40% / -23.88 dB,   front-right: 26214 /  40% / -23.88 dB\n        balance 0.00\n",
+			"headset":  "Volume: mono: 45875 /  70% / -9.29 dB\n        balance 0.00\n",
+		},
+		muted: map[string]bool{"headset": true},
+	}
+}
+
+func (f *fakePactl) run(args ...string) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	switch args[0] {
+	case "get-default-sink":
+		return []byte(f.def + "\n"), nil
+	case "get-sink-volume":
+		if vol, ok := f.volumes[args[1]]; ok {
+			return []byte(vol), nil
+		}
+	case "get-sink-mute":
+		if _, ok := f.volumes[args[1]]; ok {
+			if f.muted[args[1]] {
+				return []byte("Mute: yes\n"), nil
+			}
+			return []byte("Mute: no\n"), nil
+		}
+	case "set-sink-volume", "set-sink-mute":
+		f.set = append(f.set, strings.Join(args, " "))
+		return nil, nil
+	}
+	return nil, fmt.Errorf("pactl %v failed", args)
+}
+
+func (f *fakePactl) subscribe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	f.events = w
+	return r, nil
+}
+
+func (f *fakePactl) module(sinkName string) *pactlModule {
+	return &pactlModule{sinkName: sinkName, run: f.run, subscribe: f.subscribe}
+}
+
+func nextVolume(t *testing.T, s *value.ErrorValue, next <-chan struct{}) Volume {
+	select {
+	case <-next:
+	case <-time.After(time.Second):
+		require.Fail(t, "no volume update")
+	}
+	v, err := s.Get()
+	require.NoError(t, err)
+	return v.(Volume)
+}
+
+func TestPactlFollowsDefaultSink(t *testing.T) {
+	f := newFakePactl()
+	m := f.module("")
+	var s value.ErrorValue
+	next := s.Next()
+	go m.worker(&s)
+
+	vol := nextVolume(t, &s, next)
+	require.Equal(t, "speakers", vol.Name)
+	require.True(t, vol.Default)
+	require.Equal(t, 40, vol.Pct())
+	require.False(t, vol.Mute)
+
+	require.NoError(t, m.setVolume(32768))
+	require.NoError(t, m.setMuted(true))
+	require.Equal(t, []string{
+		"set-sink-volume speakers 32768",
+		"set-sink-mute speakers 1",
+	}, f.set)
+
+	f.Lock()
+	f.def = "headset"
+	f.set = nil
+	f.Unlock()
+	next = s.Next()
+	fmt.Fprintln(f.events, "Event 'new' on sink-input #12")
+	fmt.Fprintln(f.events, "Event 'change' on server #-1")
+	vol = nextVolume(t, &s, next)
+	require.Equal(t, "headset", vol.Name, "switches to the new default sink")
+	require.True(t, vol.Default)
+	require.Equal(t, 70, vol.Pct())
+	require.True(t, vol.Mute)
+
+	require.NoError(t, m.setMuted(false))
+	require.Equal(t, []string{"set-sink-mute headset 0"}, f.set,
+		"controls the new default sink")
+
+	next = s.Next()
+	f.events.Close()
+	select {
+	case <-next:
+	case <-time.After(time.Second):
+		require.Fail(t, "no error when pactl exits")
+	}
+	_, err := s.Get()
+	require.Error(t, err)
+}
+
+func TestPactlNamedSink(t *testing.T) {
+	f := newFakePactl()
+	m := f.module("headset")
+	var s value.ErrorValue
+	next := s.Next()
+	go m.worker(&s)
+	defer func() { f.events.Close() }()
+
+	vol := nextVolume(t, &s, next)
+	require.Equal(t, "headset", vol.Name)
+	require.False(t, vol.Default)
+
+	f.Lock()
+	f.def = "headset"
+	f.volumes["headset"] = "Volume: mono: 65536 / 100% / 0.00 dB\n"
+	f.Unlock()
+	next = s.Next()
+	fmt.Fprintln(f.events, "Event 'change' on server #-1")
+	vol = nextVolume(t, &s, next)
+	require.True(t, vol.Default, "named sink is now the default")
+	require.Equal(t, 100, vol.Pct())
+
+	f.Lock()
+	f.def = "speakers"
+	f.Unlock()
+	next = s.Next()
+	fmt.Fprintln(f.events, "Event 'change' on sink #3")
+	vol = nextVolume(t, &s, next)
+	require.Equal(t, "headset", vol.Name, "named sink does not follow default")
+	require.False(t, vol.Default)
+}
+
+func TestParsePactlVolume(t *testing.T) {
+	vol, err := parsePactlVolume("Volume: front-left: 1000 /   2% / -100.00 dB,   front-right: 3000 /   5% / -75.00 dB\n        balance 0.50\n")
+	require.NoError(t, err)
+	require.Equal(t, int64(2000), vol)
+
+	_, err = parsePactlVolume("Failed to get sink volume: No such entity")
+	require.Error(t, err)
+}
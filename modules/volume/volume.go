@@ -12,8 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package volume provides an i3bar module that interfaces with alsa
-// to display and control the system volume.
+// Package volume provides an i3bar module that interfaces with alsa,
+// PulseAudio, or PipeWire (via pactl) to display and control the system volume.
 package volume // import "barista.run/modules/volume"
 
 /*
@@ -43,6 +43,12 @@ type Volume struct {
 	Controller
 	Min, Max, Vol int64
 	Mute          bool
+	// Name is the name of the device being controlled: the sink name
+	// for PulseAudio and PipeWire, or the mixer name for ALSA.
+	Name string
+	// Default is true if the device being controlled is currently the
+	// default (fallback) sink. It is always false for ALSA mixers.
+	Default bool
 }
 
 // Frac returns the current volume as a fraction of the total range.
@@ -77,7 +83,7 @@ type moduleImpl interface {
 
 // Module represents a bar.Module that displays volume information.
 type Module struct {
-	outputFunc    value.Value      // of func(Volume) bar.Output
+	outputFunc    value.OutputFunc[Volume]
 	clickHandler  value.Value      // of func(Volume, Controller, bar.Event)
 	currentVolume value.ErrorValue // of Volume
 	impl          moduleImpl
@@ -122,7 +128,7 @@ func defaultClickHandler(v Volume) func(bar.Event) {
 func (m *Module) Stream(s bar.Sink) {
 	go m.impl.worker(&m.currentVolume)
 	v, err := m.currentVolume.Get()
-	outputFunc := m.outputFunc.Get().(func(Volume) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		if s.Error(err) {
@@ -138,7 +144,7 @@ func (m *Module) Stream(s bar.Sink) {
 			v, err = m.currentVolume.Get()
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Volume) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 	}
 }
@@ -308,6 +314,7 @@ func (m *alsaModule) worker(s *value.ErrorValue) {
 			Max:  int64(max),
 			Vol:  int64(vol),
 			Mute: (int(mute) == 0),
+			Name: m.mixerName,
 		})
 		if err(C.snd_mixer_wait(handle, -1), "snd_mixer_wait") {
 			return
@@ -320,10 +327,14 @@ func (m *alsaModule) worker(s *value.ErrorValue) {
 
 // PulseAudio implementation.
 type paModule struct {
-	conn     *dbus.Conn
 	core     dbus.BusObject
 	sink     dbus.BusObject
 	sinkName string
+	// The object path of the current fallback (default) sink, used to
+	// determine whether the controlled sink is the system default.
+	fallback dbus.ObjectPath
+	// object returns the D-Bus object for a sink, replaced in tests.
+	object func(dest string, path dbus.ObjectPath) dbus.BusObject
 }
 
 func dialAndAuth(addr string) (*dbus.Conn, error) {
@@ -382,6 +393,10 @@ func Sink(sinkName string) *Module {
 }
 
 // DefaultSink creates a PulseAudio volume module that follows the default sink.
+// It switches to the new sink as soon as PulseAudio reports a change of the
+// fallback sink. This uses PulseAudio's D-Bus interface, which PipeWire's
+// pulse compatibility layer does not provide; use PipeWireDefaultSink with
+// PipeWire.
 func DefaultSink() *Module {
 	return Sink("")
 }
@@ -411,8 +426,23 @@ func (m *paModule) listen(signal string, objects ...dbus.ObjectPath) error {
 	return call.Err
 }
 
+func (m *paModule) stopListening(signal string) error {
+	call := m.core.Call("org.PulseAudio.Core1.StopListeningForSignal", 0, "org.PulseAudio.Core1."+signal)
+	return call.Err
+}
+
 func (m *paModule) openSink(sink dbus.ObjectPath) error {
-	m.sink = m.conn.Object("org.PulseAudio.Core1.Sink", sink)
+	if m.sink != nil {
+		// Stop listening to the previous sink, otherwise changes to the
+		// old device would continue to trigger updates.
+		if err := m.stopListening("Device.VolumeUpdated"); err != nil {
+			return err
+		}
+		if err := m.stopListening("Device.MuteUpdated"); err != nil {
+			return err
+		}
+	}
+	m.sink = m.object("org.PulseAudio.Core1.Sink", sink)
 	if err := m.listen("Device.VolumeUpdated", sink); err != nil {
 		return err
 	}
@@ -428,12 +458,49 @@ func (m *paModule) openSinkByName(name string) error {
 	return m.openSink(path)
 }
 
-func (m *paModule) openFallbackSink() error {
+func (m *paModule) updateFallbackSink() error {
 	path, err := m.core.GetProperty("org.PulseAudio.Core1.FallbackSink")
 	if err != nil {
 		return err
 	}
-	return m.openSink(path.Value().(dbus.ObjectPath))
+	m.fallback = path.Value().(dbus.ObjectPath)
+	return nil
+}
+
+func (m *paModule) openFallbackSink() error {
+	if err := m.updateFallbackSink(); err != nil {
+		return err
+	}
+	return m.openSink(m.fallback)
+}
+
+// handleFallbackSignal updates the fallback sink on a FallbackSinkUpdated or
+// FallbackSinkUnset signal. If following the default sink, the new fallback
+// sink is opened immediately, using the path from the signal rather than a
+// separate (and potentially stale) property read.
+func (m *paModule) handleFallbackSignal(signal *dbus.Signal) error {
+	switch signal.Name {
+	case "org.PulseAudio.Core1.FallbackSinkUpdated":
+		var path dbus.ObjectPath
+		ok := false
+		if len(signal.Body) > 0 {
+			path, ok = signal.Body[0].(dbus.ObjectPath)
+		}
+		if ok {
+			m.fallback = path
+		} else if err := m.updateFallbackSink(); err != nil {
+			return err
+		}
+	case "org.PulseAudio.Core1.FallbackSinkUnset":
+		m.fallback = ""
+		return nil
+	default:
+		return nil
+	}
+	if m.sinkName == "" && m.fallback != m.sink.Path() {
+		return m.openSink(m.fallback)
+	}
+	return nil
 }
 
 func (m *paModule) updateVolume(s *value.ErrorValue) {
@@ -464,6 +531,13 @@ func (m *paModule) updateVolume(s *value.ErrorValue) {
 		return
 	}
 	v.Mute = mute.Value().(bool)
+
+	name, err := m.sink.GetProperty("org.PulseAudio.Core1.Device.Name")
+	if s.Error(err) {
+		return
+	}
+	v.Name = name.Value().(string)
+	v.Default = m.sink.Path() == m.fallback
 	s.Set(v)
 }
 
@@ -472,16 +546,18 @@ func (m *paModule) worker(s *value.ErrorValue) {
 	if s.Error(err) {
 		return
 	}
-	m.conn = conn
-	defer func() {
-		conn.Close()
-		m.conn = nil
-	}()
+	m.object = func(dest string, path dbus.ObjectPath) dbus.BusObject {
+		return conn.Object(dest, path)
+	}
+	defer conn.Close()
 
 	m.core = conn.Object("org.PulseAudio.Core1", "/org/pulseaudio/core1")
 	defer func() { m.core = nil }()
 
 	if m.sinkName != "" {
+		if s.Error(m.updateFallbackSink()) {
+			return
+		}
 		if s.Error(m.openSinkByName(m.sinkName)) {
 			return
 		}
@@ -489,11 +565,16 @@ func (m *paModule) worker(s *value.ErrorValue) {
 		if s.Error(m.openFallbackSink()) {
 			return
 		}
-		if s.Error(m.listen("FallbackSinkUpdated")) {
-			return
-		}
 	}
 	defer func() { m.sink = nil }()
+	// Always listen for fallback changes, even for named sinks, so that
+	// Volume.Default stays accurate.
+	if s.Error(m.listen("FallbackSinkUpdated")) {
+		return
+	}
+	if s.Error(m.listen("FallbackSinkUnset")) {
+		return
+	}
 
 	m.updateVolume(s)
 
@@ -503,10 +584,8 @@ func (m *paModule) worker(s *value.ErrorValue) {
 	// Listen for signals from D-Bus, and update appropriately.
 	for signal := range signals {
 		// If the fallback sink changed, open the new one.
-		if m.sinkName == "" && signal.Path == m.core.Path() {
-			if s.Error(m.openFallbackSink()) {
-				return
-			}
+		if s.Error(m.handleFallbackSignal(signal)) {
+			return
 		}
 		m.updateVolume(s)
 	}
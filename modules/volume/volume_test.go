@@ -14,4 +14,163 @@
 
 package volume
 
-// Empty test file for more accurate code coverage.
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"barista.run/base/value"
+
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObject is a D-Bus object that serves properties from a map, and
+// records the methods called on it.
+type fakeObject struct {
+	path  dbus.ObjectPath
+	props map[string]interface{}
+	sinks map[string]dbus.ObjectPath
+	calls []string
+}
+
+func (f *fakeObject) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	method = strings.TrimPrefix(method, "org.PulseAudio.Core1.")
+	f.calls = append(f.calls, fmt.Sprintf("%s%v", method, args))
+	c := &dbus.Call{Method: method, Args: args}
+	if method == "GetSinkByName" {
+		c.Body = []interface{}{f.sinks[args[0].(string)]}
+	}
+	return c
+}
+
+func (f *fakeObject) Go(method string, flags dbus.Flags, ch chan *dbus.Call, args ...interface{}) *dbus.Call {
+	return f.Call(method, flags, args...)
+}
+
+func (f *fakeObject) GetProperty(p string) (dbus.Variant, error) {
+	v, ok := f.props[p]
+	if !ok {
+		return dbus.Variant{}, errors.New("no such property")
+	}
+	return dbus.MakeVariant(v), nil
+}
+
+func (f *fakeObject) Destination() string   { return "" }
+func (f *fakeObject) Path() dbus.ObjectPath { return f.path }
+
+func fakeSink(name string, vol uint32) *fakeObject {
+	return &fakeObject{
+		path: dbus.ObjectPath("/org/pulseaudio/core1/" + name),
+		props: map[string]interface{}{
+			"org.PulseAudio.Core1.Device.BaseVolume": uint32(100),
+			"org.PulseAudio.Core1.Device.Volume":     []uint32{vol, vol},
+			"org.PulseAudio.Core1.Device.Mute":       false,
+			"org.PulseAudio.Core1.Device.Name":       name,
+		},
+	}
+}
+
+// fakePulse returns a PulseAudio module connected to a fake core with two
+// sinks, where the fallback sink is "speakers".
+func fakePulse(sinkName string) (*paModule, *fakeObject) {
+	speakers, headset := fakeSink("speakers", 40), fakeSink("headset", 70)
+	core := &fakeObject{
+		props: map[string]interface{}{
+			"org.PulseAudio.Core1.FallbackSink": speakers.path,
+		},
+		sinks: map[string]dbus.ObjectPath{
+			"speakers": speakers.path,
+			"headset":  headset.path,
+		},
+	}
+	m := &paModule{core: core, sinkName: sinkName}
+	m.object = func(dest string, path dbus.ObjectPath) dbus.BusObject {
+		for _, s := range []*fakeObject{speakers, headset} {
+			if s.path == path {
+				return s
+			}
+		}
+		return &fakeObject{path: path}
+	}
+	return m, core
+}
+
+func fallbackUpdated(sink string) *dbus.Signal {
+	return &dbus.Signal{
+		Name: "org.PulseAudio.Core1.FallbackSinkUpdated",
+		Body: []interface{}{dbus.ObjectPath("/org/pulseaudio/core1/" + sink)},
+	}
+}
+
+func readVolume(t *testing.T, m *paModule) Volume {
+	var s value.ErrorValue
+	m.updateVolume(&s)
+	v, err := s.Get()
+	require.NoError(t, err)
+	return v.(Volume)
+}
+
+func TestFollowsFallbackSink(t *testing.T) {
+	m, core := fakePulse("")
+	require.NoError(t, m.openFallbackSink())
+	vol := readVolume(t, m)
+	require.Equal(t, "speakers", vol.Name)
+	require.True(t, vol.Default)
+	require.Equal(t, 40, vol.Pct())
+
+	core.calls = nil
+	require.NoError(t, m.handleFallbackSignal(fallbackUpdated("headset")))
+	require.Equal(t, []string{
+		"StopListeningForSignal[org.PulseAudio.Core1.Device.VolumeUpdated]",
+		"StopListeningForSignal[org.PulseAudio.Core1.Device.MuteUpdated]",
+		"ListenForSignal[org.PulseAudio.Core1.Device.VolumeUpdated [/org/pulseaudio/core1/headset]]",
+		"ListenForSignal[org.PulseAudio.Core1.Device.MuteUpdated [/org/pulseaudio/core1/headset]]",
+	}, core.calls, "switches listeners to the new default sink")
+	vol = readVolume(t, m)
+	require.Equal(t, "headset", vol.Name)
+	require.True(t, vol.Default)
+	require.Equal(t, 70, vol.Pct())
+
+	core.calls = nil
+	require.NoError(t, m.handleFallbackSignal(fallbackUpdated("headset")))
+	require.Empty(t, core.calls, "same sink is not reopened")
+
+	require.NoError(t, m.handleFallbackSignal(&dbus.Signal{
+		Name: "org.PulseAudio.Core1.FallbackSinkUnset",
+	}))
+	vol = readVolume(t, m)
+	require.Equal(t, "headset", vol.Name, "keeps sink when fallback is unset")
+	require.False(t, vol.Default)
+
+	require.NoError(t, m.handleFallbackSignal(&dbus.Signal{
+		Name: "org.PulseAudio.Core1.FallbackSinkUpdated",
+	}))
+	require.Equal(t, "speakers", readVolume(t, m).Name,
+		"reads fallback sink if not included in signal")
+}
+
+func TestNamedSink(t *testing.T) {
+	m, core := fakePulse("headset")
+	require.NoError(t, m.updateFallbackSink())
+	require.NoError(t, m.openSinkByName("headset"))
+	vol := readVolume(t, m)
+	require.Equal(t, "headset", vol.Name)
+	require.False(t, vol.Default)
+
+	core.calls = nil
+	require.NoError(t, m.handleFallbackSignal(fallbackUpdated("headset")))
+	require.Empty(t, core.calls, "named sink is not reopened")
+	require.True(t, readVolume(t, m).Default)
+
+	require.NoError(t, m.handleFallbackSignal(fallbackUpdated("speakers")))
+	vol = readVolume(t, m)
+	require.Equal(t, "headset", vol.Name, "named sink does not follow fallback")
+	require.False(t, vol.Default)
+
+	require.NoError(t, m.handleFallbackSignal(&dbus.Signal{
+		Name: "org.PulseAudio.Core1.Device.VolumeUpdated",
+	}))
+	require.Empty(t, core.calls, "other signals do not affect the sink")
+}
@@ -46,7 +46,7 @@ const (
 // Module represents a VPN bar module.
 type Module struct {
 	intf       string
-	outputFunc value.Value // of func(State) bar.Output
+	outputFunc value.OutputFunc[State]
 }
 
 // New constructs an instance of the VPN module for the specified interface.
@@ -79,7 +79,7 @@ func (m *Module) Output(outputFunc func(State) bar.Output) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	state := Disconnected
-	outputFunc := m.outputFunc.Get().(func(State) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	linkCh := netlink.ByName(m.intf)
 	defer linkCh.Unsubscribe()
@@ -97,7 +97,7 @@ func (m *Module) Stream(s bar.Sink) {
 			}
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(State) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 		s.Output(outputFunc(state))
 	}
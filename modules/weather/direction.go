@@ -14,37 +14,52 @@
 
 package weather
 
+import "math"
+
 // Deg returns the direction in meteorological degrees.
 func (d Direction) Deg() int {
 	return int(d)
 }
 
-// Cardinal returns the cardinal direction.
+// Cardinal returns the cardinal direction, as the nearest point of the
+// 16-point compass rose, e.g. "NNW".
 func (d Direction) Cardinal() string {
-	cardinal := ""
-	deg := d.Deg()
-	m := 34 // rounded from (90/4 + 90/8)
-	// primary cardinal direction first. N, E, S, W.
-	switch {
-	case deg < m || deg > 360-m:
-		cardinal = "N"
-	case 90-m < deg && deg < 90+m:
-		cardinal = "E"
-	case 180-m < deg && deg < 180+m:
-		cardinal = "S"
-	case 270-m < deg && deg < 270+m:
-		cardinal = "W"
-	}
-	// Now append the midway points. NE, NW, SE, SW.
-	switch {
-	case 45-m < deg && deg < 45+m:
-		cardinal += "NE"
-	case 135-m < deg && deg < 135+m:
-		cardinal += "SE"
-	case 225-m < deg && deg < 225+m:
-		cardinal += "SW"
-	case 315-m < deg && deg < 315+m:
-		cardinal += "NW"
+	return Compass(float64(d))
+}
+
+var compassPoints = [...]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+var arrows = [...]string{"↑", "↗", "→", "↘", "↓", "↙", "←", "↖"}
+
+// sector returns the index of the sector containing the given direction,
+// when the compass is divided into n equal sectors centred on north.
+func sector(degrees float64, n int) int {
+	width := 360 / float64(n)
+	deg := math.Mod(degrees, 360)
+	if deg < 0 {
+		deg += 360
 	}
-	return cardinal
+	return int(math.Floor(deg/width+0.5)) % n
+}
+
+// Compass returns the nearest point of the 16-point compass rose for the
+// given direction in degrees, e.g. "NNW".
+func Compass(degrees float64) string {
+	return compassPoints[sector(degrees, len(compassPoints))]
+}
+
+// WindArrow returns the arrow nearest to the given direction in degrees, with
+// the arrow pointing in the direction, e.g. "↑" for north. Wind direction is
+// conventionally where the wind is blowing from, so to show where the wind is
+// blowing towards, add 180 degrees.
+func WindArrow(degrees float64) string {
+	return arrows[sector(degrees, len(arrows))]
+}
+
+// Arrow returns the arrow nearest to the direction.
+func (d Direction) Arrow() string {
+	return WindArrow(float64(d))
 }
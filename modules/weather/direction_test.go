@@ -53,3 +53,36 @@ func TestDirection(t *testing.T) {
 		require.Equal(t, c.deg, dir.Deg())
 	}
 }
+
+func TestCompass(t *testing.T) {
+	for i, point := range []string{
+		"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+		"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+	} {
+		centre := float64(i) * 22.5
+		for _, deg := range []float64{centre - 11.2, centre, centre + 11.2} {
+			require.Equal(t, point, Compass(deg), "compass for %v", deg)
+		}
+	}
+	require.Equal(t, "NNE", Compass(11.25), "boundaries round up")
+	require.Equal(t, "N", Compass(360), "full circle")
+	require.Equal(t, "NNW", Compass(-20), "negative degrees")
+	require.Equal(t, "E", Compass(450), "more than a full circle")
+
+	for deg := 0; deg < 360; deg++ {
+		require.Equal(t, Direction(deg).Cardinal(), Compass(float64(deg)),
+			"compass agrees with cardinal for %d", deg)
+	}
+}
+
+func TestWindArrow(t *testing.T) {
+	for i, arrow := range []string{"↑", "↗", "→", "↘", "↓", "↙", "←", "↖"} {
+		centre := float64(i) * 45
+		for _, deg := range []float64{centre - 22.4, centre, centre + 22.4} {
+			require.Equal(t, arrow, WindArrow(deg), "arrow for %v", deg)
+		}
+	}
+	require.Equal(t, "↗", WindArrow(22.5), "boundaries round up")
+	require.Equal(t, "↖", WindArrow(-45), "negative degrees")
+	require.Equal(t, "↓", Direction(190).Arrow())
+}
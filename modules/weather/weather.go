@@ -91,7 +91,7 @@ type Provider interface {
 type Module struct {
 	provider       Provider
 	scheduler      timing.Scheduler
-	outputFunc     value.Value // of func(Weather) bar.Output
+	outputFunc     value.OutputFunc[Weather]
 	currentWeather value.Value // of Weather
 }
 
@@ -126,7 +126,7 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	weather, err := m.provider.GetWeather()
-	outputFunc := m.outputFunc.Get().(func(Weather) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	for {
 		if s.Error(err) {
@@ -137,7 +137,7 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Weather) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		case <-m.scheduler.Tick():
 			weather, err = m.provider.GetWeather()
 		}
@@ -59,7 +59,7 @@ func (i Info) Enabled() bool {
 // Module represents a wlan bar module.
 type Module struct {
 	intf       string
-	outputFunc value.Value // of func(Info) bar.Output
+	outputFunc value.OutputFunc[Info]
 }
 
 // Named constructs an instance of the wlan module for the specified interface.
@@ -92,7 +92,7 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	info := Info{}
-	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	outputFunc := m.outputFunc.Get()
 	nextOutputFunc := m.outputFunc.Next()
 	var updateChan netlink.Subscription
 	if m.intf == "" {
@@ -112,7 +112,7 @@ func (m *Module) Stream(s bar.Sink) {
 			fillWifiInfo(&info)
 		case <-nextOutputFunc:
 			nextOutputFunc = m.outputFunc.Next()
-			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			outputFunc = m.outputFunc.Get()
 		}
 		s.Output(outputFunc(info))
 	}
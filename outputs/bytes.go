@@ -16,6 +16,8 @@ package outputs
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/dustin/go-humanize"
 	"github.com/martinlindhe/unit"
@@ -48,3 +50,171 @@ func IByterate(v unit.Datarate) string {
 	intval := uint64(v.BytesPerSecond())
 	return fmt.Sprintf("%s/s", humanize.IBytes(intval))
 }
+
+// Bitrate formats a Datarate in SI bit units, using the same rounding as
+// go-humanize. e.g. Bitrate(10 * unit.MegabitPerSecond) == "10 Mbit/s"
+func Bitrate(v unit.Datarate) string {
+	return humanizeBits(v.BitsPerSecond(), 1000,
+		[]string{"bit", "kbit", "Mbit", "Gbit", "Tbit", "Pbit", "Ebit"})
+}
+
+// IBitrate formats a Datarate in IEC bit units, using the same rounding as
+// go-humanize. e.g. IBitrate(10 * unit.MebibitPerSecond) == "10 Mibit/s"
+func IBitrate(v unit.Datarate) string {
+	return humanizeBits(v.BitsPerSecond(), 1024,
+		[]string{"bit", "Kibit", "Mibit", "Gibit", "Tibit", "Pibit", "Eibit"})
+}
+
+func humanizeBits(bits float64, base float64, sizes []string) string {
+	if bits < 10 {
+		return fmt.Sprintf("%.0f bit/s", math.Max(bits, 0))
+	}
+	e := math.Floor(math.Log(bits) / math.Log(base))
+	if e >= float64(len(sizes)) {
+		e = float64(len(sizes) - 1)
+	}
+	val := math.Floor(bits/math.Pow(base, e)*10+0.5) / 10
+	f := "%.0f %s/s"
+	if val < 10 {
+		f = "%.1f %s/s"
+	}
+	return fmt.Sprintf(f, val, sizes[int(e)])
+}
+
+// RateFormat formats Datarates with a fixed number of decimal places, using
+// units from a configurable range, right-justified to a fixed width. Unlike
+// Byterate and friends, the output keeps a stable width as the rate changes,
+// e.g. IByterateFormat().Width(12).Format(r) == "   9.9 MiB/s".
+//
+// RateFormat is a value type, so a format can be shared and modified freely,
+// e.g. f := IByterateFormat().Decimals(2); f.Width(12).Format(r).
+type RateFormat struct {
+	bits     bool
+	base     float64
+	units    []string
+	decimals int
+	width    int
+	min, max int
+}
+
+var (
+	siByteUnits  = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	iecByteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	siBitUnits   = []string{"bit", "kbit", "Mbit", "Gbit", "Tbit", "Pbit", "Ebit"}
+	iecBitUnits  = []string{"bit", "Kibit", "Mibit", "Gibit", "Tibit", "Pibit", "Eibit"}
+)
+
+func newRateFormat(bits bool, base float64, units []string) RateFormat {
+	return RateFormat{
+		bits:     bits,
+		base:     base,
+		units:    units,
+		decimals: 1,
+		min:      0,
+		max:      len(units) - 1,
+	}
+}
+
+// ByterateFormat returns a RateFormat for SI byte units (kB/s, MB/s, ...),
+// with one decimal place and no padding.
+func ByterateFormat() RateFormat { return newRateFormat(false, 1000, siByteUnits) }
+
+// IByterateFormat returns a RateFormat for IEC byte units (KiB/s, MiB/s, ...),
+// with one decimal place and no padding.
+func IByterateFormat() RateFormat { return newRateFormat(false, 1024, iecByteUnits) }
+
+// BitrateFormat returns a RateFormat for SI bit units (kbit/s, Mbit/s, ...),
+// with one decimal place and no padding.
+func BitrateFormat() RateFormat { return newRateFormat(true, 1000, siBitUnits) }
+
+// IBitrateFormat returns a RateFormat for IEC bit units (Kibit/s, ...),
+// with one decimal place and no padding.
+func IBitrateFormat() RateFormat { return newRateFormat(true, 1024, iecBitUnits) }
+
+// Decimals sets the number of decimal places shown. Rates in the smallest
+// unit (B/s or bit/s) are always shown without decimals.
+func (f RateFormat) Decimals(decimals int) RateFormat {
+	if decimals < 0 {
+		decimals = 0
+	}
+	f.decimals = decimals
+	return f
+}
+
+// Width sets the minimum width of the formatted rate. Shorter outputs are
+// right-justified by padding with spaces on the left.
+func (f RateFormat) Width(width int) RateFormat {
+	f.width = width
+	return f
+}
+
+// Units restricts the units used to the given range, instead of scaling
+// across all units. e.g. Units(unit.MegabytePerSecond, unit.MegabytePerSecond)
+// always shows MB/s, and Units(unit.KilobytePerSecond, unit.GigabytePerSecond)
+// shows "0.5 kB/s" instead of "500 B/s", but still scales up to GB/s.
+// Units are matched to the nearest unit of the format, so an SI unit used
+// with an IEC format selects the equivalent IEC unit.
+func (f RateFormat) Units(min, max unit.Datarate) RateFormat {
+	f.min, f.max = f.unitIndex(min), f.unitIndex(max)
+	if f.min > f.max {
+		f.min, f.max = f.max, f.min
+	}
+	return f
+}
+
+func (f RateFormat) quantity(v unit.Datarate) float64 {
+	if f.bits {
+		return v.BitsPerSecond()
+	}
+	return v.BytesPerSecond()
+}
+
+func (f RateFormat) unitIndex(v unit.Datarate) int {
+	q := f.quantity(v)
+	if q < 1 {
+		return 0
+	}
+	idx := int(math.Round(math.Log(q) / math.Log(f.base)))
+	if idx >= len(f.units) {
+		idx = len(f.units) - 1
+	}
+	return idx
+}
+
+// Format formats the given Datarate, e.g. "  12.3 MB/s".
+func (f RateFormat) Format(v unit.Datarate) string {
+	q := math.Max(f.quantity(v), 0)
+	e := 0
+	if q >= 1 {
+		e = int(math.Floor(math.Log(q) / math.Log(f.base)))
+	}
+	e = clampInt(e, f.min, f.max)
+	val, decimals := f.scaled(q, e)
+	// Rounding can push a value up to the next unit, e.g. 999.96 kB/s would
+	// be "1000.0 kB/s", which should be "1.0 MB/s" instead.
+	if e < f.max && val >= f.base {
+		e++
+		val, decimals = f.scaled(q, e)
+	}
+	out := fmt.Sprintf("%s %s/s",
+		strconv.FormatFloat(val, 'f', decimals, 64), f.units[e])
+	return fmt.Sprintf("%*s", f.width, out)
+}
+
+func (f RateFormat) scaled(q float64, e int) (val float64, decimals int) {
+	if e > 0 {
+		decimals = f.decimals
+	}
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(q/math.Pow(f.base, float64(e))*pow) / pow, decimals
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -34,3 +34,56 @@ func TestDataRateFormats(t *testing.T) {
 	require.Equal("10 kB/s", Byterate(10*1000*8*unit.BitPerSecond))
 	require.Equal("9.8 KiB/s", IByterate(10*1000*8*unit.BitPerSecond))
 }
+
+func TestBitrateFormats(t *testing.T) {
+	require := require.New(t)
+	require.Equal("10 Mbit/s", Bitrate(10*unit.MegabitPerSecond))
+	require.Equal("10 Mibit/s", IBitrate(10*unit.MebibitPerSecond))
+	require.Equal("80 kbit/s", Bitrate(10*unit.KilobytePerSecond))
+	require.Equal("9.8 Kibit/s", IBitrate(10*unit.KilobitPerSecond))
+	require.Equal("1.5 Gbit/s", Bitrate(1500*unit.MegabitPerSecond))
+	require.Equal("8 bit/s", Bitrate(unit.BytePerSecond))
+	require.Equal("0 bit/s", IBitrate(0))
+	require.Equal("15 bit/s", Bitrate(15*unit.BitPerSecond))
+}
+
+func TestRateFormat(t *testing.T) {
+	require := require.New(t)
+
+	f := IByterateFormat()
+	require.Equal("10.0 KiB/s", f.Format(10*unit.KibibytePerSecond))
+	require.Equal("512 B/s", f.Format(512*unit.BytePerSecond))
+	require.Equal("0 B/s", f.Format(-5*unit.BytePerSecond))
+	require.Equal("1.0 MiB/s", f.Format(1023.99*unit.KibibytePerSecond),
+		"rounds up to the next unit")
+
+	padded := f.Width(12)
+	require.Equal("   9.9 MiB/s", padded.Format(9.9*unit.MebibytePerSecond))
+	require.Equal("  10.1 MiB/s", padded.Format(10.1*unit.MebibytePerSecond))
+	require.Equal(" 999.0 KiB/s", padded.Format(999*unit.KibibytePerSecond))
+	require.Equal("10.0 KiB/s", f.Format(10*unit.KibibytePerSecond),
+		"original format is unchanged")
+
+	require.Equal("9.90 MB/s", ByterateFormat().Decimals(2).Format(9.9*unit.MegabytePerSecond))
+	require.Equal("10 MB/s", ByterateFormat().Decimals(-1).Format(10*unit.MegabytePerSecond))
+
+	pinned := ByterateFormat().Units(unit.MegabytePerSecond, unit.MegabytePerSecond)
+	require.Equal("0.5 MB/s", pinned.Format(500*unit.KilobytePerSecond))
+	require.Equal("2500.0 MB/s", pinned.Format(2.5*unit.GigabytePerSecond))
+	require.Equal("1000.0 MB/s", pinned.Format(999.99*unit.MegabytePerSecond),
+		"does not round up past the pinned unit")
+
+	ranged := IByterateFormat().Units(unit.GibibytePerSecond, unit.KibibytePerSecond)
+	require.Equal("0.1 KiB/s", ranged.Format(100*unit.BytePerSecond))
+	require.Equal("12.0 MiB/s", ranged.Format(12*unit.MebibytePerSecond))
+	require.Equal("2048.0 GiB/s", ranged.Format(2*unit.TebibytePerSecond))
+
+	require.Equal("1.2 MiB/s",
+		IByterateFormat().Units(unit.MegabytePerSecond, unit.MegabytePerSecond).
+			Format(1.2*unit.MebibytePerSecond),
+		"SI units select the nearest IEC unit")
+
+	require.Equal("10.0 Mbit/s", BitrateFormat().Format(10*unit.MegabitPerSecond))
+	require.Equal("8.0 Kibit/s", IBitrateFormat().Format(unit.KibibytePerSecond))
+	require.Equal("   800 bit/s", BitrateFormat().Width(12).Format(100*unit.BytePerSecond))
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"barista.run/bar"
+)
+
+// Detail wraps an output with additional detail, such as a multi-line
+// description or a list of named fields, that does not fit on the bar but can
+// be shown outside it, for example in a notification or a popup.
+type Detail struct {
+	output bar.Output
+	title  string
+	text   string
+	fields []field
+	show   func(*Detail)
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+// WithDetail constructs an output that displays the given output on the bar,
+// and shows the detail on left click using ShowDetail(). Segments that
+// already have a click handler are not affected.
+func WithDetail(output bar.Output) *Detail {
+	return &Detail{output: output, show: ShowDetail()}
+}
+
+// Title sets the title of the detail, e.g. the summary of a notification.
+func (d *Detail) Title(title string) *Detail {
+	d.title = title
+	return d
+}
+
+// Text sets the free-form (possibly multi-line) text of the detail.
+func (d *Detail) Text(text string) *Detail {
+	d.text = text
+	return d
+}
+
+// Textf sets the text of the detail using a format string and arguments.
+func (d *Detail) Textf(format string, args ...interface{}) *Detail {
+	return d.Text(fmt.Sprintf(format, args...))
+}
+
+// Field adds a named field to the detail, displayed after the text.
+func (d *Detail) Field(name string, value interface{}) *Detail {
+	d.fields = append(d.fields, field{name, fmt.Sprintf("%v", value)})
+	return d
+}
+
+// OnShow sets the function used to show the detail when clicked, replacing
+// the default of ShowDetail().
+func (d *Detail) OnShow(show func(*Detail)) *Detail {
+	d.show = show
+	return d
+}
+
+// GetTitle returns the title of the detail.
+func (d *Detail) GetTitle() string {
+	return d.title
+}
+
+// Body returns the text of the detail, followed by one line for each field.
+func (d *Detail) Body() string {
+	lines := []string{}
+	if d.text != "" {
+		lines = append(lines, d.text)
+	}
+	for _, f := range d.fields {
+		lines = append(lines, fmt.Sprintf("%s: %s", f.name, f.value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Show shows the detail, using the function set by OnShow.
+func (d *Detail) Show() {
+	if d.show != nil {
+		d.show(d)
+	}
+}
+
+// Segments implements bar.Output for Detail.
+func (d *Detail) Segments() []*bar.Segment {
+	if d.output == nil {
+		return nil
+	}
+	var segments []*bar.Segment
+	for _, s := range d.output.Segments() {
+		s = s.Clone()
+		if !s.HasClick() {
+			s.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					d.Show()
+				}
+			})
+		}
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+// runCommand runs an external command, replaced in tests.
+var runCommand = func(cmd string, args ...string) error {
+	return exec.Command(cmd, args...).Run()
+}
+
+// ShowDetail returns a function that shows a detail as a desktop
+// notification using notify-send.
+func ShowDetail() func(*Detail) {
+	return func(d *Detail) {
+		title := d.GetTitle()
+		if title == "" {
+			title = "barista"
+		}
+		runCommand("notify-send", title, d.Body())
+	}
+}
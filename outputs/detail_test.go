@@ -0,0 +1,70 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+
+	"barista.run/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetail(t *testing.T) {
+	d := WithDetail(Group(Text("a"), Text("b").OnClick(nil))).
+		Title("Title").
+		Textf("line %d\nline %d", 1, 2).
+		Field("Speed", "10 MB/s").
+		Field("Count", 3)
+
+	require.Equal(t, "Title", d.GetTitle())
+	require.Equal(t, "line 1\nline 2\nSpeed: 10 MB/s\nCount: 3", d.Body())
+
+	var shown []string
+	origRunCommand := runCommand
+	defer func() { runCommand = origRunCommand }()
+	runCommand = func(cmd string, args ...string) error {
+		shown = append(shown, cmd)
+		shown = append(shown, args...)
+		return nil
+	}
+
+	segments := d.Segments()
+	require.Len(t, segments, 2)
+	require.True(t, segments[0].HasClick(), "detail click handler added")
+	segments[0].Click(bar.Event{Button: bar.ScrollUp})
+	require.Empty(t, shown, "detail only shown on left click")
+	segments[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t,
+		[]string{"notify-send", "Title", "line 1\nline 2\nSpeed: 10 MB/s\nCount: 3"},
+		shown, "notification shown on left click")
+
+	shown = nil
+	segments[1].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Empty(t, shown, "existing click handler is not replaced")
+
+	var custom *Detail
+	d.OnShow(func(d *Detail) { custom = d })
+	d.Segments()[0].Click(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, d, custom, "custom show function")
+	require.Empty(t, shown)
+
+	shown = nil
+	WithDetail(Text("x")).Field("k", "v").Show()
+	require.Equal(t, []string{"notify-send", "barista", "k: v"}, shown,
+		"default title")
+
+	require.Empty(t, WithDetail(nil).Title("foo").Segments())
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type durationUnit struct {
+	size        time.Duration
+	short, long string
+}
+
+var durationUnits = []durationUnit{
+	{24 * time.Hour, "d", "day"},
+	{time.Hour, "h", "hour"},
+	{time.Minute, "m", "minute"},
+	{time.Second, "s", "second"},
+}
+
+// Duration formats a duration as compact text with up to two units,
+// e.g. "3h12m", "45s", "2d4h". Smaller units are truncated, not rounded.
+func Duration(d time.Duration) string {
+	return DurationN(d, 2)
+}
+
+// DurationN is like Duration, but with up to the given number of units,
+// counted from the largest non-zero unit. Units that are zero are omitted,
+// e.g. DurationN(49*time.Hour+5*time.Minute, 2) == "2d1h", but
+// DurationN(48*time.Hour+5*time.Minute, 2) == "2d".
+func DurationN(d time.Duration, units int) string {
+	return formatDuration(d, units, false)
+}
+
+// DurationFull formats a duration as words with up to two units,
+// e.g. "3 hours 12 minutes", "1 day".
+func DurationFull(d time.Duration) string {
+	return DurationFullN(d, 2)
+}
+
+// DurationFullN is like DurationFull, but with up to the given number of
+// units, counted as in DurationN.
+func DurationFullN(d time.Duration, units int) string {
+	return formatDuration(d, units, true)
+}
+
+func formatDuration(d time.Duration, units int, full bool) string {
+	if units < 1 {
+		units = 1
+	}
+	sign := ""
+	if d <= -time.Second {
+		sign = "-"
+	}
+	if d < 0 {
+		d = -d
+	}
+	var parts []string
+	started := false
+	for i, u := range durationUnits {
+		n := d / u.size
+		d -= n * u.size
+		if !started {
+			// Skip leading zero units, but always show at least seconds.
+			if n == 0 && i < len(durationUnits)-1 {
+				continue
+			}
+			started = true
+		}
+		if units == 0 {
+			break
+		}
+		units--
+		if n == 0 && len(parts) > 0 {
+			continue
+		}
+		switch {
+		case !full:
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.short))
+		case n == 1:
+			parts = append(parts, "1 "+u.long)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, u.long))
+		}
+	}
+	sep := ""
+	if full {
+		sep = " "
+	}
+	return sign + strings.Join(parts, sep)
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	day := 24 * time.Hour
+	for _, tc := range []struct {
+		d     time.Duration
+		short string
+		full  string
+	}{
+		{0, "0s", "0 seconds"},
+		{500 * time.Millisecond, "0s", "0 seconds"},
+		{time.Second, "1s", "1 second"},
+		{45 * time.Second, "45s", "45 seconds"},
+		{90 * time.Second, "1m30s", "1 minute 30 seconds"},
+		{3*time.Hour + 12*time.Minute + 59*time.Second, "3h12m", "3 hours 12 minutes"},
+		{2*day + 4*time.Hour + 30*time.Minute, "2d4h", "2 days 4 hours"},
+		{2*day + 5*time.Minute, "2d", "2 days"},
+		{-90 * time.Second, "-1m30s", "-1 minute 30 seconds"},
+		{-100 * time.Millisecond, "0s", "0 seconds"},
+	} {
+		require.Equal(t, tc.short, Duration(tc.d), "Duration(%v)", tc.d)
+		require.Equal(t, tc.full, DurationFull(tc.d), "DurationFull(%v)", tc.d)
+	}
+
+	d := 2*day + 4*time.Hour + 5*time.Second
+	require.Equal(t, "2d", DurationN(d, 1))
+	require.Equal(t, "2d4h5s", DurationN(d, 4))
+	require.Equal(t, "2d4h", DurationN(d, 3), "zero units count")
+	require.Equal(t, "2 days 4 hours 5 seconds", DurationFullN(d, 4))
+	require.Equal(t, "2d", DurationN(d, 0), "at least one unit")
+}
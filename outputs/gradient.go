@@ -0,0 +1,78 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// GradientStop is a colour at a position along a gradient. Positions are
+// usually in [0,1], but any increasing sequence of positions works.
+type GradientStop struct {
+	Pos   float64
+	Color color.Color
+}
+
+// Gradient returns the colour at the given position along a gradient, by
+// interpolating in RGB space between the nearest two stops. Values outside
+// the range of stops return the colour of the nearest endpoint.
+//
+// Stops must be sorted by position. If they are not sorted, if there are no
+// stops, or if any stop has no colour, Gradient returns nil, which can be
+// passed to Segment.Color to leave the colour unset.
+func Gradient(value float64, stops ...GradientStop) color.Color {
+	return gradient(value, stops, colorful.Color.BlendRgb)
+}
+
+// GradientHsv is like Gradient, but interpolates in HSV space, which keeps
+// intermediate colours saturated, e.g. green to red passes through yellow
+// instead of brown.
+func GradientHsv(value float64, stops ...GradientStop) color.Color {
+	return gradient(value, stops, colorful.Color.BlendHsv)
+}
+
+type blendFunc func(c1, c2 colorful.Color, t float64) colorful.Color
+
+func gradient(value float64, stops []GradientStop, blend blendFunc) color.Color {
+	if len(stops) == 0 {
+		return nil
+	}
+	cols := make([]colorful.Color, len(stops))
+	for i, s := range stops {
+		if i > 0 && s.Pos < stops[i-1].Pos {
+			return nil
+		}
+		if s.Color == nil {
+			return nil
+		}
+		c, _ := colorful.MakeColor(s.Color)
+		cols[i] = c
+	}
+	if math.IsNaN(value) || value <= stops[0].Pos {
+		return cols[0]
+	}
+	for i := 1; i < len(stops); i++ {
+		if value > stops[i].Pos {
+			continue
+		}
+		lo, hi := stops[i-1].Pos, stops[i].Pos
+		// lo < value <= hi, so hi > lo here.
+		return blend(cols[i-1], cols[i], (value-lo)/(hi-lo))
+	}
+	return cols[len(cols)-1]
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"barista.run/colors"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/stretchr/testify/require"
+)
+
+func hexOf(t *testing.T, c color.Color) string {
+	require.NotNil(t, c)
+	return c.(colorful.Color).Hex()
+}
+
+func TestGradient(t *testing.T) {
+	green, red := colors.Hex("#00ff00"), colors.Hex("#ff0000")
+	stops := []GradientStop{{0, green}, {1, red}}
+	for _, tc := range []struct {
+		value    float64
+		expected string
+	}{
+		{-1, "#00ff00"},
+		{0, "#00ff00"},
+		{0.5, "#808000"},
+		{1, "#ff0000"},
+		{2, "#ff0000"},
+		{math.NaN(), "#00ff00"},
+	} {
+		require.Equal(t, tc.expected, hexOf(t, Gradient(tc.value, stops...)),
+			"Gradient(%v)", tc.value)
+	}
+
+	require.Equal(t, "#ffff00", hexOf(t, GradientHsv(0.5, stops...)),
+		"hsv passes through yellow")
+
+	threeStops := []GradientStop{
+		{0, green}, {0.8, colors.Hex("#ffff00")}, {1, red},
+	}
+	require.Equal(t, "#80ff00", hexOf(t, Gradient(0.4, threeStops...)))
+	require.Equal(t, "#ff8000", hexOf(t, Gradient(0.9, threeStops...)))
+
+	hardStop := []GradientStop{{0, green}, {0.5, green}, {0.5, red}, {1, red}}
+	require.Equal(t, "#00ff00", hexOf(t, Gradient(0.5, hardStop...)))
+	require.Equal(t, "#ff0000", hexOf(t, Gradient(0.51, hardStop...)))
+
+	require.Nil(t, Gradient(0.5), "no stops")
+	require.Nil(t, Gradient(0.5, GradientStop{1, red}, GradientStop{0, green}),
+		"unsorted stops")
+	require.Nil(t, Gradient(0.5, stops[0], GradientStop{Pos: 1}),
+		"stop without colour")
+	require.Equal(t, "#00ff00", hexOf(t, Gradient(0.5, stops[0])),
+		"single stop")
+
+	seg := Text("x").Color(Gradient(0.5, stops...))
+	_, set := seg.GetColor()
+	require.True(t, set, "plugs into Segment.Color")
+}
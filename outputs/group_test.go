@@ -236,6 +236,37 @@ func TestSingleGroup(t *testing.T) {
 	require.False(isSet)
 }
 
+func TestNestedGroupSeparators(t *testing.T) {
+	require := require.New(t)
+	up := Text("up").Color(colors.Hex("#0f0"))
+	down := Text("down").Color(colors.Hex("#f00"))
+	speeds := Group(up, down).Glue()
+	out := Group(Text("net"), speeds, Text("end").Separator(false)).
+		InnerSeparators(true).Separator(true)
+	segs := out.Segments()
+	require.Len(segs, 4)
+
+	sep, isSet := segs[0].HasSeparator()
+	require.True(isSet)
+	require.True(sep, "inner separator before nested group")
+	sep, isSet = segs[1].HasSeparator()
+	require.True(isSet)
+	require.False(sep, "glue of nested group is kept")
+	sep, isSet = segs[2].HasSeparator()
+	require.True(isSet)
+	require.True(sep, "inner separator after last segment of nested group")
+	sep, _ = segs[3].HasSeparator()
+	require.False(sep, "explicit separator of last segment is kept")
+
+	c, _ := segs[1].GetColor()
+	require.Equal(colors.Hex("#0f0"), c, "segments keep their own colours")
+	c, _ = segs[2].GetColor()
+	require.Equal(colors.Hex("#f00"), c, "segments keep their own colours")
+
+	sep, _ = speeds.Segments()[1].HasSeparator()
+	require.True(sep, "nested group is not modified")
+}
+
 func TestEmptyGroup(t *testing.T) {
 	// Sanity check properties where the number of segments matters.
 	empty := Group()
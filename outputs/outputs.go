@@ -44,6 +44,11 @@ func Text(text string) *bar.Segment {
 }
 
 // Pango constructs a pango bar segment from a list of pango Nodes and strings.
+// Strings and other values are escaped, so user-supplied text can be mixed
+// with formatted nodes safely, e.g. for log lines with a bold prefix:
+//     outputs.Pango(pango.Text("ERROR").Bold().Color(red), " ", line)
+// Nodes are built using the pango package, e.g. pango.Text(s).Bold(),
+// .Color(c), .Size(pt), or .Append(...) to nest further nodes.
 func Pango(things ...interface{}) *bar.Segment {
 	nodes := []*pango.Node{}
 	for _, thing := range things {
@@ -61,7 +66,13 @@ func Pango(things ...interface{}) *bar.Segment {
 
 // Group concatenates several outputs into a single SegmentGroup,
 // to facilitate easier manipulation of output properties.
-// For example, setting a colour or urgency for all segments together.
+// For example, setting a colour or urgency for all segments together,
+// or displaying values as separate segments that can be styled individually:
+//     outputs.Group(
+//         outputs.Text(outputs.IByterate(s.Tx)).Color(up),
+//         outputs.Text(outputs.IByterate(s.Rx)).Color(down),
+//     ).InnerSeparators(false)
+// Nested groups are flattened into their segments.
 func Group(outputs ...bar.Output) *SegmentGroup {
 	group := new(SegmentGroup)
 	for _, o := range outputs {
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"barista.run/bar"
+	"barista.run/colors"
 	"barista.run/pango"
 	pangoTesting "barista.run/testing/pango"
 
@@ -82,6 +83,21 @@ func TestPango(t *testing.T) {
 			Pango(pango.Text("<").Heavy(), 3.14159, " ", true, pango.Text(">").Heavy()),
 			"<span weight='heavy'>&lt;</span>3.14159 true<span weight='heavy'>&gt;</span>",
 		},
+		{
+			"escapes user text",
+			Pango(pango.Text("ERROR").Bold(), ` <b>"x" & 'y'</b>`),
+			"<span weight='bold'>ERROR</span> &lt;b&gt;&#34;x&#34; &amp; &#39;y&#39;&lt;/b&gt;",
+		},
+		{
+			"escapes text in formatted nodes",
+			Pango(pango.Text("a<b").Color(colors.Hex("#f00")).AppendText(`&"`)),
+			"<span color='#ff0000'>a&lt;b&amp;&#34;</span>",
+		},
+		{
+			"escapes formatted values",
+			Pango(fmt.Errorf("<%s>", "&")),
+			"&lt;&amp;&gt;",
+		},
 	}
 	for _, tc := range tests {
 		pangoTesting.AssertEqual(t, tc.expected, textOf(tc.output), tc.desc)
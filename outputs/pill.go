@@ -0,0 +1,108 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"image/color"
+
+	"barista.run/bar"
+)
+
+// PillGroup represents a group of segments displayed as a single unit, e.g.
+// an icon and a value that appear joined, with a shared background and
+// border, that respond to clicks as one.
+type PillGroup struct {
+	group        *SegmentGroup
+	background   color.Color
+	border       color.Color
+	clickHandler func(bar.Event)
+}
+
+// Pill groups the given outputs into a pill, with no separators or padding
+// between them.
+func Pill(outputs ...bar.Output) *PillGroup {
+	return &PillGroup{group: Group(outputs...).Glue()}
+}
+
+// Background sets the background color for all segments in the pill,
+// replacing any background set on the individual segments.
+func (p *PillGroup) Background(background color.Color) *PillGroup {
+	p.background = background
+	return p
+}
+
+// Border sets the border color for all segments in the pill,
+// replacing any border set on the individual segments.
+func (p *PillGroup) Border(border color.Color) *PillGroup {
+	p.border = border
+	return p
+}
+
+// Color sets the color for segments in the pill that don't have one.
+func (p *PillGroup) Color(color color.Color) *PillGroup {
+	p.group.Color(color)
+	return p
+}
+
+// OnClick sets the click handler for the pill. Clicks on any segment in the
+// pill are delivered to this handler. If not set, the first click handler of
+// the segments in the pill is used for all segments.
+func (p *PillGroup) OnClick(f func(bar.Event)) *PillGroup {
+	p.clickHandler = f
+	return p
+}
+
+// Separator sets the separator visibility after the pill.
+func (p *PillGroup) Separator(separator bool) *PillGroup {
+	p.group.Separator(separator)
+	return p
+}
+
+// Padding sets the padding after the pill.
+func (p *PillGroup) Padding(separatorWidth int) *PillGroup {
+	p.group.Padding(separatorWidth)
+	return p
+}
+
+// Append adds additional segments to the pill.
+func (p *PillGroup) Append(output bar.Output) *PillGroup {
+	p.group.Append(output)
+	return p
+}
+
+// Segments implements bar.Output for PillGroup.
+func (p *PillGroup) Segments() []*bar.Segment {
+	segments := p.group.Segments()
+	handler := p.clickHandler
+	for _, s := range segments {
+		if handler == nil && s.HasClick() {
+			// Use a copy, since the handler will be replaced below.
+			handler = s.Clone().Click
+		}
+	}
+	// The group's segments are copies, so they can be modified safely.
+	for _, s := range segments {
+		if p.background != nil {
+			s.Background(p.background)
+		}
+		if p.border != nil {
+			s.Border(p.border)
+		}
+		if handler != nil {
+			s.OnClick(handler)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPill(t *testing.T) {
+	require := require.New(t)
+	var clicks []string
+	pill := Pill(
+		Text("icon").Background(colors.Hex("#f00")),
+		Group(Text("1"), Text("2").OnClick(func(bar.Event) {
+			clicks = append(clicks, "value")
+		})),
+	).Background(colors.Hex("#333")).Border(colors.Hex("#666"))
+
+	segs := pill.Segments()
+	require.Len(segs, 3)
+	for i, s := range segs {
+		bg, _ := s.GetBackground()
+		require.Equal(colors.Hex("#333"), bg, "shared background on %d", i)
+		border, _ := s.GetBorder()
+		require.Equal(colors.Hex("#666"), border, "shared border on %d", i)
+		require.True(s.HasClick(), "click handler on %d", i)
+		s.Click(bar.Event{Button: bar.ButtonLeft})
+	}
+	for i, s := range segs[:2] {
+		sep, _ := s.HasSeparator()
+		require.False(sep, "no inner separator on %d", i)
+		padding, _ := s.GetPadding()
+		require.Equal(0, padding, "no inner padding on %d", i)
+	}
+	_, isSet := segs[2].HasSeparator()
+	require.False(isSet, "outer separator left to the bar")
+	require.Equal([]string{"value", "value", "value"}, clicks,
+		"segment click handler delivered for all segments")
+
+	clicks = nil
+	pill.OnClick(func(bar.Event) { clicks = append(clicks, "pill") }).
+		Separator(true).Padding(12)
+	segs = pill.Segments()
+	for _, s := range segs {
+		s.Click(bar.Event{Button: bar.ButtonLeft})
+	}
+	require.Equal([]string{"pill", "pill", "pill"}, clicks,
+		"pill click handler replaces segment handlers")
+	sep, _ := segs[2].HasSeparator()
+	require.True(sep, "outer separator")
+	padding, _ := segs[2].GetPadding()
+	require.Equal(12, padding, "outer padding")
+
+	segs = Pill(Text("a"), Text("b")).Append(Text("c")).Segments()
+	require.Len(segs, 3)
+	for i, s := range segs {
+		_, isSet := s.GetBackground()
+		require.False(isSet, "no background unless set on %d", i)
+		require.False(s.HasClick(), "no click handler unless set on %d", i)
+	}
+	sep, _ = segs[1].HasSeparator()
+	require.False(sep, "appended segments are glued")
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/colors"
+)
+
+// Severity represents how much attention an output needs, and is used to
+// style outputs consistently across modules.
+type Severity int
+
+// Possible severities, in increasing order of importance.
+const (
+	Normal Severity = iota
+	Info
+	Warning
+	Critical
+)
+
+// SeverityStyle describes how outputs of a given severity are displayed.
+type SeverityStyle struct {
+	// Color is the name of a colour in the colour scheme, resolved when the
+	// style is applied so that changes to the scheme are picked up.
+	Color string
+	// Urgent marks the output as urgent.
+	Urgent bool
+	// Icon, if set, is displayed glued to the left of the output.
+	Icon bar.Output
+}
+
+var severityStyles = map[Severity]SeverityStyle{
+	Normal:   {},
+	Info:     {Color: "good"},
+	Warning:  {Color: "degraded"},
+	Critical: {Color: "bad", Urgent: true},
+}
+var severityMu sync.RWMutex
+
+// SetSeverityStyle overrides the style used for outputs of the given severity.
+func SetSeverityStyle(sev Severity, style SeverityStyle) {
+	severityMu.Lock()
+	defer severityMu.Unlock()
+	severityStyles[sev] = style
+}
+
+// GetSeverityStyle returns the style used for outputs of the given severity.
+func GetSeverityStyle(sev Severity) SeverityStyle {
+	severityMu.RLock()
+	defer severityMu.RUnlock()
+	return severityStyles[sev]
+}
+
+// WithSeverity applies the colour, urgency, and icon of the given severity
+// to a segment. The colour is taken from the current colour scheme, and is
+// not applied if the scheme does not define it.
+func WithSeverity(seg *bar.Segment, sev Severity) bar.Output {
+	style := GetSeverityStyle(sev)
+	c := colors.Scheme(style.Color)
+	if c != nil {
+		seg.Color(c)
+	}
+	if style.Urgent {
+		seg.Urgent(true)
+	}
+	if style.Icon == nil {
+		return seg
+	}
+	g := Group(style.Icon, seg).Glue()
+	if c != nil {
+		g.Color(c)
+	}
+	if style.Urgent {
+		g.Urgent(true)
+	}
+	return g
+}
+
+// Urgent marks all segments of the output as urgent if the condition holds,
+// and returns the output unchanged otherwise. The segments are copied before
+// being marked, so outputs that are reused across renders (e.g. a fixed
+// offline output) are no longer urgent once the condition is false.
+func Urgent(out bar.Output, when bool) bar.Output {
+	if !when || out == nil {
+		return out
+	}
+	var segs bar.Segments
+	for _, s := range out.Segments() {
+		segs = append(segs, s.Clone().Urgent(true))
+	}
+	return segs
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+
+	"barista.run/colors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSeverity(t *testing.T) {
+	colors.Set("good", colors.Hex("#0f0"))
+	colors.Set("degraded", colors.Hex("#ff0"))
+	colors.Set("bad", colors.Hex("#f00"))
+	defer func() {
+		for _, n := range []string{"good", "degraded", "bad"} {
+			colors.Set(n, nil)
+		}
+	}()
+
+	for _, tc := range []struct {
+		sev    Severity
+		urgent bool
+	}{
+		{Normal, false},
+		{Info, false},
+		{Warning, false},
+		{Critical, true},
+	} {
+		segs := WithSeverity(Text("test"), tc.sev).Segments()
+		require.Len(t, segs, 1, "severity %d", tc.sev)
+		urgent, _ := segs[0].IsUrgent()
+		require.Equal(t, tc.urgent, urgent, "urgent for severity %d", tc.sev)
+	}
+
+	_, set := WithSeverity(Text("test"), Normal).Segments()[0].GetColor()
+	require.False(t, set, "no colour for normal severity")
+
+	for sev, name := range map[Severity]string{
+		Info: "good", Warning: "degraded", Critical: "bad",
+	} {
+		col, _ := WithSeverity(Text("test"), sev).Segments()[0].GetColor()
+		require.Equal(t, colors.Scheme(name), col, "colour for severity %d", sev)
+	}
+}
+
+func TestSeverityStyleOverride(t *testing.T) {
+	orig := GetSeverityStyle(Warning)
+	defer SetSeverityStyle(Warning, orig)
+
+	colors.Set("warn", colors.Hex("#fa0"))
+	defer colors.Set("warn", nil)
+
+	SetSeverityStyle(Warning, SeverityStyle{
+		Color:  "warn",
+		Urgent: true,
+		Icon:   Text("!"),
+	})
+	segs := WithSeverity(Text("disk"), Warning).Segments()
+	require.Len(t, segs, 2, "icon is prepended")
+	for i, txt := range []string{"!", "disk"} {
+		content, _ := segs[i].Content()
+		require.Equal(t, txt, content)
+		col, _ := segs[i].GetColor()
+		require.Equal(t, colors.Scheme("warn"), col)
+		urgent, _ := segs[i].IsUrgent()
+		require.True(t, urgent)
+	}
+	sep, _ := segs[0].HasSeparator()
+	require.False(t, sep, "icon is glued to output")
+
+	SetSeverityStyle(Warning, SeverityStyle{Color: "undefined"})
+	_, set := WithSeverity(Text("disk"), Warning).Segments()[0].GetColor()
+	require.False(t, set, "missing scheme colours are not applied")
+}
+
+func TestUrgent(t *testing.T) {
+	require.Nil(t, Urgent(nil, true))
+
+	fixed := Group(Text("a"), Text("b"))
+	out := Urgent(fixed, true)
+	require.Len(t, out.Segments(), 2)
+	for i, s := range out.Segments() {
+		urgent, _ := s.IsUrgent()
+		require.True(t, urgent, "segment %d is urgent", i)
+	}
+	for i, s := range fixed.Segments() {
+		_, isSet := s.IsUrgent()
+		require.False(t, isSet, "original segment %d is unchanged", i)
+	}
+
+	out = Urgent(fixed, false)
+	require.Equal(t, fixed, out, "output unchanged when condition is false")
+	for _, s := range out.Segments() {
+		_, isSet := s.IsUrgent()
+		require.False(t, isSet, "urgency cleared when condition is false")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"barista.run/bar"
+	"barista.run/pango"
+)
+
+// markup is pango markup that is output by a template as is.
+type markup string
+
+// escapeFunc is the name of the function added to the end of every pipeline
+// that produces output, to escape the value for use in pango markup.
+const escapeFunc = "_escape"
+
+// templateFuncs are the functions available to templates, exposing the
+// formatting helpers from this package and pango.
+var templateFuncs = template.FuncMap{
+	"bytesize":  Bytesize,
+	"ibytesize": IBytesize,
+	"byterate":  Byterate,
+	"ibyterate": IByterate,
+	"bitrate":   Bitrate,
+	"ibitrate":  IBitrate,
+	"raw":       func(s string) markup { return markup(s) },
+	"icon":      func(ident string) markup { return markup(pango.Icon(ident).String()) },
+	escapeFunc:  escapeValue,
+}
+
+// escapeValue formats a value output by a template, escaping it unless it is
+// already markup.
+func escapeValue(v interface{}) string {
+	switch v := v.(type) {
+	case markup:
+		return string(v)
+	case *pango.Node:
+		return v.String()
+	}
+	return pango.Text(fmt.Sprint(v)).String()
+}
+
+// ParseTemplate compiles a text/template, and returns a function that renders
+// it as pango markup against the data it is given, e.g. a module's info
+// struct. The text of the template is markup, but like html/template, values
+// output by the template are escaped, so that data containing '<' or '&' is
+// displayed as is. Use the "raw" function to output a string as markup.
+//
+// In addition to the standard template functions, templates can use:
+//   - bytesize, ibytesize, byterate, ibyterate, bitrate, ibitrate: format
+//     unit values, e.g. {{.Rx | ibyterate}}.
+//   - raw: output a string as pango markup without escaping it, e.g.
+//     {{raw .Markup}}. Only use this for trusted data.
+//   - icon: display an icon, e.g. {{icon "material-wifi"}}.
+//
+// Errors executing the template are displayed as error outputs.
+func ParseTemplate(tmpl string) (func(interface{}) bar.Output, error) {
+	t, err := template.New("output").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range t.Templates() {
+		if t.Tree != nil {
+			escapeList(t.Tree.Root)
+		}
+	}
+	return func(data interface{}) bar.Output {
+		var out strings.Builder
+		if err := t.Execute(&out, data); err != nil {
+			return Error(err)
+		}
+		return bar.PangoSegment(out.String())
+	}, nil
+}
+
+// escapeList adds the escape function to the end of every pipeline in the
+// list that produces output, including those in nested control structures.
+func escapeList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.ActionNode:
+			// Pipelines that declare variables do not produce output.
+			if len(n.Pipe.Decl) == 0 {
+				n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+					NodeType: parse.NodeCommand,
+					Pos:      n.Pos,
+					Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetPos(n.Pos)},
+				})
+			}
+		case *parse.IfNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.RangeNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.WithNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		}
+	}
+}
+
+// Template is like ParseTemplate, but panics if the template cannot be
+// parsed. It is meant for templates that are constants in the bar's code,
+// so that mistakes are caught at startup instead of on every update.
+func Template(tmpl string) func(interface{}) bar.Output {
+	f, err := ParseTemplate(tmpl)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+
+	"barista.run/pango"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	type info struct {
+		Name string
+		Rx   unit.Datarate
+		Size unit.Datasize
+	}
+	tmpl := Template(`<b>{{.Name}}</b> {{.Rx | ibyterate}} {{.Size | bytesize}}`)
+	txt, isPango := tmpl(info{"a&b", 10 * unit.KibibytePerSecond, 10 * unit.Kilobyte}).
+		Segments()[0].Content()
+	require.True(t, isPango, "template output is pango")
+	require.Equal(t, "<b>a&amp;b</b> 10 KiB/s 10 kB", txt, "values are escaped")
+
+	txt, _ = Template(`{{if .Name}}{{with $n := .Name}}{{printf "%s" $n}}{{end}}{{end}}`)(
+		info{Name: "<>"}).Segments()[0].Content()
+	require.Equal(t, "&lt;&gt;", txt, "values in control structures are escaped")
+
+	txt, _ = Template(`{{raw .Name}} {{icon "test-icon"}}`)(info{Name: "<i>x</i>"}).
+		Segments()[0].Content()
+	require.Equal(t, "<i>x</i> "+pango.Icon("test-icon").String(), txt,
+		"raw and icon output markup")
+
+	txt, _ = Template(`{{index . "rate" | bitrate}}`)(map[string]interface{}{
+		"rate": 10 * unit.MegabitPerSecond,
+	}).Segments()[0].Content()
+	require.Equal(t, "10 Mbit/s", txt, "works with maps")
+
+	errOut := Template(`{{.Missing}}`)(info{}).Segments()[0]
+	require.Error(t, errOut.GetError(), "when template fails to execute")
+
+	_, err := ParseTemplate(`{{.Name`)
+	require.Error(t, err, "on parse error")
+	require.Panics(t, func() { Template(`{{.Name | nosuchfunc}}`) },
+		"Template panics on parse error")
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"barista.run/bar"
+)
+
+// TruncateMode controls which part of the text is removed when truncating.
+type TruncateMode int
+
+// Truncation modes, named for where the ellipsis is placed.
+const (
+	// TruncateEnd keeps the start of the text, e.g. "some lo…".
+	TruncateEnd TruncateMode = iota
+	// TruncateStart keeps the end of the text, e.g. "…/to/file".
+	TruncateStart
+	// TruncateMiddle keeps both ends of the text, e.g. "/hom…file".
+	TruncateMiddle
+)
+
+const ellipsis = "…"
+
+// Truncate shortens text to at most maxRunes runes, including an ellipsis
+// at the end if any text was removed.
+func Truncate(text string, maxRunes int) string {
+	return TruncateWith(text, maxRunes, TruncateEnd)
+}
+
+// TruncateWith shortens text to at most maxRunes runes, including an
+// ellipsis where text was removed, as determined by mode.
+func TruncateWith(text string, maxRunes int, mode TruncateMode) string {
+	var tokens []truncToken
+	for _, r := range text {
+		tokens = append(tokens, truncToken{string(r), true})
+	}
+	return truncate(tokens, maxRunes, mode)
+}
+
+// TruncatePango is like TruncateWith, but for pango markup. Tags are kept,
+// so that spans remain balanced, and entities count as a single rune. Only
+// the visible text is counted and removed.
+func TruncatePango(markup string, maxRunes int, mode TruncateMode) string {
+	var tokens []truncToken
+	for len(markup) > 0 {
+		end := -1
+		visible := true
+		switch markup[0] {
+		case '<':
+			if i := strings.IndexByte(markup, '>'); i >= 0 {
+				end, visible = i+1, false
+			}
+		case '&':
+			if i := strings.IndexByte(markup, ';'); i >= 0 {
+				end = i + 1
+			}
+		}
+		if end < 0 {
+			_, end = utf8.DecodeRuneInString(markup)
+		}
+		tokens = append(tokens, truncToken{markup[:end], visible})
+		markup = markup[end:]
+	}
+	return truncate(tokens, maxRunes, mode)
+}
+
+// MaxWidth truncates the text of a segment to at most maxRunes runes,
+// treating the content as markup for pango segments. The short text, if
+// set, is truncated too.
+func MaxWidth(seg *bar.Segment, maxRunes int, mode TruncateMode) *bar.Segment {
+	if text, isPango := seg.Content(); isPango {
+		seg.Pango(TruncatePango(text, maxRunes, mode))
+	} else {
+		seg.Text(TruncateWith(text, maxRunes, mode))
+	}
+	if short, ok := seg.GetShortText(); ok {
+		seg.ShortText(TruncateWith(short, maxRunes, mode))
+	}
+	return seg
+}
+
+// truncToken is a piece of text, which counts as one rune if visible.
+type truncToken struct {
+	text    string
+	visible bool
+}
+
+func truncate(tokens []truncToken, maxRunes int, mode TruncateMode) string {
+	count := 0
+	for _, t := range tokens {
+		if t.visible {
+			count++
+		}
+	}
+	var out strings.Builder
+	if count <= maxRunes {
+		for _, t := range tokens {
+			out.WriteString(t.text)
+		}
+		return out.String()
+	}
+	// Keep visible runes in [0, head) and [count-tail, count), leaving room
+	// for the ellipsis.
+	keep := maxRunes - 1
+	if keep < 0 {
+		keep = 0
+	}
+	var head, tail int
+	switch mode {
+	case TruncateStart:
+		tail = keep
+	case TruncateMiddle:
+		tail = keep / 2
+		head = keep - tail
+	default:
+		head = keep
+	}
+	idx := 0
+	wroteEllipsis := maxRunes < 1
+	for _, t := range tokens {
+		if !t.visible {
+			out.WriteString(t.text)
+			continue
+		}
+		if idx < head || idx >= count-tail {
+			out.WriteString(t.text)
+		} else if !wroteEllipsis {
+			out.WriteString(ellipsis)
+			wroteEllipsis = true
+		}
+		idx++
+	}
+	return out.String()
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	require := require.New(t)
+	require.Equal("short", Truncate("short", 5))
+	require.Equal("some…", Truncate("some long text", 5))
+	require.Equal("héllo…", Truncate("héllo wörld", 6), "counts runes, not bytes")
+	require.Equal("…", Truncate("text", 1))
+	require.Equal("", Truncate("text", 0))
+	require.Equal("…/file.go", TruncateWith("/path/to/file.go", 9, TruncateStart))
+	require.Equal("/pat….go", TruncateWith("/path/to/file.go", 8, TruncateMiddle))
+	require.Equal("/pat…e.go", TruncateWith("/path/to/file.go", 9, TruncateMiddle))
+}
+
+func TestTruncatePango(t *testing.T) {
+	require := require.New(t)
+	markup := `<span color="red">error</span>: a &amp; b`
+	require.Equal(markup, TruncatePango(markup, 12, TruncateEnd))
+	require.Equal(`<span color="red">error</span>: …`,
+		TruncatePango(markup, 8, TruncateEnd), "keeps tags")
+	require.Equal(`<span color="red">…</span> &amp; b`,
+		TruncatePango(markup, 5, TruncateStart), "entities count as one rune")
+	require.Equal(`<span color="red">er…</span>b`,
+		TruncatePango(markup, 4, TruncateMiddle))
+	require.Equal("a < b", TruncatePango("a < b", 5, TruncateEnd),
+		"tolerates invalid markup")
+}
+
+func TestMaxWidth(t *testing.T) {
+	seg := MaxWidth(Text("some long text").ShortText("long text"), 6, TruncateEnd)
+	txt, isPango := seg.Content()
+	require.False(t, isPango)
+	require.Equal(t, "some …", txt)
+	short, _ := seg.GetShortText()
+	require.Equal(t, "long …", short)
+
+	seg = MaxWidth(Pango("text ", "<>"), 6, TruncateStart)
+	txt, isPango = seg.Content()
+	require.True(t, isPango)
+	require.Equal(t, "…xt &lt;&gt;", txt)
+}
@@ -89,6 +89,11 @@ var stringifyingTests = []struct {
 		Text("<b color='red'>bold</b>").Oblique(),
 		"<span style='oblique'>&lt;b color=&#39;red&#39;&gt;bold&lt;/b&gt;</span>",
 	},
+	{
+		"attribute with special characters",
+		Text("x").Font(`a'b"<c>&`),
+		"<span face='a&#39;b&#34;&lt;c&gt;&amp;'>x</span>",
+	},
 
 	{
 		"unnamed tag collapsing",
@@ -37,6 +37,8 @@ type TestBar struct {
 	require.TestingT
 	moduleSet *core.ModuleSet
 	outputs   chan testOutput
+	clicks    []Click
+	clicksMu  sync.Mutex
 }
 
 var instance atomic.Value // of TestBar
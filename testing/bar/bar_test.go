@@ -15,6 +15,7 @@
 package bar
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -261,3 +262,77 @@ func TestSegmentErrors(t *testing.T) {
 		NextOutput().At(2).Segment()
 	}, "out of range segment")
 }
+
+func TestSendEvent(t *testing.T) {
+	New(t)
+	m1 := module.New(t)
+	m2 := module.New(t)
+	m3 := module.New(t).SkipClickHandlers()
+	Run(m1, m2, m3)
+
+	m1.AssertStarted()
+	m2.AssertStarted()
+	m3.AssertStarted()
+
+	m1.OutputText("1")
+	m2.Output(outputs.Group(outputs.Text("a"), outputs.Text("b")))
+	clicks := 0
+	m3.Output(outputs.Group(
+		outputs.Text("no-click"),
+		outputs.Textf("clicks: %d", clicks).OnClick(func(bar.Event) {
+			clicks++
+			m3.Output(outputs.Textf("clicks: %d", clicks))
+		}),
+	))
+	LatestOutput().AssertText([]string{"1", "a", "b", "no-click", "clicks: 0"})
+
+	e := bar.Event{Button: bar.ButtonRight, X: 5, Modifiers: []string{"Shift"}}
+	require.True(t, SendEvent(2, e), "event handled")
+	c := AssertHandled(1, "routed to second module")
+	require.Equal(t, 1, c.Segment, "routed to second segment of module")
+	require.Equal(t, e, c.Event)
+	require.Equal(t, e, m2.AssertClicked("second module clicked"))
+	m1.AssertNotClicked("with event for a different module")
+
+	require.False(t, SendClick(3), "segment without click handler")
+	AssertNotHandled("segment without click handler")
+
+	require.True(t, SendClick(4), "segment with click handler")
+	AssertHandled(2, "custom click handler")
+	NextOutput().AssertText([]string{"1", "a", "b", "clicks: 1"},
+		"output from click handler")
+	AssertNotHandled("after all clicks asserted")
+
+	m1.Output(outputs.Error(errors.New("foo")))
+	NextOutput().At(0).AssertError("on error output")
+	require.True(t, SendEvent(0, bar.Event{Button: bar.ButtonRight}),
+		"error segment handles right clicks")
+	AssertHandled(0, "error handler")
+	m1.AssertNotClicked("right click on error goes to error handler")
+}
+
+func TestEventErrors(t *testing.T) {
+	assertFails(t, func(m *module.TestModule) {
+		m.OutputText("test")
+		NextOutput()
+		SendClick(1)
+	}, "Clicking segment out of range")
+
+	assertFails(t, func(*module.TestModule) {
+		AssertHandled(0)
+	}, "Asserting handled when no clicks are pending")
+
+	assertFails(t, func(m *module.TestModule) {
+		m.OutputText("test")
+		NextOutput()
+		SendClick(0)
+		AssertNotHandled()
+	}, "Asserting not handled when a click is pending")
+
+	assertFails(t, func(m *module.TestModule) {
+		m.OutputText("test")
+		NextOutput()
+		SendClick(0)
+		AssertHandled(1)
+	}, "Asserting handled by the wrong module")
+}
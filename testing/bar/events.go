@@ -0,0 +1,99 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"barista.run/bar"
+	"barista.run/core"
+	l "barista.run/logging"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Click represents an invocation of a segment's click handler,
+// caused by an event sent using SendEvent.
+type Click struct {
+	// Module is the index of the module that handled the event.
+	Module int
+	// Segment is the index of the segment within the module's output.
+	Segment int
+	// Event is the event passed to the click handler.
+	Event bar.Event
+}
+
+// SendEvent simulates i3bar sending a click event for the segment at the
+// given index in the bar's current output (using the same indices as
+// LatestOutput().At(i)). The event is routed the same way as in the real bar,
+// using the name that the bar assigns to each segment that handles clicks.
+// Right-clicks on error segments go to the bar's error handler, which does
+// nothing in tests. SendEvent waits for the handler to return, and returns
+// false if the segment does not handle clicks.
+func SendEvent(index int, e bar.Event) bool {
+	t := instance.Load().(*TestBar)
+	outputs := t.moduleSet.LastOutputs()
+	names, handlers := core.ClickHandlers(outputs, func(bar.ErrorEvent) {})
+	if index < 0 || index >= len(names) {
+		require.Fail(t, "Segment out of range", "no segment at #%d", index)
+		return false
+	}
+	handler, ok := handlers[names[index]]
+	if !ok {
+		l.Fine("%s event for #%d ignored, no click handler",
+			l.ID(t.moduleSet), index)
+		return false
+	}
+	modIdx, idx := 0, index
+	for idx >= len(outputs[modIdx]) {
+		idx -= len(outputs[modIdx])
+		modIdx++
+	}
+	handler(e)
+	t.clicksMu.Lock()
+	t.clicks = append(t.clicks, Click{Module: modIdx, Segment: idx, Event: e})
+	t.clicksMu.Unlock()
+	return true
+}
+
+// SendClick is a convenience wrapper around SendEvent for a left click.
+func SendClick(index int) bool {
+	return SendEvent(index, bar.Event{Button: bar.ButtonLeft})
+}
+
+// AssertHandled asserts that the earliest unasserted event sent to the bar
+// was handled by the given module, and returns details of the invocation.
+func AssertHandled(module int, args ...interface{}) Click {
+	t := instance.Load().(*TestBar)
+	t.clicksMu.Lock()
+	defer t.clicksMu.Unlock()
+	if len(t.clicks) == 0 {
+		require.Fail(t, "Expected a click handler to have run", args...)
+		return Click{}
+	}
+	c := t.clicks[0]
+	t.clicks = t.clicks[1:]
+	require.Equal(t, module, c.Module, args...)
+	return c
+}
+
+// AssertNotHandled asserts that no click handlers were run for events sent
+// to the bar, other than those already checked by AssertHandled.
+func AssertNotHandled(args ...interface{}) {
+	t := instance.Load().(*TestBar)
+	t.clicksMu.Lock()
+	defer t.clicksMu.Unlock()
+	if len(t.clicks) > 0 {
+		require.Fail(t, "Expected no click handlers to have run", args...)
+	}
+}
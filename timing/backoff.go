@@ -0,0 +1,125 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	l "barista.run/logging"
+)
+
+// BackoffScheduler triggers after a delay that grows exponentially on
+// repeated failures, and resets on success. It is useful for polling
+// resources that may be temporarily unavailable, e.g.
+//
+//	for range sch.Tick() {
+//	    if err := update(); err != nil {
+//	        sch.Failure()
+//	    } else {
+//	        sch.Success()
+//	    }
+//	}
+//
+// Each call to Success or Failure schedules the next trigger, so consumers
+// must call exactly one of them after each tick.
+type BackoffScheduler struct {
+	sch Scheduler
+
+	mu       sync.Mutex
+	min, max time.Duration
+	jitter   float64
+	interval time.Duration
+}
+
+// randFloat is swappable in tests to control jitter.
+var randFloat = rand.Float64
+
+// NewBackoffScheduler creates a scheduler whose delay starts at min, and
+// doubles on each failure up to max. The first trigger is after min.
+func NewBackoffScheduler(min, max time.Duration) *BackoffScheduler {
+	if max < min {
+		max = min
+	}
+	b := &BackoffScheduler{sch: NewScheduler(), min: min, max: max, interval: min}
+	l.Attach(b, b.sch, "")
+	b.sch.After(min)
+	return b
+}
+
+// Jitter randomises each delay by up to ±fraction of the interval, to
+// avoid synchronised polling. Fractions outside [0,1] are clamped.
+func (b *BackoffScheduler) Jitter(fraction float64) *BackoffScheduler {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jitter = fraction
+	return b
+}
+
+// Tick returns a channel that receives an empty value
+// when the scheduler is triggered.
+func (b *BackoffScheduler) Tick() <-chan struct{} {
+	return b.sch.Tick()
+}
+
+// Success resets the interval to the minimum, and schedules the next
+// trigger.
+func (b *BackoffScheduler) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.interval = b.min
+	b.schedule()
+}
+
+// Failure doubles the interval, up to the maximum, and schedules the next
+// trigger.
+func (b *BackoffScheduler) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.interval *= 2; b.interval > b.max || b.interval <= 0 {
+		b.interval = b.max
+	}
+	b.schedule()
+}
+
+// Interval returns the current interval, without jitter.
+func (b *BackoffScheduler) Interval() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.interval
+}
+
+// Stop cancels all further triggers, until the next call to Success or
+// Failure.
+func (b *BackoffScheduler) Stop() {
+	b.sch.Stop()
+}
+
+// schedule sets the next trigger. Must be called with the lock held.
+func (b *BackoffScheduler) schedule() {
+	delay := b.interval
+	if b.jitter > 0 {
+		delay += time.Duration(float64(delay) * b.jitter * (2*randFloat() - 1))
+	}
+	l.Fine("%s next trigger in %v", l.ID(b), delay)
+	b.sch.After(delay)
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffScheduler(t *testing.T) {
+	TestMode()
+	start := Now()
+	sch := NewBackoffScheduler(time.Second, 10*time.Second)
+	require.Equal(t, start.Add(time.Second), NextTick(), "first trigger after min")
+	assertTriggered(t, sch)
+
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		sch.Failure()
+		then := Now()
+		delays = append(delays, NextTick().Sub(then))
+		assertTriggered(t, sch)
+	}
+	require.Equal(t, []time.Duration{
+		2 * time.Second, 4 * time.Second, 8 * time.Second,
+		10 * time.Second, 10 * time.Second,
+	}, delays, "doubles up to max on failure")
+	require.Equal(t, 10*time.Second, sch.Interval())
+
+	sch.Success()
+	require.Equal(t, time.Second, sch.Interval(), "resets on success")
+	then := Now()
+	require.Equal(t, then.Add(time.Second), NextTick())
+	assertTriggered(t, sch)
+
+	then = Now()
+	require.Equal(t, then, NextTick(), "no trigger until success/failure")
+	assertNotTriggered(t, sch)
+
+	sch.Failure()
+	sch.Stop()
+	require.Equal(t, then, NextTick(), "when stopped")
+	assertNotTriggered(t, sch)
+
+	oldRand := randFloat
+	defer func() { randFloat = oldRand }()
+	sch.Jitter(0.5)
+	for r, expected := range map[float64]time.Duration{
+		0:    500 * time.Millisecond,
+		0.5:  time.Second,
+		0.75: 1250 * time.Millisecond,
+	} {
+		randFloat = func() float64 { return r }
+		sch.Success()
+		then := Now()
+		require.Equal(t, expected, NextTick().Sub(then), "jitter with rand=%v", r)
+		assertTriggered(t, sch)
+	}
+}
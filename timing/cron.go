@@ -0,0 +1,204 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the trigger times for Scheduler.On.
+type Schedule interface {
+	// Next returns the first trigger time strictly after the given time,
+	// or the zero time if there are no further triggers.
+	Next(after time.Time) time.Time
+}
+
+// cron is a Schedule parsed from a cron expression. Each field is a bitset
+// of the allowed values.
+type cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	// If either day field is a wildcard, both must match. Otherwise, a day
+	// matches if either field matches, as in standard cron.
+	anyDay bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun",
+		"jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is also accepted for Sunday.
+	{0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule parses a standard 5-field cron expression
+// ("minute hour day-of-month month day-of-week"), and returns a Schedule
+// that can be used with Scheduler.On. Fields support lists, ranges, and
+// steps, e.g. "*/5 9-17 * * mon-fri" for every 5 minutes during working
+// hours, as well as the macros @hourly, @daily, @weekly, @monthly, and
+// @yearly. Trigger times are computed in the location of timing.Now().
+func CronSchedule(expr string) (Schedule, error) {
+	fields := expr
+	if m, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		fields = m
+	}
+	parts := strings.Fields(fields)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron: expected %d fields in %q, got %d",
+			len(cronFields), expr, len(parts))
+	}
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := cronFields[i].parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("cron: %q: %v", part, err)
+		}
+		bits[i] = b
+	}
+	// Sunday is either 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &cron{
+		expr:   expr,
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		anyDay: strings.HasPrefix(parts[2], "*") || strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func (f cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rng, step, hasStep := item, 1, false
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			hasStep = true
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", item[i+1:])
+			}
+			rng = item[:i]
+		}
+		lo, hi := f.min, f.max
+		switch i := strings.IndexByte(rng, '-'); {
+		case rng == "*":
+		case i >= 0:
+			var err error
+			if lo, err = f.value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			// "a/n" is shorthand for "a-max/n".
+			if !hasStep {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (c *cron) String() string {
+	return c.expr
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	if c.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next implements Schedule.
+func (c *cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	y, mo, d := after.Date()
+	t := time.Date(y, mo, d, after.Hour(), after.Minute()+1, 0, 0, loc)
+	// Expressions like "0 0 30 2 *" never match, so give up eventually.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, mo, d := t.Date()
+		var next time.Time
+		switch {
+		case !has(c.month, int(mo)):
+			next = time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			next = time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			next = time.Date(y, mo, d, t.Hour()+1, 0, 0, 0, loc)
+		case !has(c.minute, t.Minute()):
+			next = time.Date(y, mo, d, t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+		// Around daylight saving transitions, the wall-clock time may
+		// resolve to an earlier instant, so make sure to always progress.
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
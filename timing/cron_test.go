@@ -0,0 +1,122 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Friday.
+	start := time.Date(2016, time.November, 25, 20, 47, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		expr     string
+		expected []string
+	}{
+		{"* * * * *", []string{"2016-11-25 20:48", "2016-11-25 20:49"}},
+		{"*/15 * * * *", []string{"2016-11-25 21:00", "2016-11-25 21:15"}},
+		{"5,10 9-17/4 * * *", []string{
+			"2016-11-26 09:05", "2016-11-26 09:10", "2016-11-26 13:05"}},
+		{"*/5 9-17 * * mon-fri", []string{"2016-11-28 09:00", "2016-11-28 09:05"}},
+		{"0 0 * * 7", []string{"2016-11-27 00:00", "2016-12-04 00:00"}},
+		{"30 12 1 jan,JUL *", []string{"2017-01-01 12:30", "2017-07-01 12:30"}},
+		{"0 0 13 * fri", []string{"2016-12-02 00:00", "2016-12-09 00:00"}},
+		{"0 0 29 2 *", []string{"2020-02-29 00:00", "2024-02-29 00:00"}},
+		{"50/5 * * * *", []string{"2016-11-25 20:50", "2016-11-25 20:55", "2016-11-25 21:50"}},
+		{"@daily", []string{"2016-11-26 00:00", "2016-11-27 00:00"}},
+		{"@hourly", []string{"2016-11-25 21:00", "2016-11-25 22:00"}},
+	} {
+		sched, err := CronSchedule(tc.expr)
+		require.NoError(t, err, tc.expr)
+		now := start
+		for _, e := range tc.expected {
+			now = sched.Next(now)
+			require.Equal(t, e, now.Format("2006-01-02 15:04"), tc.expr)
+		}
+	}
+
+	never, err := CronSchedule("0 0 30 2 *")
+	require.NoError(t, err)
+	require.True(t, never.Next(start).IsZero(), "impossible date")
+
+	loc := time.FixedZone("IST", 5*3600+30*60)
+	sched, _ := CronSchedule("0 * * * *")
+	next := sched.Next(start.In(loc))
+	require.Equal(t, "2016-11-26 03:00", next.Format("2006-01-02 15:04"),
+		"uses location of given time")
+}
+
+func TestCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@reboot",
+	} {
+		_, err := CronSchedule(expr)
+		require.Error(t, err, "for %q", expr)
+	}
+}
+
+func TestCron_TestMode(t *testing.T) {
+	TestMode()
+	sch := NewScheduler()
+	sched, err := CronSchedule("0 21-22 * * *")
+	require.NoError(t, err)
+	sch.On(sched)
+
+	require.Equal(t, "21:00", NextTick().Format("15:04"))
+	assertTriggered(t, sch, "on first cron trigger")
+	require.Equal(t, "22:00", NextTick().Format("15:04"))
+	assertTriggered(t, sch, "on second cron trigger")
+	require.Equal(t, "2016-11-26 21:00", NextTick().Format("2006-01-02 15:04"))
+	assertTriggered(t, sch, "on next day")
+
+	sch.After(time.Minute)
+	then := Now()
+	require.Equal(t, then.Add(time.Minute), NextTick())
+	assertTriggered(t, sch)
+	require.Equal(t, then.Add(time.Minute), NextTick(), "After replaces cron")
+	assertNotTriggered(t, sch)
+}
+
+func TestOn(t *testing.T) {
+	ExitTestMode()
+	sch := NewScheduler()
+	sch.On(every50ms{})
+	assertTriggered(t, sch, "on first trigger")
+	assertTriggered(t, sch, "on second trigger")
+	sch.Stop()
+	assertNotTriggered(t, sch, "when stopped")
+}
+
+// every50ms is a Schedule that triggers on multiples of 50ms.
+type every50ms struct{}
+
+func (every50ms) Next(after time.Time) time.Time {
+	return after.Truncate(50 * time.Millisecond).Add(50 * time.Millisecond)
+}
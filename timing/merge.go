@@ -0,0 +1,116 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync"
+
+	l "barista.run/logging"
+)
+
+// Merged combines the ticks of several schedulers into a single channel, for
+// modules that refresh on more than one schedule. Ticks that occur together
+// are coalesced, in the same way as repeated triggers of a single scheduler.
+type Merged struct {
+	schedulers []Scheduler
+
+	notifyFn func()
+	notifyCh <-chan struct{}
+
+	quitter  chan struct{}
+	stopOnce sync.Once
+}
+
+// redirector is implemented by schedulers from this package, which can
+// deliver their ticks directly to a merged channel.
+type redirector interface {
+	redirect(fn func(), ch <-chan struct{})
+}
+
+// Merge returns a Merged that ticks whenever any of the given schedulers
+// ticks. Schedulers created by this package deliver their ticks directly to
+// the merged channel, so their own Tick channels will no longer receive any
+// values; other implementations are forwarded from their Tick channels.
+func Merge(schedulers ...Scheduler) *Merged {
+	ch := make(chan struct{}, 1)
+	m := &Merged{
+		schedulers: schedulers,
+		notifyCh:   ch,
+		quitter:    make(chan struct{}),
+	}
+	m.notifyFn = func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	for _, s := range schedulers {
+		if r, ok := s.(redirector); ok {
+			r.redirect(m.notifyFn, m.notifyCh)
+		} else {
+			go m.forward(s)
+		}
+	}
+	return m
+}
+
+// forward sends the ticks of a scheduler to the merged channel until the
+// Merged is stopped.
+func (m *Merged) forward(s Scheduler) {
+	for {
+		select {
+		case <-s.Tick():
+			m.notifyFn()
+		case <-m.quitter:
+			return
+		}
+	}
+}
+
+// Tick returns a channel that receives an empty value when any of the
+// schedulers is triggered.
+func (m *Merged) Tick() <-chan struct{} {
+	return m.notifyCh
+}
+
+// Trigger delivers a tick immediately, independent of the schedulers, e.g.
+// to refresh a module on demand. Like the schedulers' ticks, it is coalesced
+// with any tick that has not yet been received.
+func (m *Merged) Trigger() {
+	m.notifyFn()
+}
+
+// Pause pauses all of the schedulers. See Scheduler.Pause.
+func (m *Merged) Pause() {
+	for _, s := range m.schedulers {
+		s.Pause()
+	}
+}
+
+// Resume resumes all of the schedulers. See Scheduler.Resume.
+func (m *Merged) Resume() {
+	for _, s := range m.schedulers {
+		s.Resume()
+	}
+}
+
+// Stop cancels all further triggers for all of the schedulers.
+func (m *Merged) Stop() {
+	l.Fine("%s Stop", l.ID(m))
+	for _, s := range m.schedulers {
+		s.Stop()
+	}
+	m.stopOnce.Do(func() { close(m.quitter) })
+}
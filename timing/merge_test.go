@@ -0,0 +1,93 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// wrapped hides the implementation of a scheduler, to test forwarding.
+type wrapped struct{ Scheduler }
+
+func TestMerge(t *testing.T) {
+	TestMode()
+	a := NewScheduler()
+	b := NewScheduler()
+	c := wrapped{NewScheduler()}
+	m := Merge(a, b, c)
+	assertNotTriggered(t, m, "when not scheduled")
+
+	a.Every(time.Minute)
+	b.After(30 * time.Second)
+	NextTick()
+	assertTriggered(t, m, "first scheduler")
+	NextTick()
+	assertTriggered(t, m, "second scheduler")
+	assertNotTriggered(t, a, "merged schedulers do not tick separately")
+
+	b.At(Now().Add(time.Minute))
+	NextTick()
+	assertTriggered(t, m, "simultaneous ticks")
+	assertNotTriggered(t, m, "are coalesced")
+
+	c.After(time.Second)
+	NextTick()
+	assertTriggered(t, m, "forwarded scheduler")
+
+	m.Trigger()
+	m.Trigger()
+	assertTriggered(t, m, "on trigger")
+	assertNotTriggered(t, m, "triggers are coalesced")
+
+	m.Pause()
+	AdvanceBy(time.Hour)
+	assertNotTriggered(t, m, "while paused")
+	m.Resume()
+	assertTriggered(t, m, "catch-up tick when resumed")
+
+	a.After(time.Second)
+	c.After(time.Second)
+	m.Stop()
+	m.Stop()
+	AdvanceBy(time.Hour)
+	assertNotTriggered(t, m, "when stopped")
+	require.Equal(t, time.Time{}, NewTestClock().NextTickTime(),
+		"stops all underlying schedulers")
+}
+
+func TestMergeWithTestClock(t *testing.T) {
+	clock := NewTestClock()
+	a := NewScheduler().Every(time.Second)
+	b := NewScheduler().Every(3 * time.Second)
+	m := Merge(a, b)
+
+	start := clock.Now()
+	received := make(chan time.Duration)
+	go func() {
+		for range m.Tick() {
+			received <- Now().Sub(start)
+		}
+	}()
+	var ticks []time.Duration
+	clock.OnTick(func(time.Time) { ticks = append(ticks, <-received) })
+	clock.Advance(4 * time.Second)
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second,
+	}, ticks, "one tick per merged tick time, coalescing simultaneous ticks")
+	m.Stop()
+}
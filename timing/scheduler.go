@@ -16,11 +16,11 @@ package timing
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"barista.run/base/notifier"
 	l "barista.run/logging"
 )
 
@@ -32,10 +32,27 @@ type scheduler struct {
 	ticker  *time.Ticker
 	quitter chan struct{}
 
+	// notifyFn and notifyCh can be replaced by Merge, so they are guarded
+	// by notifyMu rather than the main lock.
+	notifyMu sync.Mutex
 	notifyFn func()
 	notifyCh <-chan struct{}
 
 	waiting int32 // basically bool, but we need atomics.
+
+	// paused and missed track Pause/Resume for this scheduler. They use a
+	// separate lock since triggers may fire with the main lock held.
+	pauseMu sync.Mutex
+	paused  bool
+	missed  bool
+
+	// Statistics, updated atomically on each trigger. Times are unix nanos,
+	// from the clock in effect when the scheduler was created.
+	now        func() time.Time
+	fired      int64
+	firstFired int64
+	lastFired  int64
+	interval   int64
 }
 
 var (
@@ -47,19 +64,69 @@ var (
 	testMode = false
 
 	mu sync.Mutex
+
+	// Schedulers that have been scheduled and not stopped since, for
+	// AllStats. Stopped schedulers are removed, so that schedulers that are
+	// no longer in use (e.g. from a module that was restarted) don't pile up.
+	schedulers   = map[*scheduler]Scheduler{}
+	schedulersMu sync.Mutex
 )
 
 // NewScheduler creates a new scheduler.
 func NewScheduler() Scheduler {
-	fn, ch := notifier.New()
-	s := &scheduler{notifyFn: fn, notifyCh: ch}
+	// Equivalent to notifier.New, which can't be used here since the
+	// notifier package depends on timing for debouncing.
+	ch := make(chan struct{}, 1)
+	fn := func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	s := &scheduler{notifyFn: fn, notifyCh: ch, now: time.Now}
 	l.Attach(s, ch, "")
+	var sch Scheduler = s
+	mu.Lock()
+	defer mu.Unlock()
 	if testMode {
+		s.now = testNow
 		t := &testScheduler{scheduler: s}
 		l.Attach(t, s, "")
-		return t
+		sch = t
 	}
-	return s
+	return sch
+}
+
+// track adds a scheduler to the set reported by AllStats.
+func track(s *scheduler, sch Scheduler) {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	schedulers[s] = sch
+}
+
+// untrack removes a stopped scheduler from the set reported by AllStats.
+func untrack(s *scheduler) {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	delete(schedulers, s)
+}
+
+// AllStats returns the statistics of all schedulers that have been scheduled
+// and not stopped since, most frequently triggered first.
+func AllStats() []Stats {
+	schedulersMu.Lock()
+	all := make([]Stats, 0, len(schedulers))
+	for _, s := range schedulers {
+		all = append(all, s.Stats())
+	}
+	schedulersMu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Fired != all[j].Fired {
+			return all[i].Fired > all[j].Fired
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
 }
 
 // Pause timing.
@@ -101,23 +168,107 @@ func Resume() {
 // Tick returns a channel that receives an empty value
 // when the scheduler is triggered.
 func (s *scheduler) Tick() <-chan struct{} {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
 	return s.notifyCh
 }
 
+// notify delivers a tick to the scheduler's channel.
+func (s *scheduler) notify() {
+	s.notifyMu.Lock()
+	fn := s.notifyFn
+	s.notifyMu.Unlock()
+	fn()
+}
+
+// redirect replaces the channel that receives the scheduler's ticks,
+// for Merge.
+func (s *scheduler) redirect(fn func(), ch <-chan struct{}) {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+	s.notifyFn, s.notifyCh = fn, ch
+}
+
 func (s *scheduler) At(when time.Time) Scheduler {
 	l.Fine("%s At(%v)", l.ID(s), when)
+	track(s, s)
 	s.Lock()
 	defer s.Unlock()
 	s.stop()
-	s.timer = time.AfterFunc(when.Sub(Now()), s.maybeTrigger)
+	atomic.StoreInt64(&s.interval, 0)
+	// Strip the monotonic reading, so that the remaining time is computed
+	// from the wall clock, and follows any adjustments to it.
+	s.armAt(when.Round(0), s.maybeTrigger)
 	return s
 }
 
+func (s *scheduler) On(sched Schedule) Scheduler {
+	l.Fine("%s On(%v)", l.ID(s), sched)
+	track(s, s)
+	s.Lock()
+	defer s.Unlock()
+	s.stop()
+	atomic.StoreInt64(&s.interval, 0)
+	s.armSchedule(sched)
+	return s
+}
+
+// armSchedule sets a timer for the next trigger of the schedule, if any.
+// Must be called with the lock held.
+func (s *scheduler) armSchedule(sched Schedule) {
+	next := sched.Next(Now())
+	if next.IsZero() {
+		return
+	}
+	s.armAt(next.Round(0), func() {
+		s.maybeTrigger()
+		s.armSchedule(sched)
+	})
+}
+
+// wallClockCheck is the maximum time between checks of the wall clock while
+// waiting for an At trigger, which bounds the delay in noticing the clock
+// being set forward.
+var wallClockCheck = 10 * time.Second
+
+// afterFunc starts the timers for At triggers, and can be replaced in tests to
+// run them on demand.
+var afterFunc = time.AfterFunc
+
+// armAt starts a timer to call fire, with the lock held, at the given
+// wall-clock time. Since timers run on the monotonic clock, it wakes up at
+// least every wallClockCheck, and when the timer expires, to re-align with
+// the wall clock before firing.
+// Must be called with the lock held.
+func (s *scheduler) armAt(when time.Time, fire func()) {
+	delay := when.Sub(Now().Round(0))
+	if delay > wallClockCheck {
+		delay = wallClockCheck
+	}
+	var timer *time.Timer
+	timer = afterFunc(delay, func() {
+		s.Lock()
+		defer s.Unlock()
+		if s.timer != timer {
+			return
+		}
+		if when.After(Now().Round(0)) {
+			s.armAt(when, fire)
+			return
+		}
+		s.timer = nil
+		fire()
+	})
+	s.timer = timer
+}
+
 func (s *scheduler) After(delay time.Duration) Scheduler {
 	l.Fine("%s After(%v)", l.ID(s), delay)
+	track(s, s)
 	s.Lock()
 	defer s.Unlock()
 	s.stop()
+	atomic.StoreInt64(&s.interval, 0)
 	s.timer = time.AfterFunc(delay, s.maybeTrigger)
 	return s
 }
@@ -127,9 +278,11 @@ func (s *scheduler) Every(interval time.Duration) Scheduler {
 	if interval <= 0 {
 		panic(errors.New("non-positive interval for Scheduler#Every"))
 	}
+	track(s, s)
 	s.Lock()
 	defer s.Unlock()
 	s.stop()
+	atomic.StoreInt64(&s.interval, int64(interval))
 	s.quitter = make(chan struct{})
 	s.ticker = time.NewTicker(interval)
 	go func() {
@@ -153,25 +306,88 @@ func (s *scheduler) Every(interval time.Duration) Scheduler {
 	return s
 }
 
+func (s *scheduler) Stats() Stats {
+	stats := Stats{
+		Name:     l.ID(s),
+		Fired:    atomic.LoadInt64(&s.fired),
+		Interval: time.Duration(atomic.LoadInt64(&s.interval)),
+	}
+	if stats.Fired == 0 {
+		return stats
+	}
+	last := atomic.LoadInt64(&s.lastFired)
+	stats.LastFired = time.Unix(0, last)
+	if stats.Fired > 1 {
+		first := atomic.LoadInt64(&s.firstFired)
+		stats.MeanInterval = time.Duration((last - first) / (stats.Fired - 1))
+	}
+	return stats
+}
+
 func (s *scheduler) Stop() {
 	l.Fine("%s Stop", l.ID(s))
+	untrack(s)
 	s.Lock()
 	defer s.Unlock()
 	s.stop()
 }
 
+func (s *scheduler) Pause() {
+	l.Fine("%s Pause", l.ID(s))
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	s.paused = true
+}
+
+func (s *scheduler) Resume() {
+	l.Fine("%s Resume", l.ID(s))
+	s.pauseMu.Lock()
+	missed := s.missed
+	s.paused, s.missed = false, false
+	s.pauseMu.Unlock()
+	if missed {
+		s.maybeTrigger()
+	}
+}
+
 func (s *scheduler) maybeTrigger() {
+	s.pauseMu.Lock()
+	if s.paused {
+		s.missed = true
+		s.pauseMu.Unlock()
+		return
+	}
+	s.pauseMu.Unlock()
 	if !atomic.CompareAndSwapInt32(&s.waiting, 0, 1) {
 		return
 	}
 	await(func() {
 		if atomic.CompareAndSwapInt32(&s.waiting, 1, 0) {
-			s.notifyFn()
+			s.recordTrigger()
+			s.notify()
 		}
 	})
 }
 
+// recordTrigger updates the statistics when the scheduler is triggered.
+func (s *scheduler) recordTrigger() {
+	now := s.now().UnixNano()
+	if atomic.AddInt64(&s.fired, 1) == 1 {
+		atomic.StoreInt64(&s.firstFired, now)
+	}
+	atomic.StoreInt64(&s.lastFired, now)
+}
+
+// clearMissed discards any tick missed while paused, when the schedule is
+// changed or stopped.
+func (s *scheduler) clearMissed() {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	s.missed = false
+}
+
 func (s *scheduler) stop() {
+	s.clearMissed()
 	if s.timer != nil {
 		s.timer.Stop()
 		s.timer = nil
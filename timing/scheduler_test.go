@@ -21,7 +21,10 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func assertTriggered(t *testing.T, s Scheduler, msgAndArgs ...interface{}) {
+// ticker is implemented by Scheduler and BackoffScheduler.
+type ticker interface{ Tick() <-chan struct{} }
+
+func assertTriggered(t *testing.T, s ticker, msgAndArgs ...interface{}) {
 	select {
 	case <-s.Tick():
 	case <-time.After(time.Second):
@@ -29,7 +32,7 @@ func assertTriggered(t *testing.T, s Scheduler, msgAndArgs ...interface{}) {
 	}
 }
 
-func assertNotTriggered(t *testing.T, s Scheduler, msgAndArgs ...interface{}) {
+func assertNotTriggered(t *testing.T, s ticker, msgAndArgs ...interface{}) {
 	select {
 	case <-s.Tick():
 		require.Fail(t, "scheduler was triggered", msgAndArgs...)
@@ -81,6 +84,24 @@ func TestPauseResume(t *testing.T) {
 	assertNotTriggered(t, sch, "repeated resume is nop")
 }
 
+func TestSchedulerPause(t *testing.T) {
+	ExitTestMode()
+	sch := NewScheduler()
+	sch.Pause()
+	sch.After(2 * time.Millisecond)
+	assertNotTriggered(t, sch, "while paused")
+	sch.Resume()
+	assertTriggered(t, sch, "when resumed")
+
+	sch.Pause()
+	sch.Every(2 * time.Millisecond)
+	assertNotTriggered(t, sch, "while paused")
+	sch.Resume()
+	assertTriggered(t, sch, "catch-up tick when resumed")
+	assertTriggered(t, sch, "continues after resuming")
+	sch.Stop()
+}
+
 func TestRepeating(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping real repeating test in short mode")
@@ -147,3 +168,49 @@ func TestPastTriggers(t *testing.T) {
 		sch.Every(-1 * time.Second)
 	}, "negative repeating interval")
 }
+
+func TestAtClockJump(t *testing.T) {
+	ExitTestMode()
+	start := time.Date(2016, time.November, 25, 20, 47, 0, 0, time.UTC)
+	nowInTest.Store(start)
+	Now = testNow
+	// Capture the timers instead of starting them, so that the test can
+	// move the clock and expire them on demand.
+	var delays []time.Duration
+	var expire func()
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		delays = append(delays, d)
+		expire = f
+		return time.NewTimer(time.Hour)
+	}
+	defer func() {
+		Now = time.Now
+		afterFunc = time.AfterFunc
+	}()
+
+	sch := NewScheduler()
+	sch.At(start.Add(time.Hour))
+	require.Equal(t, []time.Duration{wallClockCheck}, delays,
+		"checks the wall clock before a distant trigger time")
+	nowInTest.Store(start.Add(time.Hour))
+	expire()
+	assertTriggered(t, sch, "when the clock is set past the trigger time")
+
+	delays = nil
+	sch.At(start.Add(time.Hour + time.Second))
+	nowInTest.Store(start)
+	expire()
+	assertNotTriggered(t, sch, "when the clock is set back")
+	require.Equal(t, []time.Duration{time.Second, wallClockCheck}, delays,
+		"re-aligned with the clock that was set back")
+	nowInTest.Store(start.Add(time.Hour + time.Second))
+	expire()
+	assertTriggered(t, sch, "when the clock catches up")
+
+	sch.At(start.Add(2 * time.Hour))
+	stopped := expire
+	sch.Stop()
+	nowInTest.Store(start.Add(3 * time.Hour))
+	stopped()
+	assertNotTriggered(t, sch, "when stopped")
+}
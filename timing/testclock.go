@@ -0,0 +1,125 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import "time"
+
+// tickReceiveTimeout is how long TestClock waits for a tick to be received
+// before moving on, in case nothing is reading from the scheduler.
+var tickReceiveTimeout = time.Second
+
+// TestClock steps the test clock in fine increments, for testing code that
+// depends on both timing.Now() and scheduler ticks, e.g. rates computed over
+// sampling intervals. Unlike AdvanceBy, which moves the clock to the target
+// time and coalesces repeated ticks of the same scheduler, TestClock moves the
+// clock to each tick in turn, and waits for the tick to be received before
+// moving on.
+type TestClock struct {
+	onTick func(time.Time)
+}
+
+// NewTestClock returns a TestClock, entering test mode if needed. Schedulers
+// must be created in test mode to be controlled by the clock.
+func NewTestClock() *TestClock {
+	mu.Lock()
+	inTestMode := testMode
+	mu.Unlock()
+	if !inTestMode {
+		TestMode()
+	}
+	return &TestClock{}
+}
+
+// OnTick sets a function called at the time of each tick while advancing the
+// clock, after the tick has been received, e.g. to wait for the output
+// computed on each tick before the clock moves on.
+func (c *TestClock) OnTick(fn func(time.Time)) *TestClock {
+	c.onTick = fn
+	return c
+}
+
+// Now returns the current test time.
+func (c *TestClock) Now() time.Time {
+	return testNow()
+}
+
+// NextTickTime returns the time of the next tick of any scheduler, without
+// triggering it or moving the clock, or the zero time if nothing is scheduled.
+func (c *TestClock) NextTickTime() time.Time {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	if len(triggers) == 0 {
+		return time.Time{}
+	}
+	if now := testNow(); triggers[0].when.Before(now) {
+		return now
+	}
+	return triggers[0].when
+}
+
+// Advance moves the clock forward by the given duration, triggering each
+// scheduler tick in the interval in order. See AdvanceTo.
+func (c *TestClock) Advance(d time.Duration) time.Time {
+	return c.AdvanceTo(testNow().Add(d))
+}
+
+// AdvanceTo moves the clock forward to the given time. At each tick in the
+// interval, the clock is set to the time of the tick, all schedulers due at
+// that time are triggered, and the clock waits for their ticks to be received
+// (and the OnTick function to return) before moving on. Repeating schedulers
+// are triggered once for each interval that elapses.
+func (c *TestClock) AdvanceTo(t time.Time) time.Time {
+	for {
+		next := c.NextTickTime()
+		if next.IsZero() || next.After(t) {
+			break
+		}
+		fired := c.fire(next)
+		for _, s := range fired {
+			waitForReceive(s)
+		}
+		if c.onTick != nil {
+			c.onTick(next)
+		}
+	}
+	if t.After(testNow()) {
+		nowInTest.Store(t)
+	}
+	return testNow()
+}
+
+// fire triggers all schedulers due at the given time, and returns them.
+func (c *TestClock) fire(when time.Time) []*testScheduler {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	var fired []*testScheduler
+	for _, t := range triggers {
+		if t.when.After(when) {
+			break
+		}
+		fired = append(fired, t.what)
+	}
+	advanceToLocked(when)
+	return fired
+}
+
+// waitForReceive waits until the scheduler's tick has been received, or the
+// timeout expires.
+func waitForReceive(s *testScheduler) {
+	deadline := time.Now().Add(tickReceiveTimeout)
+	for len(s.Tick()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestClock(t *testing.T) {
+	ExitTestMode()
+	clock := NewTestClock()
+	start := Now()
+	require.Equal(t, start, clock.Now(), "enters test mode")
+	require.True(t, clock.NextTickTime().IsZero(), "nothing scheduled")
+
+	every := NewScheduler().Every(time.Second)
+	after := NewScheduler().After(2500 * time.Millisecond)
+	require.Equal(t, start.Add(time.Second), clock.NextTickTime())
+	require.Equal(t, start, Now(), "NextTickTime does not move the clock")
+
+	type tick struct {
+		name string
+		at   time.Duration
+	}
+	ticks := make(chan tick)
+	go func() {
+		for {
+			select {
+			case <-every.Tick():
+				ticks <- tick{"every", Now().Sub(start)}
+			case <-after.Tick():
+				ticks <- tick{"after", Now().Sub(start)}
+			}
+		}
+	}()
+	var got []tick
+	var onTick []time.Duration
+	clock.OnTick(func(now time.Time) {
+		got = append(got, <-ticks)
+		onTick = append(onTick, now.Sub(start))
+	})
+
+	require.Equal(t, start.Add(3500*time.Millisecond), clock.Advance(3500*time.Millisecond))
+	require.Equal(t, []tick{
+		{"every", time.Second},
+		{"every", 2 * time.Second},
+		{"after", 2500 * time.Millisecond},
+		{"every", 3 * time.Second},
+	}, got, "each tick is triggered in order, at its own time")
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 2500 * time.Millisecond, 3 * time.Second,
+	}, onTick)
+	require.Equal(t, start.Add(4*time.Second), clock.NextTickTime())
+
+	got = nil
+	clock.AdvanceTo(start.Add(3900 * time.Millisecond))
+	require.Empty(t, got, "no ticks in interval")
+	require.Equal(t, start.Add(3900*time.Millisecond), Now())
+
+	every.Stop()
+	clock.OnTick(nil)
+	unread := NewScheduler().After(time.Second)
+	tickReceiveTimeout = 10 * time.Millisecond
+	defer func() { tickReceiveTimeout = time.Second }()
+	clock.Advance(time.Minute)
+	assertTriggered(t, unread, "when tick is not received")
+}
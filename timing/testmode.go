@@ -26,13 +26,30 @@ import (
 
 type testScheduler struct {
 	*scheduler
-	startTime time.Time
-	interval  time.Duration
 }
 
+// trigger is a pending trigger of a test scheduler. Repeating triggers also
+// carry the start time and interval, or the schedule, used to re-arm them,
+// so that they are only accessed with triggersMu held.
 type trigger struct {
-	what *testScheduler
-	when time.Time
+	what     *testScheduler
+	when     time.Time
+	start    time.Time
+	interval time.Duration
+	schedule Schedule
+}
+
+// next returns the time of the next trigger after the given time for
+// repeating triggers, or the zero time otherwise.
+func (t trigger) next(now time.Time) time.Time {
+	switch {
+	case t.interval > 0:
+		elapsedIntervals := now.Sub(t.start) / t.interval
+		return t.start.Add(t.interval * (elapsedIntervals + 1))
+	case t.schedule != nil:
+		return t.schedule.Next(now)
+	}
+	return time.Time{}
 }
 
 type triggerList []trigger
@@ -82,11 +99,15 @@ func reset(fn func()) {
 	defer triggersMu.Unlock()
 	fn()
 	waiters = nil
+	schedulersMu.Lock()
+	schedulers = map[*scheduler]Scheduler{}
+	schedulersMu.Unlock()
 	triggers = nil
 	paused = false
 }
 
-func (s *testScheduler) setNextTrigger(when time.Time) Scheduler {
+func (s *testScheduler) setNextTrigger(next trigger) Scheduler {
+	s.clearMissed()
 	newTriggers := triggerList{}
 	triggersMu.Lock()
 	defer triggersMu.Unlock()
@@ -96,26 +117,33 @@ func (s *testScheduler) setNextTrigger(when time.Time) Scheduler {
 		}
 	}
 	triggers = newTriggers
-	if !when.IsZero() {
-		triggers = append(triggers, trigger{s, when})
+	if !next.when.IsZero() {
+		next.what = s
+		triggers = append(triggers, next)
+		track(s.scheduler, s)
+	} else {
+		untrack(s.scheduler)
 	}
 	sort.Sort(triggers)
 	return s
 }
 
-func (s *testScheduler) nextRepeatingTick() time.Time {
-	elapsedIntervals := Now().Sub(s.startTime) / s.interval
-	return s.startTime.Add(s.interval * (elapsedIntervals + 1))
-}
-
 func (s *testScheduler) At(when time.Time) Scheduler {
 	l.Fine("%s At[Test](%v)", l.ID(s), when)
-	return s.setNextTrigger(when)
+	atomic.StoreInt64(&s.scheduler.interval, 0)
+	return s.setNextTrigger(trigger{when: when})
 }
 
 func (s *testScheduler) After(delay time.Duration) Scheduler {
 	l.Fine("%s After[Test](%v)", l.ID(s), delay)
-	return s.setNextTrigger(Now().Add(delay))
+	atomic.StoreInt64(&s.scheduler.interval, 0)
+	return s.setNextTrigger(trigger{when: Now().Add(delay)})
+}
+
+func (s *testScheduler) On(sched Schedule) Scheduler {
+	l.Fine("%s On[Test](%v)", l.ID(s), sched)
+	atomic.StoreInt64(&s.scheduler.interval, 0)
+	return s.setNextTrigger(trigger{when: sched.Next(Now()), schedule: sched})
 }
 
 func (s *testScheduler) Every(interval time.Duration) Scheduler {
@@ -123,16 +151,15 @@ func (s *testScheduler) Every(interval time.Duration) Scheduler {
 	if interval <= 0 {
 		panic(errors.New("non-positive interval for Scheduler#Every"))
 	}
-	s.Lock()
-	defer s.Unlock()
-	s.startTime = Now()
-	s.interval = interval
-	return s.setNextTrigger(s.nextRepeatingTick())
+	atomic.StoreInt64(&s.scheduler.interval, int64(interval))
+	t := trigger{start: Now(), interval: interval}
+	t.when = t.next(t.start)
+	return s.setNextTrigger(t)
 }
 
 func (s *testScheduler) Stop() {
 	l.Fine("%s Stop[Test]", l.ID(s))
-	s.setNextTrigger(time.Time{})
+	s.setNextTrigger(trigger{})
 }
 
 // NextTick triggers the next scheduler and returns the trigger time.
@@ -182,8 +209,7 @@ func advanceToLocked(newTime time.Time) time.Time {
 		if triggers[i].when.After(nextTick) {
 			break
 		}
-		if t.what.interval > 0 {
-			t.when = t.what.nextRepeatingTick()
+		if t.when = t.next(nextTick); !t.when.IsZero() {
 			triggers = append(triggers, t)
 		}
 		idx = i + 1
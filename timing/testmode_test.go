@@ -221,6 +221,50 @@ func TestPauseResume_TestMode(t *testing.T) {
 	assertTriggered(t, sch2, "tick after resuming")
 }
 
+func TestSchedulerPause_TestMode(t *testing.T) {
+	TestMode()
+	sch := NewScheduler().Every(time.Minute)
+	other := NewScheduler().Every(time.Minute)
+	start := Now()
+
+	sch.Pause()
+	require.Equal(t, start.Add(time.Minute), NextTick())
+	assertNotTriggered(t, sch, "while paused")
+	assertTriggered(t, other, "other schedulers not paused")
+	NextTick()
+	assertNotTriggered(t, sch, "while paused")
+	assertTriggered(t, other)
+
+	AdvanceBy(30 * time.Second)
+	sch.Resume()
+	assertTriggered(t, sch, "catch-up tick when resumed")
+	assertNotTriggered(t, sch, "only once when resumed")
+	require.Equal(t, start.Add(3*time.Minute), NextTick(),
+		"paused time counts towards interval")
+	assertTriggered(t, sch)
+	assertTriggered(t, other)
+
+	sch.Pause()
+	sch.Resume()
+	assertNotTriggered(t, sch, "when no ticks were missed")
+
+	sch.Pause()
+	NextTick()
+	assertTriggered(t, other)
+	sch.Stop()
+	sch.Resume()
+	assertNotTriggered(t, sch, "stop discards missed tick")
+
+	sch.Pause()
+	Pause()
+	sch.After(time.Second)
+	NextTick()
+	Resume()
+	assertNotTriggered(t, sch, "when globally resumed but still paused")
+	sch.Resume()
+	assertTriggered(t, sch, "when both resumed")
+}
+
 func TestPastTriggers_TestMode(t *testing.T) {
 	TestMode()
 	sch := NewScheduler()
@@ -280,3 +324,59 @@ func TestTestModeReset(t *testing.T) {
 	assertNotTriggered(t, sch1, "previous scheduler is not triggered")
 	assertTriggered(t, sch2, "new scheduler is repeatedly triggered")
 }
+
+func TestStats(t *testing.T) {
+	TestMode()
+	start := Now()
+	fast := NewScheduler().Every(time.Second)
+	once := NewScheduler()
+	idle := NewScheduler()
+
+	require.Equal(t, Stats{Interval: time.Second}, fast.Stats(), "before triggering")
+	require.Equal(t, Stats{}, idle.Stats(), "never scheduled")
+
+	for i := 0; i < 3; i++ {
+		NextTick()
+		assertTriggered(t, fast, "repeating scheduler")
+	}
+	stats := fast.Stats()
+	require.True(t, start.Add(3*time.Second).Equal(stats.LastFired),
+		"last fired at %v", stats.LastFired)
+	stats.LastFired = time.Time{}
+	require.Equal(t, Stats{
+		Fired:        3,
+		Interval:     time.Second,
+		MeanInterval: time.Second,
+	}, stats)
+	require.Len(t, AllStats(), 1, "only schedulers that have been scheduled")
+	fast.Stop()
+
+	once.After(time.Minute)
+	NextTick()
+	assertTriggered(t, once, "one-off scheduler")
+	stats = once.Stats()
+	require.Equal(t, int64(1), stats.Fired)
+	require.Equal(t, time.Duration(0), stats.MeanInterval, "needs two triggers")
+	require.True(t, start.Add(3*time.Second+time.Minute).Equal(stats.LastFired))
+
+	once.Every(time.Minute).After(time.Hour)
+	NextTick()
+	assertTriggered(t, once, "after rescheduling")
+	stats = once.Stats()
+	require.Equal(t, int64(2), stats.Fired)
+	require.Equal(t, time.Hour, stats.MeanInterval)
+	require.Equal(t, time.Duration(0), stats.Interval, "no longer repeating")
+
+	all := AllStats()
+	require.Len(t, all, 1, "stopped schedulers are dropped")
+	require.Equal(t, int64(2), all[0].Fired)
+
+	fast.Every(time.Minute)
+	all = AllStats()
+	require.Len(t, all, 2, "re-added when scheduled again")
+	require.Equal(t, []int64{3, 2}, []int64{all[0].Fired, all[1].Fired},
+		"sorted by number of triggers")
+
+	TestMode()
+	require.Empty(t, AllStats(), "reset on entering test mode")
+}
@@ -53,16 +53,60 @@ type Scheduler interface {
 
 	// At sets the scheduler to trigger a specific time.
 	// This will replace any pending triggers.
+	//
+	// The time is a wall-clock time, so if the system clock is adjusted
+	// (e.g. an NTP step), the trigger is re-aligned to the new clock. Moving
+	// the clock past the trigger time may be noticed up to 10s late.
+	// In test mode, it triggers when test time reaches the given time.
 	At(time.Time) Scheduler
 
 	// After sets the scheduler to trigger after a delay.
-	// This will replace any pending triggers.
+	// This will replace any pending triggers, including those set by At
+	// or Every.
 	After(time.Duration) Scheduler
 
 	// Every sets the scheduler to trigger at an interval.
-	// This will replace any pending triggers.
+	// This will replace any pending triggers, including those set by At
+	// or After.
 	Every(time.Duration) Scheduler
 
+	// On sets the scheduler to trigger at the times given by a Schedule,
+	// e.g. from CronSchedule. Like At, the times are wall-clock times.
+	// This will replace any pending triggers.
+	On(Schedule) Scheduler
+
+	// Pause suspends delivery of ticks from this scheduler, without
+	// changing its schedule. Paused time counts towards the next trigger,
+	// e.g. a repeating scheduler keeps its original phase.
+	Pause()
+
+	// Resume resumes delivery of ticks after Pause. If the scheduler was
+	// triggered while paused, a single tick is delivered immediately.
+	// To resume without catching up, call Stop before Resume, and then
+	// set a new schedule.
+	Resume()
+
+	// Stats returns statistics about the scheduler's triggers.
+	Stats() Stats
+
 	// Stop cancels all further triggers for the scheduler.
 	Stop()
 }
+
+// Stats contains statistics about the triggers of a scheduler, to help find
+// schedulers that fire more often than necessary.
+type Stats struct {
+	// Name identifies the scheduler by its logging labels, which attribute
+	// it to a module. Only available when built with the debuglog tag.
+	Name string
+	// Fired is the number of times the scheduler has been triggered.
+	Fired int64
+	// Interval is the requested interval for repeating schedulers,
+	// or 0 if the scheduler is not repeating.
+	Interval time.Duration
+	// MeanInterval is the mean actual interval between triggers,
+	// or 0 if the scheduler has been triggered less than twice.
+	MeanInterval time.Duration
+	// LastFired is the time of the most recent trigger.
+	LastFired time.Time
+}